@@ -1,8 +1,10 @@
 package bot
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -10,6 +12,7 @@ import (
 	"path"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -46,9 +49,70 @@ const (
 // AudioEvent is a self-contained representation of an intent to emit audio in a
 // given guild's voice channel.
 type AudioEvent struct {
+	// offset is the number of Opus frames that have been sent. It's accessed
+	// atomically.
+	offset int64
+
 	guildID        string
 	voiceChannelID string
 	audio          io.ReadCloser
+
+	// tag identifies events that may be superseded before they're played.
+	tag string
+
+	track Track
+
+	// live is the live stream the audio comes from, if any.
+	live *LiveStream
+
+	// resumed means the event was put back at the front of the queue after
+	// being interrupted.
+	resumed bool
+}
+
+// Track describes requested audio.
+type Track struct {
+	// Origin is the URL the audio was requested from.
+	Origin string
+
+	// CacheKey is the key of the converted audio in the Opus cache. Events
+	// without one can't be restored after a restart.
+	CacheKey string
+
+	Title       string
+	RequesterID string
+}
+
+// GuildID is the ID of the guild the event is played in.
+func (e *AudioEvent) GuildID() string {
+	return e.guildID
+}
+
+// VoiceChannelID is the ID of the voice channel the event is played in.
+func (e *AudioEvent) VoiceChannelID() string {
+	return e.voiceChannelID
+}
+
+// Track describes the event's audio, if it was requested.
+func (e *AudioEvent) Track() Track {
+	return e.track
+}
+
+// RequesterID is the ID of the user who queued the event, if any.
+func (e *AudioEvent) RequesterID() string {
+	return e.track.RequesterID
+}
+
+// Title is the title of the event's audio, if it has one. A live stream's
+// title is whatever it's currently playing.
+func (e *AudioEvent) Title() string {
+	if e.live != nil {
+		if title := e.live.Title(); title != "" {
+			return e.track.Title + ": " + title
+		}
+	}
+
+	return e.track.Title
 }
 
 // Audio contains the state needed for audio receiving and sending.
@@ -68,19 +132,59 @@ type Audio struct {
 	OnInboundAudioPacket func(*discordgo.Packet)
 
 	queue *AudioEventQueue
+
+	// nowPlaying is the event being sent, if any. It's guarded by stateCond.
+	nowPlaying *AudioEvent
+
+	// paused are the guilds whose playback is paused. The queue checks it while
+	// locked, so it has its own lock rather than stateCond.
+	pausedLock sync.Mutex
+	paused     map[string]bool
+
+	idleLock   sync.Mutex
+	idleTimers map[string]*time.Timer
+
+	// queueStore is where the queue is saved between restarts. It's not saved
+	// when nil.
+	queueStore  Store
+	persistLock sync.Mutex
+	restoreOnce sync.Once
+
+	// restored is guarded by persistLock.
+	restored bool
+
+	history *History
 }
 
 // NewAudio creates an Audio struct
 func NewAudio(bot *Bot) *Audio {
-	return &Audio{
+	queue := NewAudioEventQueue()
+
+	queue.fair = func(guildID string) bool {
+		return bot.settings.Guild(guildID).FairQueue
+	}
+
+	a := &Audio{
 		bot:            bot,
 		sendCond:       sync.NewCond(new(sync.Mutex)),
 		receiveCond:    sync.NewCond(new(sync.Mutex)),
 		stateCond:      sync.NewCond(new(sync.Mutex)),
 		userSSRCs:      map[string]uint32{},
 		streamDecoders: map[uint32]*gopus.Decoder{},
-		queue:          NewAudioEventQueue(),
+		queue:          queue,
+		paused:         map[string]bool{},
+		idleTimers:     map[string]*time.Timer{},
+		queueStore:     bot.store,
+		history:        NewHistory(NewBucket(bot.store, historyBucket...)),
 	}
+
+	queue.held = a.isPaused
+
+	bot.bus.Subscribe(a.onVoiceLeave)
+	bot.bus.Subscribe(a.onVoiceMove)
+	bot.bus.Subscribe(a.onTrackFinished)
+
+	return a
 }
 
 // Skip skips the current event.
@@ -93,6 +197,35 @@ func (a *Audio) Skip() {
 	a.stateCond.L.Unlock()
 }
 
+// NowPlaying returns the event being played in the guild, if any.
+func (a *Audio) NowPlaying(guildID string) *AudioEvent {
+	a.stateCond.L.Lock()
+	defer a.stateCond.L.Unlock()
+
+	if a.nowPlaying != nil && a.nowPlaying.guildID == guildID {
+		return a.nowPlaying
+	}
+
+	return nil
+}
+
+// SkipEvent skips the event if it's still the one being played, reporting
+// whether it was.
+func (a *Audio) SkipEvent(event *AudioEvent) bool {
+	a.stateCond.L.Lock()
+	defer a.stateCond.L.Unlock()
+
+	if event == nil || a.nowPlaying != event {
+		return false
+	}
+
+	a.playerState = PlayerStateSkipped
+
+	a.stateCond.Signal()
+
+	return true
+}
+
 // Clear clears the entire event queue.
 func (a *Audio) Clear() {
 	a.stateCond.L.Lock()
@@ -102,6 +235,55 @@ func (a *Audio) Clear() {
 
 	a.stateCond.Signal()
 	a.stateCond.L.Unlock()
+
+	a.persist()
+}
+
+// ClearGuild stops what's playing in the guild and drops the guild's queued
+// events, leaving other guilds' playback alone.
+func (a *Audio) ClearGuild(guildID string) {
+	a.queue.Remove(func(event *AudioEvent) bool {
+		return event.guildID == guildID
+	})
+
+	a.pausedLock.Lock()
+	delete(a.paused, guildID)
+	a.pausedLock.Unlock()
+
+	a.persist()
+
+	a.stateCond.L.Lock()
+
+	if a.nowPlaying != nil && a.nowPlaying.guildID == guildID {
+		a.playerState = PlayerStateCleared
+		a.stateCond.Signal()
+	}
+
+	a.stateCond.L.Unlock()
+}
+
+// PauseGuild pauses the guild's playback. Its events stay queued while other
+// guilds' are played.
+func (a *Audio) PauseGuild(guildID string) {
+	a.pausedLock.Lock()
+	a.paused[guildID] = true
+	a.pausedLock.Unlock()
+}
+
+// ResumeGuild resumes the guild's paused playback.
+func (a *Audio) ResumeGuild(guildID string) {
+	a.pausedLock.Lock()
+	delete(a.paused, guildID)
+	a.pausedLock.Unlock()
+
+	a.queue.Wake()
+}
+
+func (a *Audio) isPaused(guildID string) bool {
+	a.pausedLock.Lock()
+	defer a.pausedLock.Unlock()
+
+	return a.paused[guildID]
 }
 
 // Pause pauses the player.
@@ -147,11 +329,54 @@ func (a *Audio) Preempt(guildID, voiceChannelID, filePath string) {
 }
 
 func (a *Audio) EnqueueAudioFile(guildID, voiceChannelID string, file *os.File) {
-	a.queue.Enqueue(&AudioEvent{
+	a.EnqueueTaggedAudioFile(guildID, voiceChannelID, "", file)
+}
+
+// EnqueueTaggedAudioFile enqueues the file with a tag that can later be used
+// to drop it from the queue if it becomes stale before it's played.
+func (a *Audio) EnqueueTaggedAudioFile(guildID, voiceChannelID, tag string, file *os.File) {
+	a.enqueue(&AudioEvent{
 		guildID:        guildID,
 		voiceChannelID: voiceChannelID,
 		audio:          file,
+		tag:            tag,
+	})
+}
+
+// EnqueueTrack enqueues the file for the requested track.
+func (a *Audio) EnqueueTrack(guildID, voiceChannelID string, track Track, file *os.File) {
+	a.enqueue(&AudioEvent{
+		guildID:        guildID,
+		voiceChannelID: voiceChannelID,
+		audio:          file,
+		track:          track,
+	})
+}
+
+// enqueue queues the event, saves the queue and announces the event.
+func (a *Audio) enqueue(event *AudioEvent) {
+	a.queue.Enqueue(event)
+
+	a.persist()
+
+	a.bot.bus.Publish(&TrackQueuedEvent{Event: event})
+}
+
+// Queued returns the guild's queued events in the order they'll be played.
+func (a *Audio) Queued(guildID string) []*AudioEvent {
+	return a.queue.Queued(guildID)
+}
+
+// DropTagged removes the queued events with the given tag, returning how many
+// were removed.
+func (a *Audio) DropTagged(tag string) int {
+	removed := a.queue.Remove(func(event *AudioEvent) bool {
+		return event.tag == tag
 	})
+
+	a.persist()
+
+	return removed
 }
 
 // TODO
@@ -177,6 +402,14 @@ func (a *Audio) ProcessAudioEventQueue() {
 		// Block until we get another audio event.
 		event := a.queue.Dequeue()
 
+		a.stopIdleTimer(event.guildID)
+
+		a.stateCond.L.Lock()
+		a.nowPlaying = event
+		a.stateCond.L.Unlock()
+
+		a.persist()
+
 		a.bot.VoiceLog().WithFields(log.Fields{
 			"guild":   event.guildID,
 			"channel": event.voiceChannelID,
@@ -191,6 +424,10 @@ func (a *Audio) ProcessAudioEventQueue() {
 				"channel": event.voiceChannelID,
 			}).WithError(err).Error("Couldn't join voice channel")
 
+			a.bot.bus.Publish(&TrackFailedEvent{Event: event, Err: err})
+
+			a.finishPlaying(event, false)
+
 			continue
 		}
 
@@ -201,10 +438,23 @@ func (a *Audio) ProcessAudioEventQueue() {
 
 		// TODO
 		// Will this repetitively add handlers?
-		voiceConnection.AddHandler(a.onVoiceSpeakingUpdate)
+		voiceConnection.OnSpeakingUpdate(a.onVoiceSpeakingUpdate)
+
+		a.bot.bus.Publish(&TrackStartedEvent{Event: event})
 
 		// Send Opus audio until it's finished or a control is received.
-		a.SendOpus(voiceConnection, event)
+		err = a.SendOpus(voiceConnection, event)
+
+		switch {
+		case err != nil:
+			a.bot.bus.Publish(&TrackFailedEvent{Event: event, Err: err})
+
+		// A paused or preempted event was put back in the queue to be resumed.
+		case !event.resumed:
+			a.bot.bus.Publish(&TrackFinishedEvent{Event: event})
+		}
+
+		a.finishPlaying(event, err == nil && !event.resumed)
 	}
 
 	a.bot.VoiceLog().Fatal("Exited playAudio")
@@ -213,13 +463,14 @@ func (a *Audio) ProcessAudioEventQueue() {
 // StopSpeaking emits Speaking(false) after a 250ms delay in the hopes that
 // discordgo is done with the channel by then, otherwise discordgo resets it to
 // Speaking(true).
-func (a *Audio) StopSpeaking(voiceConnection *discordgo.VoiceConnection) {
+func (a *Audio) StopSpeaking(voiceConnection VoiceConnection) {
 	time.Sleep(250 * time.Millisecond)
 	voiceConnection.Speaking(false)
 }
 
-// SendOpus sends Opus-encoded data to the voice connection.
-func (a *Audio) SendOpus(voiceConnection *discordgo.VoiceConnection, event *AudioEvent) {
+// SendOpus sends Opus-encoded data to the voice connection until the audio
+// ends or a control is received, returning an error if it couldn't be sent.
+func (a *Audio) SendOpus(voiceConnection VoiceConnection, event *AudioEvent) error {
 	a.sendCond.L.Lock()
 
 	for a.sendingPCM {
@@ -242,31 +493,37 @@ func (a *Audio) SendOpus(voiceConnection *discordgo.VoiceConnection, event *Audi
 	for {
 		a.stateCond.L.Lock()
 
-		switch a.playerState {
+		state := a.playerState
+
+		if state == PlayerStateReady && a.isPaused(event.guildID) {
+			state = PlayerStatePaused
+		}
+
+		switch state {
 		case PlayerStateCleared, PlayerStateSkipped:
 			event.audio.Close()
 			a.StopSpeaking(voiceConnection)
 			a.stateCond.L.Unlock()
-			return
+			return nil
 
 		case PlayerStatePaused:
 			a.queue.EnqueueFront(event)
 			a.StopSpeaking(voiceConnection)
 			a.stateCond.L.Unlock()
-			return
+			return nil
 
 		case PlayerStatePreempted:
 			a.queue.EnqueueFront(event)
 			a.queue.Preempt()
 			a.StopSpeaking(voiceConnection)
 			a.stateCond.L.Unlock()
-			return
+			return nil
 		}
 
 		a.stateCond.L.Unlock()
 
 		// 128 [kb] * 20 [frame size] / 8 [byte] = 320
-		opusFrame := make([]byte, 320)
+		opusFrame := make([]byte, opusFrameBytes)
 
 		err := binary.Read(event.audio, binary.LittleEndian, &opusFrame)
 
@@ -275,7 +532,7 @@ func (a *Audio) SendOpus(voiceConnection *discordgo.VoiceConnection, event *Audi
 			event.audio.Close()
 
 			a.StopSpeaking(voiceConnection)
-			return
+			return nil
 		}
 
 		if err == io.ErrUnexpectedEOF {
@@ -283,7 +540,7 @@ func (a *Audio) SendOpus(voiceConnection *discordgo.VoiceConnection, event *Audi
 			event.audio.Close()
 
 			a.StopSpeaking(voiceConnection)
-			return
+			return nil
 		}
 
 		if err != nil {
@@ -291,27 +548,59 @@ func (a *Audio) SendOpus(voiceConnection *discordgo.VoiceConnection, event *Audi
 			event.audio.Close()
 
 			a.StopSpeaking(voiceConnection)
-			return
+			return err
 		}
 
-		if !voiceConnection.Ready || voiceConnection.OpusSend == nil {
+		if !voiceConnection.Ready() || voiceConnection.OpusSend() == nil {
 			a.bot.VoiceLog().Error("Client isn't ready to send Opus packets")
 			// Keep looping until it's ready, otherwise this event will be dropped.
 
 			a.StopSpeaking(voiceConnection)
-			return
+			return errVoiceNotReady
 		}
 
 		// Send the Opus frame through the Discord voice connection.
-		voiceConnection.OpusSend <- opusFrame
+		voiceConnection.OpusSend() <- opusFrame
+
+		if atomic.AddInt64(&event.offset, 1)%persistInterval == 0 {
+			a.persist()
+		}
+	}
+}
+
+// errVoiceNotReady means the voice connection couldn't take audio.
+var errVoiceNotReady = errors.New("Voice connection isn't ready")
+
+// opusEncodingArgs are the ffmpeg arguments that encode the input as the
+// constant bitrate Opus frames SendOpus expects, written to the output.
+func opusEncodingArgs(output string) []string {
+	return []string{
+		"-f", "data",
+		"-map", "0:a",
+		"-ar", strconv.Itoa(frequency),
+		"-ac", strconv.Itoa(channels),
+		"-acodec", "libopus",
+		"-sample_fmt", "s16",
+		"-vbr", "off",
+		"-b:a", "128000",
+		"-compression_level", "10",
+		output,
 	}
 }
 
+// opusCachePath is the path of the converted audio for the key in the Opus
+// cache.
+func (a *Audio) opusCachePath(key string) string {
+	return path.Join(a.bot.Config().Paths.Opus, fmt.Sprintf("%x", sha1.Sum([]byte(key))))
+}
+
 // TODO
 // This should accept an explicit key. When filePath is a youtube-dl-derived
 // youtube audioURL, the URL may be different each time even though it's been
 // downloading before. In this case it should be determined by the Origin URL.
-func (a *Audio) GetOrConvertFile(filePath string, keys ...string) (*os.File, error) {
+//
+// The conversion is stopped when the context is cancelled.
+func (a *Audio) GetOrConvertFile(ctx context.Context, filePath string, keys ...string) (*os.File, error) {
 	var key string
 
 	if len(keys) == 0 {
@@ -322,8 +611,7 @@ func (a *Audio) GetOrConvertFile(filePath string, keys ...string) (*os.File, err
 
 	a.bot.VoiceLog().WithField("path", filePath).Info("Getting or converting file")
 
-	shaSum := fmt.Sprintf("%x", sha1.Sum([]byte(key)))
-	audioPath := path.Join("./data/opus", shaSum)
+	audioPath := a.opusCachePath(key)
 
 	if _, err := os.Stat(audioPath); err == nil {
 		a.bot.VoiceLog().WithField("path", audioPath).Info("Cache Hit: Opus audio")
@@ -332,19 +620,7 @@ func (a *Audio) GetOrConvertFile(filePath string, keys ...string) (*os.File, err
 
 	a.bot.VoiceLog().WithField("path", filePath).Info("Invoking FFMPEG")
 
-	ffmpeg := exec.Command(
-		"ffmpeg",
-		"-i", filePath,
-		"-f", "data",
-		"-map", "0:a",
-		"-ar", strconv.Itoa(frequency),
-		"-ac", strconv.Itoa(channels),
-		"-acodec", "libopus",
-		"-sample_fmt", "s16",
-		"-vbr", "off",
-		"-b:a", "128000",
-		"-compression_level", "10",
-		audioPath)
+	ffmpeg := exec.CommandContext(ctx, "ffmpeg", append([]string{"-i", filePath}, opusEncodingArgs(audioPath)...)...)
 
 	err := ffmpeg.Start()
 
@@ -361,6 +637,10 @@ func (a *Audio) GetOrConvertFile(filePath string, keys ...string) (*os.File, err
 
 	if err != nil {
 		a.bot.VoiceLog().WithError(err).Error("Conversion error")
+
+		// Don't leave a partial conversion behind to be mistaken for a cached one.
+		os.Remove(audioPath)
+
 		return nil, err
 	}
 
@@ -388,29 +668,27 @@ func (a *Audio) GetOrConvertFile(filePath string, keys ...string) (*os.File, err
 // type simply exposed methods such as onVoiceSpeakingUpdate() and
 // onVoiceStateUpdate() which are invoked by the Bot.
 
-func (a *Audio) onVoiceSpeakingUpdate(voiceConnection *discordgo.VoiceConnection, speakingUpdate *discordgo.VoiceSpeakingUpdate) {
+func (a *Audio) onVoiceSpeakingUpdate(speakingUpdate *discordgo.VoiceSpeakingUpdate) {
 	// In discordgo VoiceSpeakingUpdate.SSRC is int while it's uint32 everywhere
 	// else.
 	a.userSSRCs[speakingUpdate.UserID] = uint32(speakingUpdate.SSRC)
 }
 
-func (a *Audio) onUserLeaveVoiceChannel(voiceState *discordgo.VoiceState) {
-	// User left the channel, either their SSRC is no longer relevant or it may
-	// change if they join another channel (?), so to be safe remove their entry.
-	//
-	// Note that this may be a critical section. For example, what if this
-	// triggers while in the middle of receivePCM()? Should it multiplex on a
-	// channel that receives such a notification? Or what if the user has left but
-	// we still have audio buffered that we're in the process of decoding?
-	if voiceState.ChannelID == "" {
-		delete(a.streamDecoders, a.userSSRCs[voiceState.UserID])
-		delete(a.userSSRCs, voiceState.UserID)
-	}
+// forgetSSRC forgets the user's SSRC when they leave a voice channel, since it
+// may change if they join another one.
+//
+// Note that this may be a critical section. For example, what if this
+// triggers while in the middle of receivePCM()? Should it multiplex on a
+// channel that receives such a notification? Or what if the user has left but
+// we still have audio buffered that we're in the process of decoding?
+func (a *Audio) forgetSSRC(userID string) {
+	delete(a.streamDecoders, a.userSSRCs[userID])
+	delete(a.userSSRCs, userID)
 }
 
 // Receive audio packets from the Discord voice connection and Opus-decode them
 // into PCM.
-func (a *Audio) receivePCM(voiceConnection *discordgo.VoiceConnection) {
+func (a *Audio) receivePCM(voiceConnection VoiceConnection) {
 	// TODO
 	// Use receiveCond.
 
@@ -421,12 +699,12 @@ func (a *Audio) receivePCM(voiceConnection *discordgo.VoiceConnection) {
 	var err error
 
 	for {
-		if !voiceConnection.Ready || voiceConnection.OpusRecv == nil {
+		if !voiceConnection.Ready() || voiceConnection.OpusRecv() == nil {
 			a.bot.VoiceLog().Error("Client isn't ready to receive opus packets")
 		}
 
 		// Obtain an audio packet from Discord's audio input.
-		inboundAudioPacket, ok := <-voiceConnection.OpusRecv
+		inboundAudioPacket, ok := <-voiceConnection.OpusRecv()
 
 		if !ok {
 			a.bot.VoiceLog().Info("No audio packet available")
@@ -0,0 +1,208 @@
+package bot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// AutoplaySource is where autoplay picks tracks from when a guild's queue runs
+// dry.
+type AutoplaySource string
+
+const (
+	// AutoplayOff disables autoplay.
+	AutoplayOff AutoplaySource = ""
+
+	// AutoplayHistory picks a random track from the guild's history.
+	AutoplayHistory AutoplaySource = "history"
+
+	// AutoplayLibrary picks a random file from the local library.
+	AutoplayLibrary AutoplaySource = "library"
+
+	// AutoplayRelated searches for a track related to the last one.
+	AutoplayRelated AutoplaySource = "related"
+)
+
+// ParseAutoplaySource parses the name of an autoplay source.
+func ParseAutoplaySource(name string) (AutoplaySource, error) {
+	switch source := AutoplaySource(strings.ToLower(name)); source {
+	case AutoplayHistory, AutoplayLibrary, AutoplayRelated:
+		return source, nil
+	}
+
+	return AutoplayOff, fmt.Errorf("%q isn't history, library or related", name)
+}
+
+// autoplay queues another track in the event's voice channel if the guild has
+// autoplay enabled and there's still someone listening. Only requested tracks
+// lead to autoplay, so that speech doesn't start the music.
+func (a *Audio) autoplay(last *AudioEvent) {
+	source := a.bot.settings.Guild(last.guildID).Autoplay
+
+	if source == AutoplayOff || last.track.Origin == "" {
+		return
+	}
+
+	logger := a.bot.VoiceLog().WithFields(log.Fields{
+		"guild":   last.guildID,
+		"channel": last.voiceChannelID,
+		"source":  source,
+	})
+
+	if len(a.bot.VoiceChannelUsers(last.guildID, last.voiceChannelID)) == 0 {
+		logger.Info("Stopping autoplay in empty voice channel")
+		return
+	}
+
+	var (
+		track Track
+		file  *os.File
+		err   error
+	)
+
+	switch source {
+	case AutoplayHistory:
+		track, file, err = a.autoplayFromHistory(last)
+
+	case AutoplayLibrary:
+		track, file, err = a.autoplayFromLibrary(last)
+
+	case AutoplayRelated:
+		track, file, err = a.autoplayRelated(last)
+	}
+
+	if err != nil {
+		logger.WithError(err).Warn("Couldn't pick a track to autoplay")
+		return
+	}
+
+	// Picking a track may have taken a while, during which the listeners may
+	// have queued something themselves or the channel may have been left.
+	if a.isPlayingIn(last.guildID) || a.hasQueuedEvents(last.guildID) ||
+		a.VoiceChannelID(last.guildID) != last.voiceChannelID {
+		file.Close()
+		return
+	}
+
+	logger.WithField("origin", track.Origin).Info("Autoplaying track")
+
+	a.EnqueueTrack(last.guildID, last.voiceChannelID, track, file)
+}
+
+// openTrack opens the track's audio from the cache, converting it from the
+// audio URL if it isn't cached.
+func (a *Audio) openTrack(track Track, audioURL string) (*os.File, error) {
+	if file, err := a.OpenCached(track.CacheKey); err == nil {
+		return file, nil
+	}
+
+	return a.GetOrConvertFile(a.bot.Context(), audioURL, track.CacheKey)
+}
+
+func (a *Audio) autoplayFromHistory(last *AudioEvent) (Track, *os.File, error) {
+	var candidates []HistoryEntry
+
+	for _, entry := range a.history.Recent(last.guildID, maxHistory) {
+		if entry.Origin != last.track.Origin {
+			candidates = append(candidates, entry)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return Track{}, nil, fmt.Errorf("No other tracks in the history")
+	}
+
+	track := candidates[rand.Intn(len(candidates))].Track()
+	track.RequesterID = ""
+
+	if file, err := a.OpenCached(track.CacheKey); err == nil {
+		return track, file, nil
+	}
+
+	meta, err := GetAudioMetadata(a.bot.Context(), track.Origin)
+
+	if err != nil {
+		return Track{}, nil, err
+	}
+
+	if IsLiveStream(a.bot.Context(), meta) {
+		return Track{}, nil, fmt.Errorf("%s is a live stream", track.Origin)
+	}
+
+	file, err := a.GetOrConvertFile(a.bot.Context(), meta.AudioURL, track.CacheKey)
+
+	return track, file, err
+}
+
+func (a *Audio) autoplayFromLibrary(last *AudioEvent) (Track, *os.File, error) {
+	libraryPath := a.bot.Config().Paths.Library
+
+	infos, err := ioutil.ReadDir(libraryPath)
+
+	if err != nil {
+		return Track{}, nil, err
+	}
+
+	var candidates []string
+
+	for _, info := range infos {
+		filePath := path.Join(libraryPath, info.Name())
+
+		if info.Mode().IsRegular() && filePath != last.track.Origin {
+			candidates = append(candidates, filePath)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return Track{}, nil, fmt.Errorf("No other tracks in the library")
+	}
+
+	filePath := candidates[rand.Intn(len(candidates))]
+	name := path.Base(filePath)
+
+	track := Track{
+		Origin:   filePath,
+		CacheKey: filePath,
+		Title:    strings.TrimSuffix(name, path.Ext(name)),
+	}
+
+	file, err := a.openTrack(track, filePath)
+
+	return track, file, err
+}
+
+func (a *Audio) autoplayRelated(last *AudioEvent) (Track, *os.File, error) {
+	exclude := map[string]bool{}
+
+	for _, entry := range a.history.Recent(last.guildID, 20) {
+		exclude[entry.Origin] = true
+	}
+
+	exclude[last.track.Origin] = true
+
+	meta, err := RelatedAudioMetadata(a.bot.Context(), last.track.Title, exclude)
+
+	if err != nil {
+		return Track{}, nil, err
+	}
+
+	if IsLiveStream(a.bot.Context(), meta) {
+		return Track{}, nil, fmt.Errorf("%s is a live stream", meta.Origin)
+	}
+
+	track := Track{
+		Origin:   meta.Origin,
+		CacheKey: meta.Origin,
+		Title:    meta.Title,
+	}
+
+	file, err := a.openTrack(track, meta.AudioURL)
+
+	return track, file, err
+}
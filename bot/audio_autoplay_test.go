@@ -0,0 +1,27 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAutoplaySource(t *testing.T) {
+	source, err := ParseAutoplaySource("History")
+
+	assert.NoError(t, err)
+	assert.Equal(t, AutoplayHistory, source)
+
+	source, err = ParseAutoplaySource("related")
+
+	assert.NoError(t, err)
+	assert.Equal(t, AutoplayRelated, source)
+
+	_, err = ParseAutoplaySource("")
+
+	assert.Error(t, err)
+
+	_, err = ParseAutoplaySource("spotify")
+
+	assert.Error(t, err)
+}
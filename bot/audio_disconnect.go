@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// finishPlaying clears the now playing event and, if nothing else is queued
+// for its guild, starts counting down to leaving the guild's voice channel. If
+// the event played and the player wasn't cleared, autoplay may pick what plays
+// next.
+func (a *Audio) finishPlaying(event *AudioEvent, played bool) {
+	a.stateCond.L.Lock()
+
+	a.nowPlaying = nil
+	cleared := a.playerState == PlayerStateCleared
+
+	a.stateCond.Broadcast()
+	a.stateCond.L.Unlock()
+
+	a.persist()
+
+	if a.hasQueuedEvents(event.guildID) {
+		return
+	}
+
+	a.startIdleTimer(event.guildID)
+
+	if played && !cleared {
+		go a.autoplay(event)
+	}
+}
+
+func (a *Audio) hasQueuedEvents(guildID string) bool {
+	return a.queue.Any(func(event *AudioEvent) bool {
+		return event.guildID == guildID
+	})
+}
+
+func (a *Audio) isPlayingIn(guildID string) bool {
+	a.stateCond.L.Lock()
+	defer a.stateCond.L.Unlock()
+
+	return a.nowPlaying != nil && a.nowPlaying.guildID == guildID
+}
+
+func (a *Audio) startIdleTimer(guildID string) {
+	idleTimeout := a.bot.Config().Voice.IdleTimeout.Duration
+
+	if idleTimeout <= 0 {
+		return
+	}
+
+	a.idleLock.Lock()
+	defer a.idleLock.Unlock()
+
+	if timer, ok := a.idleTimers[guildID]; ok {
+		timer.Stop()
+	}
+
+	a.idleTimers[guildID] = time.AfterFunc(idleTimeout, func() {
+		a.disconnectIfIdle(guildID)
+	})
+}
+
+func (a *Audio) stopIdleTimer(guildID string) {
+	a.idleLock.Lock()
+	defer a.idleLock.Unlock()
+
+	if timer, ok := a.idleTimers[guildID]; ok {
+		timer.Stop()
+		delete(a.idleTimers, guildID)
+	}
+}
+
+// StartIdleTimer starts counting down to leaving the guild's voice channel.
+// It's meant for when the voice channel was joined without queuing anything.
+func (a *Audio) StartIdleTimer(guildID string) {
+	a.startIdleTimer(guildID)
+}
+
+func (a *Audio) disconnectIfIdle(guildID string) {
+	if a.isPlayingIn(guildID) || a.hasQueuedEvents(guildID) {
+		return
+	}
+
+	a.bot.VoiceLog().WithField("guild", guildID).Info("Leaving idle voice channel")
+
+	a.Leave(guildID)
+}
+
+func (a *Audio) onVoiceLeave(event *VoiceLeaveEvent) {
+	a.forgetSSRC(event.UserID)
+	a.leaveIfAlone(event.GuildID)
+}
+
+func (a *Audio) onVoiceMove(event *VoiceMoveEvent) {
+	a.forgetSSRC(event.UserID)
+	a.leaveIfAlone(event.GuildID)
+}
+
+// leaveIfAlone leaves the guild's voice channel if the bot is the only one left
+// in it.
+func (a *Audio) leaveIfAlone(guildID string) {
+	channelID := a.VoiceChannelID(guildID)
+
+	if channelID == "" || len(a.bot.VoiceChannelUsers(guildID, channelID)) > 0 {
+		return
+	}
+
+	a.bot.VoiceLog().WithFields(log.Fields{
+		"guild":   guildID,
+		"channel": channelID,
+	}).Info("Leaving empty voice channel")
+
+	// Leaving waits for the player to stop, so don't hold up event handling.
+	go a.Leave(guildID)
+}
+
+// VoiceChannelID is the ID of the voice channel the bot is connected to in the
+// guild, if any.
+func (a *Audio) VoiceChannelID(guildID string) string {
+	if voiceConnection, ok := a.bot.Session().VoiceConnection(guildID); ok {
+		return voiceConnection.ChannelID()
+	}
+
+	return ""
+}
+
+// Leave stops playing in the guild, drops the guild's queued events, and
+// leaves its voice channel.
+func (a *Audio) Leave(guildID string) {
+	a.stopIdleTimer(guildID)
+
+	a.queue.Remove(func(event *AudioEvent) bool {
+		return event.guildID == guildID
+	})
+
+	a.persist()
+
+	a.stateCond.L.Lock()
+
+	if a.nowPlaying != nil && a.nowPlaying.guildID == guildID {
+		// Clearing rather than skipping keeps autoplay from queuing another track.
+		a.playerState = PlayerStateCleared
+		a.stateCond.Broadcast()
+
+		// Wait for the player to stop sending before disconnecting, otherwise it
+		// may block on a voice connection that's no longer being drained.
+		for a.nowPlaying != nil && a.nowPlaying.guildID == guildID {
+			a.stateCond.Wait()
+		}
+	}
+
+	a.stateCond.L.Unlock()
+
+	voiceConnection, ok := a.bot.Session().VoiceConnection(guildID)
+
+	if !ok {
+		return
+	}
+
+	if err := voiceConnection.Disconnect(); err != nil {
+		a.bot.VoiceLog().WithFields(log.Fields{
+			"guild":   guildID,
+			"channel": voiceConnection.ChannelID(),
+		}).WithError(err).Error("Couldn't leave voice channel")
+	}
+
+	a.stopIdleTimer(guildID)
+}
@@ -6,13 +6,178 @@ import "sync"
 type AudioEventQueue struct {
 	cond  *sync.Cond
 	queue []*AudioEvent
+
+	// fair reports whether a guild's events are played round-robin by
+	// requester rather than in the order they were queued.
+	fair func(guildID string) bool
+
+	// rotations is the order in which each fair guild's requesters take turns.
+	rotations map[string][]string
+
+	// held reports whether a guild's events are held back from being dequeued,
+	// such as while the guild is paused.
+	held func(guildID string) bool
 }
 
 func NewAudioEventQueue() *AudioEventQueue {
 	return &AudioEventQueue{
-		queue: make([]*AudioEvent, 0, 10),
-		cond:  sync.NewCond(new(sync.Mutex)),
+		queue:     make([]*AudioEvent, 0, 10),
+		cond:      sync.NewCond(new(sync.Mutex)),
+		rotations: map[string][]string{},
+	}
+}
+
+func (q *AudioEventQueue) isFair(guildID string) bool {
+	return q.fair != nil && q.fair(guildID)
+}
+
+func (q *AudioEventQueue) isHeld(guildID string) bool {
+	return q.held != nil && q.held(guildID)
+}
+
+// requesters lists the requesters of the events in the order they'll take
+// turns: first those already in the rotation, then newcomers in the order they
+// first queued something.
+func requesters(rotation []string, events []*AudioEvent) []string {
+	queued := map[string]bool{}
+
+	for _, event := range events {
+		queued[event.RequesterID()] = true
+	}
+
+	var order []string
+	seen := map[string]bool{}
+
+	for _, requesterID := range rotation {
+		if queued[requesterID] && !seen[requesterID] {
+			order = append(order, requesterID)
+			seen[requesterID] = true
+		}
+	}
+
+	for _, event := range events {
+		if !seen[event.RequesterID()] {
+			order = append(order, event.RequesterID())
+			seen[event.RequesterID()] = true
+		}
+	}
+
+	return order
+}
+
+// interleave orders the events by taking one from each requester in turn.
+func interleave(rotation []string, events []*AudioEvent) []*AudioEvent {
+	byRequester := map[string][]*AudioEvent{}
+
+	for _, event := range events {
+		byRequester[event.RequesterID()] = append(byRequester[event.RequesterID()], event)
+	}
+
+	order := requesters(rotation, events)
+	interleaved := make([]*AudioEvent, 0, len(events))
+
+	for len(interleaved) < len(events) {
+		for _, requesterID := range order {
+			if pending := byRequester[requesterID]; len(pending) > 0 {
+				interleaved = append(interleaved, pending[0])
+				byRequester[requesterID] = pending[1:]
+			}
+		}
+	}
+
+	return interleaved
+}
+
+// ordered returns the events in the order they'll be played. Each fair guild's
+// events are interleaved by requester within the slots that guild already
+// occupies, while events put back at the front stay where they are. The
+// caller must hold the lock.
+func (q *AudioEventQueue) ordered() []*AudioEvent {
+	fairEvents := map[string][]*AudioEvent{}
+	fair := map[string]bool{}
+
+	for _, event := range q.queue {
+		if _, ok := fair[event.guildID]; !ok {
+			fair[event.guildID] = q.isFair(event.guildID)
+		}
+
+		if fair[event.guildID] && !event.resumed {
+			fairEvents[event.guildID] = append(fairEvents[event.guildID], event)
+		}
+	}
+
+	if len(fairEvents) == 0 {
+		return q.queue
+	}
+
+	for guildID, events := range fairEvents {
+		fairEvents[guildID] = interleave(q.rotations[guildID], events)
+	}
+
+	order := make([]*AudioEvent, len(q.queue))
+
+	for i, event := range q.queue {
+		events, ok := fairEvents[event.guildID]
+
+		if !ok || event.resumed {
+			order[i] = event
+			continue
+		}
+
+		order[i], fairEvents[event.guildID] = events[0], events[1:]
+	}
+
+	return order
+}
+
+// served moves the event's requester to the back of its guild's rotation. The
+// caller must hold the lock.
+func (q *AudioEventQueue) served(event *AudioEvent) {
+	if event.resumed || !q.isFair(event.guildID) {
+		return
+	}
+
+	var remaining []*AudioEvent
+
+	for _, queued := range q.queue {
+		if queued.guildID == event.guildID {
+			remaining = append(remaining, queued)
+		}
+	}
+
+	var rotation []string
+
+	for _, requesterID := range requesters(q.rotations[event.guildID], remaining) {
+		if requesterID != event.RequesterID() {
+			rotation = append(rotation, requesterID)
+		}
 	}
+
+	q.rotations[event.guildID] = append(rotation, event.RequesterID())
+}
+
+// Events returns every queued event in the order they'll be played.
+func (q *AudioEventQueue) Events() []*AudioEvent {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	return append([]*AudioEvent(nil), q.ordered()...)
+}
+
+// Queued returns the guild's queued events in the order they'll be played.
+func (q *AudioEventQueue) Queued(guildID string) []*AudioEvent {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	var events []*AudioEvent
+
+	for _, event := range q.ordered() {
+		if event.guildID == guildID {
+			events = append(events, event)
+		}
+	}
+
+	return events
 }
 
 func (q *AudioEventQueue) Clear() {
@@ -24,6 +189,7 @@ func (q *AudioEventQueue) Clear() {
 	}
 
 	q.queue = make([]*AudioEvent, 0, 10)
+	q.rotations = map[string][]string{}
 }
 
 func (q *AudioEventQueue) Enqueue(event *AudioEvent) {
@@ -51,23 +217,97 @@ func (q *AudioEventQueue) Preempt() {
 	q.cond.L.Unlock()
 }
 
+// EnqueueFront puts the event back at the front of the queue, where it stays
+// regardless of the guild's queue mode.
 func (q *AudioEventQueue) EnqueueFront(event *AudioEvent) {
 	q.cond.L.Lock()
 
+	event.resumed = true
+
 	q.queue = append([]*AudioEvent{event}, q.queue...)
 
 	q.cond.L.Unlock()
 }
 
+// Remove removes and closes every queued event that matches, returning how
+// many were removed.
+func (q *AudioEventQueue) Remove(matches func(*AudioEvent) bool) int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	kept := make([]*AudioEvent, 0, len(q.queue))
+
+	for _, event := range q.queue {
+		if matches(event) {
+			event.audio.Close()
+			continue
+		}
+
+		kept = append(kept, event)
+	}
+
+	removed := len(q.queue) - len(kept)
+	q.queue = kept
+
+	return removed
+}
+
+// Any reports whether any queued event matches.
+func (q *AudioEventQueue) Any(matches func(*AudioEvent) bool) bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+
+	for _, event := range q.queue {
+		if matches(event) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Wake has Dequeue check again for events that are no longer held back.
+func (q *AudioEventQueue) Wake() {
+	q.cond.L.Lock()
+
+	q.cond.Broadcast()
+	q.cond.L.Unlock()
+}
+
+// take removes the first event in play order that isn't held back, returning
+// nil if there's none. The caller must hold the lock.
+func (q *AudioEventQueue) take() *AudioEvent {
+	// Keep the queue in play order so that the slots fair guilds occupy advance
+	// along with everyone else's.
+	order := q.ordered()
+
+	for i, event := range order {
+		if q.isHeld(event.guildID) {
+			continue
+		}
+
+		q.queue = append(append(make([]*AudioEvent, 0, len(order)), order[:i]...), order[i+1:]...)
+
+		return event
+	}
+
+	return nil
+}
+
+// Dequeue blocks until there's an event that isn't held back, then removes and
+// returns it.
 func (q *AudioEventQueue) Dequeue() *AudioEvent {
 	q.cond.L.Lock()
 
-	for len(q.queue) == 0 {
+	event := q.take()
+
+	for event == nil {
 		q.cond.Wait()
+		event = q.take()
 	}
 
-	var event *AudioEvent
-	event, q.queue = q.queue[0], q.queue[1:]
+	q.served(event)
+	event.resumed = false
 
 	q.cond.Signal()
 	q.cond.L.Unlock()
@@ -0,0 +1,212 @@
+package bot
+
+import (
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAudioEvent(guildID, requesterID, title string) *AudioEvent {
+	return &AudioEvent{
+		guildID: guildID,
+		track:   Track{Title: title, RequesterID: requesterID},
+		audio:   ioutil.NopCloser(strings.NewReader("")),
+	}
+}
+
+func titles(events []*AudioEvent) []string {
+	var names []string
+
+	for _, event := range events {
+		names = append(names, event.Title())
+	}
+
+	return names
+}
+
+func TestAudioEventQueueFIFO(t *testing.T) {
+	q := NewAudioEventQueue()
+
+	q.Enqueue(testAudioEvent("g", "a", "a1"))
+	q.Enqueue(testAudioEvent("g", "a", "a2"))
+	q.Enqueue(testAudioEvent("g", "b", "b1"))
+
+	assert.Equal(t, []string{"a1", "a2", "b1"}, titles(q.Queued("g")))
+	assert.Equal(t, "a1", q.Dequeue().Title())
+}
+
+func TestAudioEventQueueFair(t *testing.T) {
+	q := NewAudioEventQueue()
+	q.fair = func(guildID string) bool { return guildID == "g" }
+
+	for _, title := range []string{"a1", "a2", "a3"} {
+		q.Enqueue(testAudioEvent("g", "a", title))
+	}
+
+	q.Enqueue(testAudioEvent("other", "a", "o1"))
+	q.Enqueue(testAudioEvent("g", "b", "b1"))
+	q.Enqueue(testAudioEvent("g", "c", "c1"))
+	q.Enqueue(testAudioEvent("g", "b", "b2"))
+
+	assert.Equal(t, []string{"a1", "b1", "c1", "a2", "b2", "a3"}, titles(q.Queued("g")))
+	assert.Equal(t, []string{"o1"}, titles(q.Queued("other")))
+
+	assert.Equal(t, "a1", q.Dequeue().Title())
+
+	// a goes to the back of the rotation, so a late requester queues behind a.
+	q.Enqueue(testAudioEvent("g", "d", "d1"))
+
+	assert.Equal(t, []string{"b1", "c1", "a2", "d1", "b2", "a3"}, titles(q.Queued("g")))
+
+	var played []string
+
+	for range []int{1, 2, 3, 4, 5, 6, 7} {
+		played = append(played, q.Dequeue().Title())
+	}
+
+	assert.Equal(t, []string{"b1", "c1", "o1", "a2", "d1", "b2", "a3"}, played)
+}
+
+func TestAudioEventQueueFairKeepsResumedEventFirst(t *testing.T) {
+	q := NewAudioEventQueue()
+	q.fair = func(string) bool { return true }
+
+	q.Enqueue(testAudioEvent("g", "a", "a1"))
+	q.Enqueue(testAudioEvent("g", "b", "b1"))
+
+	paused := q.Dequeue()
+	q.Enqueue(testAudioEvent("g", "a", "a2"))
+	q.EnqueueFront(paused)
+
+	assert.Equal(t, []string{"a1", "b1", "a2"}, titles(q.Queued("g")))
+	assert.Equal(t, "a1", q.Dequeue().Title())
+	assert.Equal(t, "b1", q.Dequeue().Title())
+}
+
+func TestAudioEventQueueConcurrent(t *testing.T) {
+	q := NewAudioEventQueue()
+	q.fair = func(string) bool { return true }
+
+	const requesters, perRequester = 8, 50
+
+	received := make(chan *AudioEvent)
+
+	go func() {
+		for i := 0; i < requesters*perRequester; i++ {
+			received <- q.Dequeue()
+		}
+	}()
+
+	var wg sync.WaitGroup
+
+	for r := 0; r < requesters; r++ {
+		wg.Add(1)
+
+		go func(requesterID string) {
+			defer wg.Done()
+
+			for i := 0; i < perRequester; i++ {
+				q.Enqueue(testAudioEvent("g", requesterID, requesterID))
+			}
+		}(string(rune('a' + r)))
+	}
+
+	counts := map[string]int{}
+	timeout := time.After(5 * time.Second)
+
+	for i := 0; i < requesters*perRequester; i++ {
+		select {
+		case event := <-received:
+			counts[event.RequesterID()]++
+
+		case <-timeout:
+			t.Fatalf("Only dequeued %d events", i)
+		}
+	}
+
+	wg.Wait()
+
+	assert.Len(t, counts, requesters)
+
+	for requesterID, count := range counts {
+		assert.Equal(t, perRequester, count, requesterID)
+	}
+
+	assert.Empty(t, q.Queued("g"))
+}
+
+func TestAudioEventQueueDequeueBlocks(t *testing.T) {
+	q := NewAudioEventQueue()
+
+	dequeued := make(chan *AudioEvent)
+
+	go func() {
+		dequeued <- q.Dequeue()
+	}()
+
+	select {
+	case <-dequeued:
+		t.Fatal("Dequeued from an empty queue")
+
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.Enqueue(testAudioEvent("g", "a", "a1"))
+
+	select {
+	case event := <-dequeued:
+		assert.Equal(t, "a1", event.Title())
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dequeue didn't wake up")
+	}
+}
+
+func TestAudioEventQueueHeld(t *testing.T) {
+	var lock sync.Mutex
+	held := map[string]bool{"g": true}
+
+	q := NewAudioEventQueue()
+	q.held = func(guildID string) bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return held[guildID]
+	}
+
+	q.Enqueue(testAudioEvent("g", "a", "a1"))
+	q.Enqueue(testAudioEvent("h", "b", "b1"))
+
+	assert.Equal(t, "b1", q.Dequeue().Title())
+
+	dequeued := make(chan *AudioEvent)
+
+	go func() {
+		dequeued <- q.Dequeue()
+	}()
+
+	select {
+	case <-dequeued:
+		t.Fatal("Dequeued a held event")
+
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	lock.Lock()
+	held["g"] = false
+	lock.Unlock()
+
+	q.Wake()
+
+	select {
+	case event := <-dequeued:
+		assert.Equal(t, "a1", event.Title())
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dequeue didn't wake up")
+	}
+}
@@ -0,0 +1,299 @@
+package bot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// maxHistory is how many plays are kept for each guild.
+const maxHistory = 1000
+
+// HistoryEntry records a track that finished playing or was skipped.
+type HistoryEntry struct {
+	Time        time.Time `json:"time"`
+	GuildID     string    `json:"guildID"`
+	Origin      string    `json:"origin"`
+	CacheKey    string    `json:"cacheKey"`
+	Title       string    `json:"title"`
+	RequesterID string    `json:"requesterID"`
+}
+
+// Track is the track that was played.
+func (e HistoryEntry) Track() Track {
+	return Track{
+		Origin:      e.Origin,
+		CacheKey:    e.CacheKey,
+		Title:       e.Title,
+		RequesterID: e.RequesterID,
+	}
+}
+
+// TrackPlays counts the plays of a track.
+type TrackPlays struct {
+	// Entry is the track's most recent play.
+	Entry HistoryEntry
+	Plays int
+}
+
+// historyBucket holds each guild's history in a bucket named by its ID.
+var historyBucket = []string{"audio", "history"}
+
+// History is a per-guild log of played tracks, kept in the store so that it
+// survives restarts. Each guild's plays are loaded the first time they're
+// needed.
+type History struct {
+	lock   sync.Mutex
+	bucket Bucket
+
+	entries map[string][]HistoryEntry
+
+	// keys are the store keys of each guild's entries.
+	keys map[string][]string
+}
+
+// NewHistory creates a History that's kept in the bucket.
+func NewHistory(bucket Bucket) *History {
+	return &History{
+		bucket:  bucket,
+		entries: map[string][]HistoryEntry{},
+		keys:    map[string][]string{},
+	}
+}
+
+// readHistoryFile reads the plays saved as JSON lines before history moved
+// into the store. A missing file means nothing was played.
+func readHistoryFile(historyPath string) ([]HistoryEntry, error) {
+	file, err := os.Open(historyPath)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var entries []HistoryEntry
+
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		var entry HistoryEntry
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.WithFields(log.Fields{
+				"topic": "voice",
+				"path":  historyPath,
+			}).WithError(err).Warn("Skipping unreadable history entry")
+
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// load reads the guild's plays from the store if they haven't been yet. The
+// caller must hold the lock.
+func (h *History) load(guildID string) error {
+	if _, ok := h.keys[guildID]; ok {
+		return nil
+	}
+
+	entries := []HistoryEntry{}
+	keys := []string{}
+
+	err := h.bucket.Bucket(guildID).ForEach(func(key string, value []byte) error {
+		var entry HistoryEntry
+
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return err
+		}
+
+		entries = append(entries, entry)
+		keys = append(keys, key)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	h.entries[guildID] = entries
+	h.keys[guildID] = keys
+
+	return nil
+}
+
+// historyKey is the store key of an entry, which orders entries by time. It's
+// made later than the last key if needed so that keys are unique.
+func historyKey(entry HistoryEntry, last string) string {
+	nanos := entry.Time.UnixNano()
+
+	// Negative keys wouldn't sort in order.
+	if nanos < 0 {
+		nanos = 0
+	}
+
+	if lastNanos, err := strconv.ParseInt(last, 10, 64); err == nil && nanos <= lastNanos {
+		nanos = lastNanos + 1
+	}
+
+	return fmt.Sprintf("%020d", nanos)
+}
+
+// Record adds the entry to its guild's history, forgetting the guild's oldest
+// plays past maxHistory.
+func (h *History) Record(entry HistoryEntry) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if err := h.load(entry.GuildID); err != nil {
+		return err
+	}
+
+	bucket := h.bucket.Bucket(entry.GuildID)
+	keys := h.keys[entry.GuildID]
+
+	last := ""
+
+	if len(keys) > 0 {
+		last = keys[len(keys)-1]
+	}
+
+	key := historyKey(entry, last)
+
+	if err := bucket.PutJSON(key, entry); err != nil {
+		return err
+	}
+
+	entries := append(h.entries[entry.GuildID], entry)
+	keys = append(keys, key)
+
+	for len(entries) > maxHistory {
+		if err := bucket.Delete(keys[0]); err != nil {
+			return err
+		}
+
+		entries, keys = entries[1:], keys[1:]
+	}
+
+	h.entries[entry.GuildID] = entries
+	h.keys[entry.GuildID] = keys
+
+	return nil
+}
+
+// loadOrLog loads the guild's plays, logging rather than returning failures
+// for callers that can carry on with what's in memory. The caller must hold
+// the lock.
+func (h *History) loadOrLog(guildID string) {
+	if err := h.load(guildID); err != nil {
+		log.WithFields(log.Fields{
+			"topic": "voice",
+			"guild": guildID,
+		}).WithError(err).Error("Couldn't load history")
+	}
+}
+
+// Recent returns up to n of the guild's plays, most recent first.
+func (h *History) Recent(guildID string, n int) []HistoryEntry {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.loadOrLog(guildID)
+
+	entries := h.entries[guildID]
+	recent := []HistoryEntry{}
+
+	for i := len(entries) - 1; i >= 0 && len(recent) < n; i-- {
+		recent = append(recent, entries[i])
+	}
+
+	return recent
+}
+
+// Top returns up to n of the guild's most played tracks, most played first.
+func (h *History) Top(guildID string, n int) []TrackPlays {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.loadOrLog(guildID)
+
+	var top []TrackPlays
+	index := map[string]int{}
+
+	for _, entry := range h.entries[guildID] {
+		i, ok := index[entry.Origin]
+
+		if !ok {
+			i = len(top)
+			index[entry.Origin] = i
+			top = append(top, TrackPlays{})
+		}
+
+		top[i].Entry = entry
+		top[i].Plays++
+	}
+
+	sort.SliceStable(top, func(i, j int) bool {
+		if top[i].Plays != top[j].Plays {
+			return top[i].Plays > top[j].Plays
+		}
+
+		return top[i].Entry.Time.After(top[j].Entry.Time)
+	})
+
+	if len(top) > n {
+		top = top[:n]
+	}
+
+	return top
+}
+
+// onTrackFinished records that the event finished playing or was skipped.
+// Events that weren't requested, such as speech, and live streams, which can't
+// be replayed from the cache, aren't recorded.
+func (a *Audio) onTrackFinished(finished *TrackFinishedEvent) {
+	event := finished.Event
+
+	if event.track.Origin == "" || event.live != nil {
+		return
+	}
+
+	entry := HistoryEntry{
+		Time:        time.Now(),
+		GuildID:     event.guildID,
+		Origin:      event.track.Origin,
+		CacheKey:    event.track.CacheKey,
+		Title:       event.track.Title,
+		RequesterID: event.track.RequesterID,
+	}
+
+	if err := a.history.Record(entry); err != nil {
+		a.bot.VoiceLog().WithError(err).Error("Couldn't record history")
+	}
+}
+
+// History provides access to the log of played tracks.
+func (a *Audio) History() *History {
+	return a.history
+}
+
+// OpenCached opens the converted audio for the key if it's in the Opus cache.
+func (a *Audio) OpenCached(key string) (*os.File, error) {
+	return os.Open(a.opusCachePath(key))
+}
@@ -0,0 +1,76 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryRecentAndTop(t *testing.T) {
+	h := NewHistory(NewBucket(NewMemoryStore(), historyBucket...))
+	start := time.Now()
+
+	for i, origin := range []string{"a", "b", "a", "c", "b", "a"} {
+		assert.NoError(t, h.Record(HistoryEntry{
+			Time:    start.Add(time.Duration(i) * time.Minute),
+			GuildID: "g",
+			Origin:  origin,
+			Title:   origin,
+		}))
+	}
+
+	assert.NoError(t, h.Record(HistoryEntry{GuildID: "other", Origin: "d"}))
+
+	var recent []string
+
+	for _, entry := range h.Recent("g", 4) {
+		recent = append(recent, entry.Origin)
+	}
+
+	assert.Equal(t, []string{"a", "b", "c", "a"}, recent)
+	assert.Len(t, h.Recent("g", 100), 6)
+	assert.Empty(t, h.Recent("none", 10))
+
+	top := h.Top("g", 2)
+
+	assert.Len(t, top, 2)
+	assert.Equal(t, "a", top[0].Entry.Origin)
+	assert.Equal(t, 3, top[0].Plays)
+	assert.Equal(t, start.Add(5*time.Minute), top[0].Entry.Time)
+	assert.Equal(t, "b", top[1].Entry.Origin)
+	assert.Equal(t, 2, top[1].Plays)
+}
+
+func TestHistoryReload(t *testing.T) {
+	store := NewMemoryStore()
+
+	h := NewHistory(NewBucket(store, historyBucket...))
+
+	assert.NoError(t, h.Record(HistoryEntry{GuildID: "g", Origin: "a", CacheKey: "a"}))
+	assert.NoError(t, h.Record(HistoryEntry{GuildID: "g", Origin: "b", CacheKey: "b"}))
+
+	reloaded := NewHistory(NewBucket(store, historyBucket...))
+
+	assert.Equal(t, h.Recent("g", 10), reloaded.Recent("g", 10))
+}
+
+func TestHistoryTrimsOldest(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewHistory(NewBucket(store, historyBucket...))
+	start := time.Now()
+
+	for i := 0; i < maxHistory+5; i++ {
+		assert.NoError(t, h.Record(HistoryEntry{
+			Time:    start.Add(time.Duration(i) * time.Second),
+			GuildID: "g",
+			Origin:  "a",
+		}))
+	}
+
+	reloaded := NewHistory(NewBucket(store, historyBucket...))
+	recent := reloaded.Recent("g", 2*maxHistory)
+
+	assert.Len(t, recent, maxHistory)
+	assert.Equal(t, start.Add(5*time.Second).UnixNano(), recent[len(recent)-1].Time.UnixNano())
+}
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strings"
@@ -10,13 +11,22 @@ type AudioMetadata struct {
 	Origin   string
 	AudioURL string
 	Title    string
+
+	// Live is whether youtube-dl says the audio is a live broadcast.
+	Live bool
 }
 
-func GetAudioMetadata(url string) (*AudioMetadata, error) {
-	out, err := exec.Command(
+// GetAudioMetadata asks youtube-dl about the audio at the URL, killing it if
+// the context is cancelled first.
+func GetAudioMetadata(ctx context.Context, url string) (*AudioMetadata, error) {
+	out, err := exec.CommandContext(
+		ctx,
 		"youtube-dl",
 		"--get-title",
 		"--get-url",
+		"--get-filename",
+		"--output",
+		"%(is_live)s",
 		"--format",
 		"bestaudio",
 		url,
@@ -31,13 +41,52 @@ func GetAudioMetadata(url string) (*AudioMetadata, error) {
 	trimmed := strings.TrimSpace(string(out))
 	components := strings.Split(trimmed, "\n")
 
-	if len(components) != 2 {
-		return audio, fmt.Errorf("Expected two components (title, url) got: %+v", components)
+	if len(components) != 3 {
+		return audio, fmt.Errorf("Expected three components (title, url, is_live) got: %+v", components)
 	}
 
 	audio.Origin = url
 	audio.Title = components[0]
 	audio.AudioURL = components[1]
+	audio.Live = components[2] == "True"
 
 	return audio, nil
 }
+
+// RelatedAudioMetadata searches for audio related to the title, skipping the
+// results whose origins are excluded.
+func RelatedAudioMetadata(ctx context.Context, title string, exclude map[string]bool) (*AudioMetadata, error) {
+	out, err := exec.CommandContext(
+		ctx,
+		"youtube-dl",
+		"--get-title",
+		"--get-id",
+		"--get-url",
+		"--format",
+		"bestaudio",
+		"ytsearch5:"+title,
+	).Output()
+
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+
+	// Each result is printed as its title, ID and URL.
+	for i := 0; i+2 < len(lines); i += 3 {
+		origin := "https://www.youtube.com/watch?v=" + lines[i+1]
+
+		if exclude[origin] {
+			continue
+		}
+
+		return &AudioMetadata{
+			Origin:   origin,
+			Title:    lines[i],
+			AudioURL: lines[i+2],
+		}, nil
+	}
+
+	return nil, fmt.Errorf("No related audio for %q", title)
+}
@@ -0,0 +1,211 @@
+package bot
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// opusFrameBytes is the size of each Opus frame in the cache.
+	opusFrameBytes = 320
+
+	// persistInterval is how many frames are sent between saving the playing
+	// event's offset, which is about five seconds.
+	persistInterval = 250
+)
+
+// QueueRecord is the serializable form of a queued or playing event.
+type QueueRecord struct {
+	Origin      string `json:"origin"`
+	CacheKey    string `json:"cacheKey"`
+	Title       string `json:"title"`
+	GuildID     string `json:"guildID"`
+	ChannelID   string `json:"channelID"`
+	RequesterID string `json:"requesterID"`
+
+	// Offset is the number of Opus frames that were already played.
+	Offset int64 `json:"offset"`
+}
+
+func (e *AudioEvent) record() QueueRecord {
+	return QueueRecord{
+		Origin:      e.track.Origin,
+		CacheKey:    e.track.CacheKey,
+		Title:       e.track.Title,
+		GuildID:     e.guildID,
+		ChannelID:   e.voiceChannelID,
+		RequesterID: e.track.RequesterID,
+		Offset:      atomic.LoadInt64(&e.offset),
+	}
+}
+
+// queueRecords lists the records of the playing event followed by the queued
+// events. Events that can't be restored are left out.
+func (a *Audio) queueRecords() []QueueRecord {
+	a.stateCond.L.Lock()
+	playing := a.nowPlaying
+	a.stateCond.L.Unlock()
+
+	events := a.queue.Events()
+
+	if playing != nil {
+		// A paused event is put back in the queue before it stops playing.
+		for _, event := range events {
+			if event == playing {
+				playing = nil
+				break
+			}
+		}
+	}
+
+	if playing != nil {
+		events = append([]*AudioEvent{playing}, events...)
+	}
+
+	records := []QueueRecord{}
+
+	for _, event := range events {
+		if event.track.CacheKey != "" {
+			records = append(records, event.record())
+		}
+	}
+
+	return records
+}
+
+// queueBucket holds the queue's records.
+var queueBucket = []string{"audio", "queue"}
+
+// persist saves the queue in the store so that it can be restored after a
+// restart.
+func (a *Audio) persist() {
+	if a.queueStore == nil {
+		return
+	}
+
+	a.persistLock.Lock()
+	defer a.persistLock.Unlock()
+
+	// Don't overwrite the saved queue before it's been restored.
+	if !a.restored {
+		return
+	}
+
+	if err := NewBucket(a.queueStore, queueBucket...).PutJSON("records", a.queueRecords()); err != nil {
+		a.bot.VoiceLog().WithError(err).Error("Couldn't save queue")
+	}
+}
+
+// readQueueRecords reads the records saved at the path before the queue moved
+// into the store. A missing file means nothing was queued.
+func readQueueRecords(queuePath string) ([]QueueRecord, error) {
+	data, err := ioutil.ReadFile(queuePath)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var records []QueueRecord
+
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// eventFromRecord reopens the record's cached audio, seeking to where it left
+// off if resume is set.
+func (a *Audio) eventFromRecord(record QueueRecord, resume bool) (*AudioEvent, error) {
+	file, err := a.OpenCached(record.CacheKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event := &AudioEvent{
+		guildID:        record.GuildID,
+		voiceChannelID: record.ChannelID,
+		audio:          file,
+		track: Track{
+			Origin:      record.Origin,
+			CacheKey:    record.CacheKey,
+			Title:       record.Title,
+			RequesterID: record.RequesterID,
+		},
+	}
+
+	if resume && record.Offset > 0 {
+		if _, err := file.Seek(record.Offset*opusFrameBytes, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		event.offset = record.Offset
+	}
+
+	return event, nil
+}
+
+// RestoreQueue queues the events that were saved before the last shutdown.
+// The event that was playing comes first and, if resuming is enabled, picks up
+// at its saved offset. Playing them rejoins their voice channels.
+func (a *Audio) RestoreQueue() {
+	if a.queueStore == nil {
+		return
+	}
+
+	logger := a.bot.VoiceLog()
+
+	var records []QueueRecord
+
+	err := NewBucket(a.queueStore, queueBucket...).GetJSON("records", &records)
+
+	if err != nil && err != ErrNotFound {
+		logger.WithError(err).Error("Couldn't read saved queue")
+
+		// The saved queue is lost either way, so don't stop saving new ones.
+		a.persistLock.Lock()
+		a.restored = true
+		a.persistLock.Unlock()
+
+		return
+	}
+
+	resume := a.bot.Config().Voice.ResumePlayback
+	restored := 0
+
+	for i, record := range records {
+		event, err := a.eventFromRecord(record, resume && i == 0)
+
+		if err != nil {
+			logger.WithFields(log.Fields{
+				"origin": record.Origin,
+				"guild":  record.GuildID,
+			}).WithError(err).Warn("Couldn't restore queued track")
+
+			continue
+		}
+
+		a.queue.Enqueue(event)
+		a.bot.bus.Publish(&TrackQueuedEvent{Event: event})
+		restored++
+	}
+
+	logger.WithField("count", restored).Info("Restored queue")
+
+	a.persistLock.Lock()
+	a.restored = true
+	a.persistLock.Unlock()
+
+	a.persist()
+}
@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueRecords(t *testing.T) {
+	a := &Audio{
+		stateCond: sync.NewCond(new(sync.Mutex)),
+		queue:     NewAudioEventQueue(),
+	}
+
+	playing := testAudioEvent("g", "a", "playing")
+	playing.track.CacheKey = "playing"
+	playing.voiceChannelID = "c"
+	playing.offset = 42
+
+	queued := testAudioEvent("g", "b", "queued")
+	queued.track.CacheKey = "queued"
+
+	a.nowPlaying = playing
+	a.queue.Enqueue(queued)
+	a.queue.Enqueue(testAudioEvent("g", "", "speech"))
+
+	records := a.queueRecords()
+
+	assert.Equal(t, []QueueRecord{
+		{CacheKey: "playing", Title: "playing", GuildID: "g", ChannelID: "c", RequesterID: "a", Offset: 42},
+		{CacheKey: "queued", Title: "queued", GuildID: "g", RequesterID: "b"},
+	}, records)
+
+	// A paused event is back in the queue while it's still the one playing.
+	a.queue.EnqueueFront(playing)
+
+	assert.Len(t, a.queueRecords(), 2)
+}
+
+func TestReadQueueRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bmo")
+
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	queuePath := path.Join(dir, "queue.json")
+
+	records, err := readQueueRecords(queuePath)
+
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	data := `[{"origin": "https://example.com", "cacheKey": "k", "guildID": "g", "offset": 3}]`
+
+	assert.NoError(t, ioutil.WriteFile(queuePath, []byte(data), 0644))
+
+	records, err = readQueueRecords(queuePath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []QueueRecord{{Origin: "https://example.com", CacheKey: "k", GuildID: "g", Offset: 3}}, records)
+}
+
+func TestRestoreUnreadableQueue(t *testing.T) {
+	b, err := New(DefaultConfig(), NewMemoryStore())
+
+	require.NoError(t, err)
+
+	bucket := NewBucket(b.store, queueBucket...)
+
+	require.NoError(t, bucket.Put("records", []byte("not json")))
+
+	b.audio.RestoreQueue()
+
+	// The queue is still saved afterwards.
+	queued := testAudioEvent("g", "a", "queued")
+	queued.track.CacheKey = "queued"
+
+	b.audio.queue.Enqueue(queued)
+	b.audio.persist()
+
+	var records []QueueRecord
+
+	require.NoError(t, bucket.GetJSON("records", &records))
+	assert.Equal(t, []QueueRecord{{CacheKey: "queued", Title: "queued", GuildID: "g", RequesterID: "a"}}, records)
+}
@@ -0,0 +1,435 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// maxStreamReconnects is how many times in a row a dropped live stream is
+	// reconnected before giving up.
+	maxStreamReconnects = 5
+
+	// streamReconnectDelay is how long to wait before reconnecting, multiplied
+	// by the number of attempts so far.
+	streamReconnectDelay = 2 * time.Second
+)
+
+// streamClient fetches live streams. The body is read for as long as the
+// stream plays, so only connecting is subject to a timeout.
+var streamClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 10 * time.Second,
+	},
+}
+
+// IsLiveURL checks whether the audio URL is a live HLS playlist, which can't be
+// converted into the cache.
+func IsLiveURL(audioURL string) bool {
+	parsed, err := url.Parse(audioURL)
+
+	return err == nil && strings.HasSuffix(parsed.Path, ".m3u8")
+}
+
+// endlessContentTypes are the content types Icecast and SHOUTcast serve
+// streams as.
+var endlessContentTypes = []string{"audio/mpeg", "audio/aac", "audio/aacp"}
+
+// IsLiveStream checks whether the audio is a live stream, which never finishes
+// converting into the cache. youtube-dl recognizes some of them, and the rest
+// are recognized by their URL or by how the server responds to them.
+func IsLiveStream(ctx context.Context, meta *AudioMetadata) bool {
+	return meta.Live || IsLiveURL(meta.AudioURL) || probeLiveStream(ctx, meta.AudioURL)
+}
+
+// probeLiveStream checks whether the server responds to the URL like an
+// Icecast or SHOUTcast stream, with ICY headers or with audio of no particular
+// length.
+func probeLiveStream(ctx context.Context, audioURL string) bool {
+	request, err := http.NewRequest("GET", audioURL, nil)
+
+	if err != nil {
+		return false
+	}
+
+	// Stream servers don't all answer HEAD, so ask for the first byte instead.
+	// Files are then served with a length, while streams ignore the range.
+	request = request.WithContext(ctx)
+	request.Header.Set("Icy-MetaData", "1")
+	request.Header.Set("Range", "bytes=0-0")
+
+	response, err := streamClient.Do(request)
+
+	if err != nil {
+		return false
+	}
+
+	// Only the headers matter, and a stream's body never ends.
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusPartialContent {
+		return false
+	}
+
+	for name := range response.Header {
+		if strings.HasPrefix(strings.ToLower(name), "icy-") {
+			return true
+		}
+	}
+
+	if response.ContentLength >= 0 {
+		return false
+	}
+
+	contentType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+
+	for _, endless := range endlessContentTypes {
+		if contentType == endless {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseStreamTitle extracts the title from ICY metadata, which looks like
+// StreamTitle='Artist - Song';StreamUrl='https://example.com';
+func parseStreamTitle(metadata string) (string, bool) {
+	const key = "StreamTitle='"
+
+	start := strings.Index(metadata, key)
+
+	if start < 0 {
+		return "", false
+	}
+
+	rest := metadata[start+len(key):]
+	end := strings.Index(rest, "';")
+
+	if end < 0 {
+		end = strings.LastIndex(rest, "'")
+	}
+
+	if end < 0 {
+		return "", false
+	}
+
+	return rest[:end], true
+}
+
+// icyReader strips the ICY metadata that's interleaved with the audio every
+// metaint bytes, reporting the stream titles it contains.
+type icyReader struct {
+	reader    io.Reader
+	metaint   int
+	remaining int
+	onTitle   func(string)
+}
+
+func newICYReader(reader io.Reader, metaint int, onTitle func(string)) *icyReader {
+	return &icyReader{
+		reader:    reader,
+		metaint:   metaint,
+		remaining: metaint,
+		onTitle:   onTitle,
+	}
+}
+
+func (r *icyReader) Read(p []byte) (int, error) {
+	if r.metaint <= 0 {
+		return r.reader.Read(p)
+	}
+
+	if r.remaining == 0 {
+		if err := r.readMetadata(); err != nil {
+			return 0, err
+		}
+
+		r.remaining = r.metaint
+	}
+
+	if len(p) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.reader.Read(p)
+	r.remaining -= n
+
+	return n, err
+}
+
+// readMetadata reads a metadata block, which is a byte giving its length in
+// units of 16 bytes followed by the null-padded metadata.
+func (r *icyReader) readMetadata() error {
+	var length [1]byte
+
+	if _, err := io.ReadFull(r.reader, length[:]); err != nil {
+		return err
+	}
+
+	if length[0] == 0 {
+		return nil
+	}
+
+	metadata := make([]byte, int(length[0])*16)
+
+	if _, err := io.ReadFull(r.reader, metadata); err != nil {
+		return err
+	}
+
+	if title, ok := parseStreamTitle(strings.TrimRight(string(metadata), "\x00")); ok && r.onTitle != nil {
+		r.onTitle(title)
+	}
+
+	return nil
+}
+
+// streamSource reads the audio of an Icecast or SHOUTcast stream, reconnecting
+// when the stream drops.
+type streamSource struct {
+	url     string
+	onTitle func(string)
+	logger  *log.Entry
+
+	lock   sync.Mutex
+	body   io.ReadCloser
+	reader io.Reader
+	closed bool
+}
+
+func (s *streamSource) connect() error {
+	request, err := http.NewRequest("GET", s.url, nil)
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Icy-MetaData", "1")
+
+	response, err := streamClient.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return fmt.Errorf("Stream responded with %s", response.Status)
+	}
+
+	metaint, _ := strconv.Atoi(response.Header.Get("Icy-Metaint"))
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		response.Body.Close()
+		return io.EOF
+	}
+
+	s.body = response.Body
+	s.reader = newICYReader(response.Body, metaint, s.onTitle)
+
+	return nil
+}
+
+func (s *streamSource) Read(p []byte) (int, error) {
+	for attempt := 0; ; attempt++ {
+		s.lock.Lock()
+		reader, closed := s.reader, s.closed
+		s.lock.Unlock()
+
+		if closed {
+			return 0, io.EOF
+		}
+
+		if reader != nil {
+			n, err := reader.Read(p)
+
+			if err == nil || n > 0 {
+				return n, nil
+			}
+
+			s.logger.WithError(err).Warn("Live stream dropped")
+
+			s.lock.Lock()
+			s.body.Close()
+			s.reader = nil
+			s.lock.Unlock()
+		}
+
+		if attempt == maxStreamReconnects {
+			return 0, fmt.Errorf("Gave up reconnecting to %s", s.url)
+		}
+
+		time.Sleep(time.Duration(attempt) * streamReconnectDelay)
+
+		if err := s.connect(); err != nil {
+			s.logger.WithError(err).Warn("Couldn't connect to live stream")
+		}
+	}
+}
+
+// Close stops reading the stream and keeps it from reconnecting.
+func (s *streamSource) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+
+	if s.body != nil {
+		return s.body.Close()
+	}
+
+	return nil
+}
+
+// LiveStream is the Opus audio of a live stream, encoded by ffmpeg as it's
+// played instead of being converted into the cache first. It only connects
+// once it's first read, so that queued streams don't buffer.
+type LiveStream struct {
+	ctx    context.Context
+	url    string
+	logger *log.Entry
+
+	startOnce sync.Once
+	startErr  error
+	closeOnce sync.Once
+
+	source *streamSource
+	ffmpeg *exec.Cmd
+	output io.ReadCloser
+
+	titleLock sync.Mutex
+	title     string
+}
+
+// NewLiveStream creates a LiveStream for the URL. Its ffmpeg is stopped when
+// the context is cancelled.
+func NewLiveStream(ctx context.Context, streamURL string, logger *log.Entry) *LiveStream {
+	return &LiveStream{
+		ctx:    ctx,
+		url:    streamURL,
+		logger: logger.WithField("stream", streamURL),
+	}
+}
+
+// Title is what the stream says it's currently playing, if anything.
+func (s *LiveStream) Title() string {
+	s.titleLock.Lock()
+	defer s.titleLock.Unlock()
+
+	return s.title
+}
+
+func (s *LiveStream) setTitle(title string) {
+	s.titleLock.Lock()
+	changed := s.title != title
+	s.title = title
+	s.titleLock.Unlock()
+
+	if changed {
+		s.logger.WithField("title", title).Info("Live stream title changed")
+	}
+}
+
+func (s *LiveStream) start() error {
+	var input []string
+
+	if IsLiveURL(s.url) {
+		// ffmpeg reads HLS playlists itself, so let it reconnect too.
+		input = []string{
+			"-reconnect", "1",
+			"-reconnect_streamed", "1",
+			"-reconnect_delay_max", "5",
+			"-i", s.url,
+		}
+	} else {
+		s.source = &streamSource{
+			url:     s.url,
+			onTitle: s.setTitle,
+			logger:  s.logger,
+		}
+
+		input = []string{"-i", "pipe:0"}
+	}
+
+	s.ffmpeg = exec.CommandContext(s.ctx, "ffmpeg", append(input, opusEncodingArgs("pipe:1")...)...)
+
+	if s.source != nil {
+		s.ffmpeg.Stdin = s.source
+	}
+
+	output, err := s.ffmpeg.StdoutPipe()
+
+	if err != nil {
+		return err
+	}
+
+	s.output = output
+
+	s.logger.Info("Starting live stream")
+
+	return s.ffmpeg.Start()
+}
+
+func (s *LiveStream) Read(p []byte) (int, error) {
+	s.startOnce.Do(func() {
+		s.startErr = s.start()
+	})
+
+	if s.startErr != nil {
+		return 0, s.startErr
+	}
+
+	return s.output.Read(p)
+}
+
+// Close stops the stream and ffmpeg.
+func (s *LiveStream) Close() error {
+	s.closeOnce.Do(func() {
+		// Keep a stream that's never been read from starting afterwards.
+		s.startOnce.Do(func() {
+			s.startErr = io.EOF
+		})
+
+		if s.source != nil {
+			s.source.Close()
+		}
+
+		if s.ffmpeg != nil && s.ffmpeg.Process != nil {
+			s.ffmpeg.Process.Kill()
+			s.ffmpeg.Wait()
+		}
+	})
+
+	return nil
+}
+
+// EnqueueLiveStream enqueues the live stream at the URL. It's played as it's
+// received rather than being cached, so it can't be restored after a restart.
+func (a *Audio) EnqueueLiveStream(guildID, voiceChannelID string, track Track, streamURL string) {
+	track.CacheKey = ""
+
+	stream := NewLiveStream(a.bot.Context(), streamURL, a.bot.VoiceLog())
+
+	a.enqueue(&AudioEvent{
+		guildID:        guildID,
+		voiceChannelID: voiceChannelID,
+		audio:          stream,
+		track:          track,
+		live:           stream,
+	})
+}
@@ -0,0 +1,121 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func icyMetadataBlock(metadata string) []byte {
+	length := (len(metadata) + 15) / 16
+	block := make([]byte, 1+length*16)
+
+	block[0] = byte(length)
+	copy(block[1:], metadata)
+
+	return block
+}
+
+func TestICYReader(t *testing.T) {
+	var stream bytes.Buffer
+
+	stream.WriteString("abcd")
+	stream.Write(icyMetadataBlock("StreamTitle='First Song';StreamUrl='';"))
+	stream.WriteString("efgh")
+	stream.Write([]byte{0})
+	stream.WriteString("ij")
+
+	var titles []string
+
+	reader := newICYReader(&stream, 4, func(title string) {
+		titles = append(titles, title)
+	})
+
+	audio, err := ioutil.ReadAll(reader)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "abcdefghij", string(audio))
+	assert.Equal(t, []string{"First Song"}, titles)
+}
+
+func TestICYReaderWithoutMetadata(t *testing.T) {
+	audio, err := ioutil.ReadAll(newICYReader(strings.NewReader("abcdef"), 0, nil))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "abcdef", string(audio))
+}
+
+func TestParseStreamTitle(t *testing.T) {
+	title, ok := parseStreamTitle("StreamTitle='It's Here';StreamUrl='';")
+
+	assert.True(t, ok)
+	assert.Equal(t, "It's Here", title)
+
+	title, ok = parseStreamTitle("StreamTitle='Unterminated'")
+
+	assert.True(t, ok)
+	assert.Equal(t, "Unterminated", title)
+
+	_, ok = parseStreamTitle("StreamUrl='';")
+
+	assert.False(t, ok)
+}
+
+func TestIsLiveURL(t *testing.T) {
+	assert.True(t, IsLiveURL("https://example.com/live/index.m3u8?token=x"))
+	assert.False(t, IsLiveURL("https://example.com/stream.mp3"))
+	assert.False(t, IsLiveURL("https://example.com/watch?v=m3u8"))
+}
+
+func TestIsLiveStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/icecast":
+			w.Header().Set("Icy-Metaint", "16000")
+			w.Header().Set("Content-Type", "audio/mpeg")
+
+		case "/shoutcast":
+			w.Header().Set("Content-Type", "audio/aacp")
+
+		case "/error":
+			w.Header().Set("Icy-Metaint", "16000")
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+		case "/song.mp3":
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.Write(make([]byte, 1024))
+
+			return
+
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		// Streams never end, and don't say how long they are.
+		w.Write(make([]byte, 1024))
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+	}))
+
+	defer server.Close()
+
+	ctx := context.Background()
+
+	assert.True(t, IsLiveStream(ctx, &AudioMetadata{AudioURL: server.URL + "/icecast"}))
+	assert.True(t, IsLiveStream(ctx, &AudioMetadata{AudioURL: server.URL + "/shoutcast"}))
+	assert.True(t, IsLiveStream(ctx, &AudioMetadata{AudioURL: server.URL + "/live.m3u8"}))
+	assert.True(t, IsLiveStream(ctx, &AudioMetadata{AudioURL: server.URL + "/missing", Live: true}))
+
+	assert.False(t, IsLiveStream(ctx, &AudioMetadata{AudioURL: server.URL + "/song.mp3"}))
+	assert.False(t, IsLiveStream(ctx, &AudioMetadata{AudioURL: server.URL + "/missing"}))
+	assert.False(t, IsLiveStream(ctx, &AudioMetadata{AudioURL: server.URL + "/error"}))
+}
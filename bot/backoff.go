@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// maxSendRetries is how many times a rate limited message is sent again
+	// before giving up.
+	maxSendRetries = 3
+
+	// maxBackoff is the longest the bot waits out a rate limit before sending a
+	// message. Messages that would wait longer are dropped.
+	maxBackoff = 30 * time.Second
+)
+
+// backoffSession is a Session that backs off sending messages to a channel
+// when Discord responds that the bot is sending too many. Messages sent to the
+// channel while it's backing off wait until it's over, rather than making the
+// rate limit worse.
+type backoffSession struct {
+	Session
+
+	lock sync.Mutex
+
+	// until are when each channel's backoff ends.
+	until map[string]time.Time
+
+	// sleep waits for the duration. Tests replace it.
+	sleep func(time.Duration)
+}
+
+func newBackoffSession(session Session) *backoffSession {
+	return &backoffSession{
+		Session: session,
+		until:   map[string]time.Time{},
+		sleep:   time.Sleep,
+	}
+}
+
+// retryAfter is how long the error says to wait before sending again, if it's
+// a rate limit error.
+func retryAfter(err error) (time.Duration, bool) {
+	switch err := err.(type) {
+	case *discordgo.RateLimitError:
+		return err.RetryAfter, true
+
+	case discordgo.RateLimitError:
+		return err.RetryAfter, true
+	}
+
+	return 0, false
+}
+
+// backoff records that the channel's messages must wait for the duration.
+func (s *backoffSession) backoff(channelID string, wait time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if until := time.Now().Add(wait); until.After(s.until[channelID]) {
+		s.until[channelID] = until
+	}
+}
+
+// wait is how long until the channel's backoff is over.
+func (s *backoffSession) wait(channelID string) time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	until, ok := s.until[channelID]
+
+	if !ok {
+		return 0
+	}
+
+	wait := time.Until(until)
+
+	if wait <= 0 {
+		delete(s.until, channelID)
+		return 0
+	}
+
+	return wait
+}
+
+// send sends a message to the channel, waiting out the channel's backoff and
+// retrying if the message is rate limited.
+func (s *backoffSession) send(channelID string, send func() (*discordgo.Message, error)) (*discordgo.Message, error) {
+	logger := log.WithFields(log.Fields{
+		"topic":   "session",
+		"channel": channelID,
+	})
+
+	for attempt := 0; ; attempt++ {
+		if wait := s.wait(channelID); wait > 0 {
+			if wait > maxBackoff {
+				return nil, &BackoffError{Wait: wait}
+			}
+
+			s.sleep(wait)
+		}
+
+		message, err := send()
+
+		wait, limited := retryAfter(err)
+
+		if !limited {
+			return message, err
+		}
+
+		logger.WithField("retry_after", wait).Warn("Message was rate limited")
+
+		s.backoff(channelID, wait)
+
+		if attempt == maxSendRetries {
+			return nil, err
+		}
+	}
+}
+
+// BackoffError is returned instead of sending a message to a channel that the
+// bot has to wait too long to send to.
+type BackoffError struct {
+	Wait time.Duration
+}
+
+func (e *BackoffError) Error() string {
+	return "Backing off sending messages for " + e.Wait.String()
+}
+
+func (s *backoffSession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	return s.send(channelID, func() (*discordgo.Message, error) {
+		return s.Session.ChannelMessageSend(channelID, content)
+	})
+}
+
+func (s *backoffSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return s.send(channelID, func() (*discordgo.Message, error) {
+		return s.Session.ChannelMessageSendEmbed(channelID, embed)
+	})
+}
+
+func (s *backoffSession) ChannelMessageSendReply(channelID, content string, reference *discordgo.MessageReference) (*discordgo.Message, error) {
+	return s.send(channelID, func() (*discordgo.Message, error) {
+		return s.Session.ChannelMessageSendReply(channelID, content, reference)
+	})
+}
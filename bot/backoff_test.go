@@ -0,0 +1,79 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+// limitedSession rate limits the first messages it's sent.
+type limitedSession struct {
+	Session
+
+	limited int
+	sent    []string
+}
+
+func (s *limitedSession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	if s.limited > 0 {
+		s.limited--
+
+		return nil, &discordgo.RateLimitError{RateLimit: &discordgo.RateLimit{
+			TooManyRequests: &discordgo.TooManyRequests{RetryAfter: time.Second},
+		}}
+	}
+
+	s.sent = append(s.sent, content)
+
+	return &discordgo.Message{ChannelID: channelID, Content: content}, nil
+}
+
+func TestBackoffRetriesRateLimitedMessages(t *testing.T) {
+	limited := &limitedSession{limited: 2}
+	session := newBackoffSession(limited)
+
+	var slept []time.Duration
+
+	session.sleep = func(wait time.Duration) { slept = append(slept, wait) }
+
+	message, err := session.ChannelMessageSend("channel", "hello")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", message.Content)
+	assert.Equal(t, []string{"hello"}, limited.sent)
+	assert.Len(t, slept, 2)
+
+	// Other messages to the channel wait for the backoff too.
+	session.ChannelMessageSend("channel", "again")
+
+	assert.Len(t, slept, 3)
+
+	session.ChannelMessageSend("other", "elsewhere")
+
+	assert.Len(t, slept, 3)
+}
+
+func TestBackoffGivesUp(t *testing.T) {
+	limited := &limitedSession{limited: maxSendRetries + 1}
+	session := newBackoffSession(limited)
+	session.sleep = func(time.Duration) {}
+
+	_, err := session.ChannelMessageSend("channel", "hello")
+
+	_, ok := retryAfter(err)
+
+	assert.True(t, ok)
+	assert.Empty(t, limited.sent)
+
+	session.backoff("channel", time.Hour)
+
+	_, err = session.ChannelMessageSend("channel", "hello")
+
+	var backoffErr *BackoffError
+
+	assert.True(t, errors.As(err, &backoffErr))
+	assert.Empty(t, limited.sent)
+}
@@ -1,6 +1,7 @@
 package bot
 
 import (
+	"context"
 	"crypto/sha1"
 	"fmt"
 	"io/ioutil"
@@ -35,13 +36,48 @@ type Bot struct {
 	ownerID         string
 	userID          string
 	ivonaClient     *ivona.Ivona
-	session         *discordgo.Session
+	session         Session
 	voiceStateCache map[string]map[string]*discordgo.VoiceState
+	settings        *Settings
+	store           Store
+	bus             *Bus
 
-	commands   []Commander
-	previewers []Previewer
+	configLock sync.RWMutex
+	config     *Config
 
-	audio *Audio
+	router     *Router
+	commands   []namedCommander
+	previewers []namedPreviewer
+
+	// messages are the recently received messages, for handling edits and
+	// deletes.
+	messages *messageRecords
+
+	rateLimiter *rateLimiter
+	outbox      *outbox
+
+	// plugins are every registered CommandSet, Commander and Previewer, in the
+	// order they were registered.
+	plugins   []plugin
+	readyOnce sync.Once
+
+	// initialized is how many plugins were initialized, which are the ones
+	// that are shut down.
+	initialized int
+
+	closeOnce  sync.Once
+	closeError error
+
+	// context is cancelled when the bot closes, cancelling the jobs the
+	// workers run.
+	context context.Context
+	cancel  context.CancelFunc
+	jobs    *jobQueue
+	workers sync.WaitGroup
+
+	audio     *Audio
+	readAloud *readAloud
+	presence  *presenceDebouncer
 
 	sessionLog *log.Entry
 	chatLog    *log.Entry
@@ -49,15 +85,35 @@ type Bot struct {
 	embedLog   *log.Entry
 }
 
-// New creates a new Bot.
-func New() *Bot {
+// New creates a new Bot with the configuration, keeping its state in the
+// store. The store is migrated to the latest schema first.
+func New(config *Config, store Store) (*Bot, error) {
+	if err := Migrate(store, migrations(config)); err != nil {
+		return nil, err
+	}
+
+	settings, err := NewSettings(store)
+
+	if err != nil {
+		return nil, err
+	}
+
 	bot := &Bot{
 		// voiceStateCache is a map of GuildIDs to a voiceStateCache which is itself
 		// a map of UserIDs to their VoiceState. This mainly facilitates detecting
 		// when a user leaves or enters a channel.
 		voiceStateCache: map[string]map[string]*discordgo.VoiceState{},
 
-		ivonaClient: ivona.New(os.Getenv("IVONA_ACCESS_KEY"), os.Getenv("IVONA_SECRET_KEY")),
+		settings: settings,
+		store:    store,
+		bus:      NewBus(),
+		router:   NewRouter(),
+		config:   config,
+		messages: newMessageRecords(),
+
+		rateLimiter: newRateLimiter(),
+
+		ivonaClient: ivona.New(config.Ivona.AccessKey, config.Ivona.SecretKey),
 
 		sessionLog: log.WithField("topic", "session"),
 		chatLog:    log.WithField("topic", "chat"),
@@ -66,8 +122,13 @@ func New() *Bot {
 	}
 
 	bot.audio = NewAudio(bot)
+	bot.readAloud = newReadAloud(bot)
+	bot.presence = newPresenceDebouncer(bot)
+	bot.outbox = newOutbox(bot)
+
+	bot.bus.Subscribe(bot.onPreviewSent)
 
-	return bot
+	return bot, nil
 }
 
 // EmbedLog is an embed-specific log.
@@ -81,7 +142,7 @@ func (b *Bot) VoiceLog() *log.Entry {
 }
 
 // Session provides access to the underlying Discord session.
-func (b *Bot) Session() *discordgo.Session {
+func (b *Bot) Session() Session {
 	return b.session
 }
 
@@ -89,21 +150,63 @@ func (b *Bot) Audio() *Audio {
 	return b.audio
 }
 
-// Close closes the Discord session.
+// Settings provides access to the per-guild and per-user settings.
+func (b *Bot) Settings() *Settings {
+	return b.settings
+}
+
+// Close cancels running commands and previews, shuts down the plugins, then
+// closes the Discord session and the store. Only the first call does anything.
 func (b *Bot) Close() error {
-	return b.session.Close()
+	b.closeOnce.Do(func() {
+		b.stopWorkers()
+
+		b.closeError = b.shutdownPlugins()
+
+		if b.session != nil {
+			if err := b.session.Close(); b.closeError == nil {
+				b.closeError = err
+			}
+		}
+
+		if err := b.store.Close(); b.closeError == nil {
+			b.closeError = err
+		}
+	})
+
+	return b.closeError
 }
 
-// Open opens the Discord session.
+// Open connects to Discord.
 func (b *Bot) Open() error {
-	var err error
-
-	b.session, err = discordgo.New("Bot " + os.Getenv("DISCORD_TOKEN"))
+	session, err := NewDiscordSession(b.Config().Discord.Token)
 
 	if err != nil {
-		b.sessionLog.WithError(err).Fatal("Couldn't establish a Discord session")
+		return fmt.Errorf("Couldn't establish a Discord session: %s", err)
+	}
+
+	return b.OpenSession(session)
+}
+
+// OpenSession initializes the plugins and opens the session, which tests may
+// fake.
+func (b *Bot) OpenSession(session Session) error {
+	// Commands and previewers are registered after the config is loaded, so
+	// references to them can only be checked now.
+	if err := b.checkNames(b.Config()); err != nil {
+		return err
 	}
 
+	// Messages are sent through the backoff so that a flood of them doesn't get
+	// the bot rate limited further.
+	b.session = newBackoffSession(session)
+
+	if err := b.initPlugins(); err != nil {
+		return err
+	}
+
+	b.startWorkers(b.Config().Workers.Count, b.Config().Workers.Queue)
+
 	b.registerHandlers()
 
 	go b.audio.ProcessAudioEventQueue()
@@ -115,18 +218,48 @@ func (b *Bot) registerHandlers() {
 	b.session.AddHandler(b.onReady)
 	b.session.AddHandler(b.onMessageUpdate)
 	b.session.AddHandler(b.onMessageCreate)
+	b.session.AddHandler(b.onMessageDelete)
+	b.session.AddHandler(b.onMessageDeleteBulk)
 	b.session.AddHandler(b.onVoiceStateUpdate)
+	b.session.AddHandler(b.onInteractionCreate)
 }
 
 // RegisterCommand registers a Bot command that follows the Commander interface.
-func (b *Bot) RegisterCommand(command Commander) {
-	b.commands = append(b.commands, command)
+// Commanders receive every message that may issue commands and do their own
+// matching. Prefer RegisterCommands for new commands. The name identifies it as
+// a plugin.
+func (b *Bot) RegisterCommand(name string, command Commander) {
+	if err := b.registerPlugin(name, command); err != nil {
+		b.sessionLog.WithError(err).Fatal("Couldn't register commander")
+	}
+
+	b.commands = append(b.commands, namedCommander{name: name, Commander: command})
+}
+
+// RegisterCommands registers the structured commands provided by the
+// CommandSet with the Router. The name identifies it as a plugin.
+func (b *Bot) RegisterCommands(name string, set CommandSet) {
+	if err := b.registerPlugin(name, set); err != nil {
+		b.sessionLog.WithError(err).Fatal("Couldn't register commands")
+	}
+
+	for _, command := range set.Commands() {
+		command.setPlugin(name)
+
+		if err := b.router.Register(command); err != nil {
+			b.sessionLog.WithError(err).Fatal("Couldn't register command")
+		}
+	}
 }
 
 // RegisterPreviewer registers a URL previewer that follows the Previewer
-// interface.
-func (b *Bot) RegisterPreviewer(previewer Previewer) {
-	b.previewers = append(b.previewers, previewer)
+// interface. The name is how the config refers to it.
+func (b *Bot) RegisterPreviewer(name string, previewer Previewer) {
+	if err := b.registerPlugin(name, previewer); err != nil {
+		b.sessionLog.WithError(err).Fatal("Couldn't register previewer")
+	}
+
+	b.previewers = append(b.previewers, namedPreviewer{name: name, Previewer: previewer})
 }
 
 func (b *Bot) getSelfID() {
@@ -139,7 +272,7 @@ func (b *Bot) getSelfID() {
 }
 
 func (b *Bot) getOwnerID() {
-	if ownerUser, err := b.session.User(os.Getenv("BOT_OWNER")); err == nil {
+	if ownerUser, err := b.session.User(b.Config().Discord.Owner); err == nil {
 		b.sessionLog.WithField("id", ownerUser.ID).Info("Got owner ID")
 		b.ownerID = ownerUser.ID
 	} else {
@@ -157,6 +290,9 @@ func (b *Bot) getOrCreateGuildVoiceStateCache(guildID string) map[string]*discor
 }
 
 func (b *Bot) setupGuild(guild *discordgo.Guild) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
 	// Populate voiceStateCache
 	guildVoiceStateCache := b.getOrCreateGuildVoiceStateCache(guild.ID)
 
@@ -177,6 +313,14 @@ func (b *Bot) onReady(_ *discordgo.Session, event *discordgo.Ready) {
 	b.getSelfID()
 	b.getOwnerID()
 
+	b.registerApplicationCommands()
+
+	// Ready is sent again after reconnecting, but the queue is only restored on
+	// startup.
+	b.audio.restoreOnce.Do(b.audio.RestoreQueue)
+
+	b.readyOnce.Do(b.readyPlugins)
+
 	for _, guild := range event.Guilds {
 		if !guild.Unavailable {
 			b.setupGuild(guild)
@@ -198,13 +342,11 @@ func (b *Bot) IsOwner(ID string) bool {
 	return b.ownerID == ID
 }
 
-// CanIssueCommands checks whether the user with the given ID can issue commands.
-func (b *Bot) CanIssueCommands(ID string) bool {
-	return b.IsOwner(ID)
-}
-
-func (b *Bot) onMessageUpdate(_ *discordgo.Session, msg *discordgo.MessageUpdate) {
-	// Detect message updates here
+// CanIssueCommands checks whether the user with the given ID can use the
+// Commanders registered through RegisterCommand. Commands registered with the
+// Router declare their own permissions instead.
+func (b *Bot) CanIssueCommands(guildID, ID string) bool {
+	return b.HasPermission(guildID, ID, PermissionCommanders)
 }
 
 func (b *Bot) onMessageCreate(_ *discordgo.Session, msg *discordgo.MessageCreate) {
@@ -214,19 +356,47 @@ func (b *Bot) onMessageCreate(_ *discordgo.Session, msg *discordgo.MessageCreate
 		return
 	}
 
-	b.chatLog.Info("Received message from owner")
+	b.chatLog.Info("Received message")
+
+	b.messages.add(msg.Message)
+
+	b.readAloud.onMessage(msg.Message)
 
 	b.previewURLs(msg.Message)
 
-	// TODO
-	// Allow more granular permissions later.
-	if b.CanIssueCommands(msg.Author.ID) {
+	b.routeCommand(msg.Message)
+
+	guildID, _ := b.MessageGuildID(msg.Message)
+
+	if b.CanIssueCommands(guildID, msg.Author.ID) {
 		for _, command := range b.commands {
-			command.Command(b, msg.Message)
+			command := command
+
+			b.dispatch(orderKey(guildID, msg.ChannelID), command.name, func(ctx context.Context) {
+				command.Command(ctx, b, msg.Message)
+			})
 		}
 	}
 }
 
+// routeCommand dispatches the command the message invokes, unless it's the
+// one it invoked before it was edited.
+func (b *Bot) routeCommand(msg *discordgo.Message) {
+	text, _, _ := b.commandText(msg)
+
+	if !b.messages.setCommandText(msg.ID, text) {
+		return
+	}
+
+	if invocation, ok := b.router.prepare(b, msg); ok {
+		b.dispatch(invocation.orderKey(), invocation.Command.plugin, func(ctx context.Context) {
+			b.router.execute(ctx, invocation)
+		})
+	}
+}
+
+// previewURLs previews the URLs in the message that weren't previewed before
+// it was edited.
 func (b *Bot) previewURLs(msg *discordgo.Message) {
 	b.chatLog.Info("Previewing URLs")
 
@@ -241,9 +411,18 @@ func (b *Bot) previewURLs(msg *discordgo.Message) {
 	// that we wouldn't be able to distinguish new URLs from those we've already
 	// previewed unless we maintained a record.
 	//
-	// We avoid those issues and simply detect URLs ourselves.
+	// We avoid those issues and simply detect URLs ourselves. Edits are handled
+	// too, and the record of previewed URLs keeps them from being previewed
+	// again.
+
+	guildID, _ := b.MessageGuildID(msg)
+	config := b.Config().Guild(guildID)
 
 	for _, link := range xurls.Relaxed.FindAllString(msg.Content, -1) {
+		if !b.messages.markPreviewed(msg.ID, link) {
+			continue
+		}
+
 		parsed, err := url.Parse(link)
 
 		if err != nil {
@@ -252,13 +431,32 @@ func (b *Bot) previewURLs(msg *discordgo.Message) {
 		}
 
 		for _, previewer := range b.previewers {
-			previewer.Preview(b, msg, parsed)
+			if !config.PreviewerEnabled(previewer.name) {
+				continue
+			}
+
+			previewer := previewer
+
+			// Previews don't change anything, so only a message's own previews
+			// are ordered.
+			b.dispatch("message:"+msg.ID, previewer.name, func(ctx context.Context) {
+				previewer.Preview(ctx, b, msg, parsed)
+			})
 		}
 	}
 }
 
-func (b *Bot) ReplyToMessage(msg *discordgo.Message, content string) (*discordgo.Message, error) {
-	return b.Session().ChannelMessageSend(msg.ChannelID, "<@"+msg.Author.ID+">: "+content)
+// ReplyToMessage replies to the message's author with SendMessage, recording
+// the replies so that they're deleted if the message is. Replies are sent even
+// while the bot closes, so that commands that finish meanwhile still answer.
+func (b *Bot) ReplyToMessage(msg *discordgo.Message, content string) ([]*discordgo.Message, error) {
+	replies, err := b.SendMessage(context.Background(), msg.ChannelID, "<@"+msg.Author.ID+">: "+content)
+
+	for _, reply := range replies {
+		b.RecordResponse(msg, reply)
+	}
+
+	return replies, err
 }
 
 func (b *Bot) MessageMentionsBot(msg *discordgo.Message) bool {
@@ -271,26 +469,112 @@ func (b *Bot) MessageMentionsBot(msg *discordgo.Message) bool {
 	return false
 }
 
+// commandText strips the invocation prefix from a message that commands the
+// bot. Commands are invoked by mentioning the bot, by starting the message with
+// one of the guild's prefixes, or by messaging the bot directly, in which case
+// the message isn't prefixed.
+func (b *Bot) commandText(msg *discordgo.Message) (text string, prefixed, ok bool) {
+	content := strings.TrimSpace(msg.Content)
+
+	for _, mention := range []string{"<@" + b.userID + ">", "<@!" + b.userID + ">"} {
+		if strings.HasPrefix(content, mention) {
+			return strings.TrimSpace(content[len(mention):]), true, true
+		}
+	}
+
+	guildID, err := b.MessageGuildID(msg)
+
+	if err != nil {
+		return "", false, false
+	}
+
+	// Direct messages don't need a prefix.
+	if guildID == "" {
+		return content, false, true
+	}
+
+	for _, prefix := range b.settings.Guild(guildID).Prefixes {
+		if len(content) >= len(prefix) && strings.EqualFold(content[:len(prefix)], prefix) {
+			return strings.TrimSpace(content[len(prefix):]), true, true
+		}
+	}
+
+	return "", false, false
+}
+
+// MessageCommandsBot checks whether the message is a command for the bot.
 func (b *Bot) MessageCommandsBot(msg *discordgo.Message) bool {
-	return strings.HasPrefix(msg.Content, "<@"+b.userID+">")
+	_, _, ok := b.commandText(msg)
+	return ok
 }
 
+// MessageCommand is the message's command text without the invocation prefix.
+// It's empty if the message doesn't command the bot.
 func (b *Bot) MessageCommand(msg *discordgo.Message) string {
-	return msg.Content[len(b.userID)+4:]
+	command, _, _ := b.commandText(msg)
+	return command
 }
 
-func (b *Bot) getIvonaSpeech(text string) (string, error) {
-	shaSum := fmt.Sprintf("%x", sha1.Sum([]byte(text)))
-	speechPath := path.Join("./data/speech", shaSum)
+// MessageGuildID determines the ID of the guild the message was sent in.
+func (b *Bot) MessageGuildID(msg *discordgo.Message) (string, error) {
+	channel, err := b.session.Channel(msg.ChannelID)
+
+	if err != nil {
+		return "", err
+	}
+
+	return channel.GuildID, nil
+}
+
+// Speech is a request to synthesize speech.
+type Speech struct {
+	Text  string
+	Voice VoiceSettings
+
+	// SSML means that Text is an SSML document rather than plain text.
+	SSML bool
+
+	// Tag optionally identifies the speech so that it can be dropped from the
+	// audio queue if it becomes stale. See Audio.DropTagged.
+	Tag string
+}
+
+// key uniquely identifies the synthesized audio for caching purposes.
+func (s *Speech) key() string {
+	return fmt.Sprintf("%s\x00%s\x00%t\x00%s", s.Voice.Name, s.Voice.Rate, s.SSML, s.Text)
+}
+
+func (s *Speech) ivonaOptions() ivona.SpeechOptions {
+	options := ivona.NewSpeechOptions(s.Text)
+
+	if s.SSML {
+		options.Input.Type = "application/ssml+xml"
+	}
+
+	// Let Ivona infer the language and gender from the voice name.
+	if s.Voice.Name != "" {
+		options.Voice = &ivona.Voice{Name: s.Voice.Name}
+	}
+
+	if s.Voice.Rate != "" {
+		options.Parameters.Rate = s.Voice.Rate
+	}
+
+	return options
+}
+
+func (b *Bot) getIvonaSpeech(speech *Speech) (string, error) {
+	shaSum := fmt.Sprintf("%x", sha1.Sum([]byte(speech.key())))
+	speechPath := path.Join(b.Config().Paths.Speech, shaSum)
 
 	if _, err := os.Stat(speechPath); err == nil {
-		b.voiceLog.Infoln("Cache Hit: Ivona Speech:", text)
+		b.voiceLog.Infoln("Cache Hit: Ivona Speech:", speech.Text)
 		return speechPath, nil
 	}
 
 	b.voiceLog.Info("Cache Miss: Ivona Speech")
 
-	speechOptions := ivona.NewSpeechOptions(text)
+	speechOptions := speech.ivonaOptions()
 	response, err := b.ivonaClient.CreateSpeech(speechOptions)
 
 	if err != nil {
@@ -307,6 +591,9 @@ func (b *Bot) getIvonaSpeech(text string) (string, error) {
 }
 
 func (b *Bot) UserVoiceState(guildID, userID string) (*discordgo.VoiceState, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
 	guildState, ok := b.voiceStateCache[guildID]
 
 	if !ok {
@@ -322,38 +609,62 @@ func (b *Bot) UserVoiceState(guildID, userID string) (*discordgo.VoiceState, err
 	return voiceState, nil
 }
 
-// TODO
-// Not really useful?
-func (b *Bot) JoinUserVoiceChannel(guildID, userID string) (*discordgo.VoiceConnection, error) {
+// JoinUserVoiceChannel joins the voice channel the user is in. The bot leaves
+// it again if it stays idle.
+func (b *Bot) JoinUserVoiceChannel(guildID, userID string) (VoiceConnection, error) {
 	voiceState, err := b.UserVoiceState(guildID, userID)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return b.Session().ChannelVoiceJoin(guildID, voiceState.ChannelID, false, true)
+	voiceConnection, err := b.Session().ChannelVoiceJoin(guildID, voiceState.ChannelID, false, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b.audio.StartIdleTimer(guildID)
+
+	return voiceConnection, nil
 }
 
+// Voice is the voice the user speaks with in the guild: the configured
+// default, overridden by the guild's voice settings and then the user's. An
+// empty user ID gives the guild's voice.
+func (b *Bot) Voice(guildID, userID string) VoiceSettings {
+	return b.Config().Guild(guildID).Voice.merge(b.settings.Voice(guildID, userID))
+}
+
+// Speak speaks the text in the voice channel using the guild's voice.
 func (b *Bot) Speak(guildID, voiceChannelID, text string) error {
+	return b.SpeakWith(guildID, voiceChannelID, &Speech{
+		Text:  text,
+		Voice: b.Voice(guildID, ""),
+	})
+}
+
+// SpeakWith synthesizes the speech and queues it in the voice channel.
+func (b *Bot) SpeakWith(guildID, voiceChannelID string, speech *Speech) error {
 	// TODO
 	// Look into the possibility of pausing certain audio transmissions, switching
 	// channels, then switching back and resuming.
 
-	if speechFile, err := b.getIvonaSpeech(text); err == nil {
+	if speechFile, err := b.getIvonaSpeech(speech); err == nil {
 		b.voiceLog.WithFields(log.Fields{
 			"path":    speechFile,
 			"guild":   guildID,
 			"channel": voiceChannelID,
 		}).Info("Emitting speech event")
 
-		file, err := b.audio.GetOrConvertFile(speechFile, text)
+		file, err := b.audio.GetOrConvertFile(b.Context(), speechFile, speech.key())
 
 		if err != nil {
 			b.voiceLog.WithError(err).Error("Couldn't get or convert speech file")
 			return err
 		}
 
-		b.audio.EnqueueAudioFile(guildID, voiceChannelID, file)
+		b.audio.EnqueueTaggedAudioFile(guildID, voiceChannelID, speech.Tag, file)
 	} else {
 		return err
 	}
@@ -362,46 +673,27 @@ func (b *Bot) Speak(guildID, voiceChannelID, text string) error {
 }
 
 func channelName(guild *discordgo.Guild, channel *discordgo.Channel) string {
-	return guild.Name + "#" + channel.Name + "[" + channel.Type + "]"
-}
-
-func (b *Bot) speakPresenceUpdate(voiceState *discordgo.VoiceState, action string) {
-	member, err := b.session.State.Member(voiceState.GuildID, voiceState.UserID)
-
-	if err != nil {
-		b.sessionLog.WithFields(log.Fields{
-			"guild": voiceState.GuildID,
-			"user":  voiceState.UserID,
-		}).WithError(err).Error("Couldn't find user")
-
-		return
-	}
-
-	presenceText := fmt.Sprintf("%s %s the channel", memberFriendlyName(member), action)
-
-	if err := b.Speak(voiceState.GuildID, voiceState.ChannelID, presenceText); err != nil {
-		b.sessionLog.WithError(err).Error("Couldn't speak with Ivona")
-	}
+	return guild.Name + "#" + channel.Name + "[" + fmt.Sprint(channel.Type) + "]"
 }
 
 func (b *Bot) voiceStateLog(voiceState *discordgo.VoiceState) *log.Entry {
 	logger := b.voiceLog
 
-	if guild, err := b.session.State.Guild(voiceState.GuildID); err == nil {
+	if guild, err := b.session.Guild(voiceState.GuildID); err == nil {
 		logger = logger.WithField("guild", guild.Name)
 	} else {
 		b.sessionLog.WithField("guild", voiceState.GuildID).WithError(err).Error("Couldn't find guild")
 		logger = logger.WithField("guild", voiceState.GuildID)
 	}
 
-	if member, err := b.session.State.Member(voiceState.GuildID, voiceState.UserID); err == nil {
+	if member, err := b.session.Member(voiceState.GuildID, voiceState.UserID); err == nil {
 		logger = logger.WithField("user", memberDiscordTag(member))
 	} else {
 		b.sessionLog.WithField("user", voiceState.UserID).WithError(err).Error("Couldn't find user")
 		logger = logger.WithField("user", voiceState.UserID)
 	}
 
-	if channel, err := b.session.State.Channel(voiceState.ChannelID); err == nil {
+	if channel, err := b.session.Channel(voiceState.ChannelID); err == nil {
 		logger = logger.WithField("channel", channel.Name)
 	} else {
 		b.sessionLog.WithField("channel", voiceState.ChannelID).WithError(err).Error("Couldn't find channel")
@@ -411,49 +703,77 @@ func (b *Bot) voiceStateLog(voiceState *discordgo.VoiceState) *log.Entry {
 	return logger
 }
 
-func (b *Bot) onUserLeaveVoiceChannel(voiceState *discordgo.VoiceState) {
+func (b *Bot) onUserLeaveVoiceChannel(voiceState *discordgo.VoiceState) interface{} {
 	b.voiceStateLog(voiceState).Info("User left")
 
-	b.speakPresenceUpdate(voiceState, "left")
-
-	// TODO
-	// Or should Audio register itself through a pointer to Bot?
-	b.audio.onUserLeaveVoiceChannel(voiceState)
+	return &VoiceLeaveEvent{
+		GuildID:   voiceState.GuildID,
+		UserID:    voiceState.UserID,
+		ChannelID: voiceState.ChannelID,
+	}
 }
 
-func (b *Bot) onUserJoinVoiceChannel(voiceState *discordgo.VoiceState) {
+func (b *Bot) onUserJoinVoiceChannel(voiceState *discordgo.VoiceState) interface{} {
 	b.voiceStateLog(voiceState).Info("User joined")
 
-	b.speakPresenceUpdate(voiceState, "joined")
+	return &VoiceJoinEvent{
+		GuildID:   voiceState.GuildID,
+		UserID:    voiceState.UserID,
+		ChannelID: voiceState.ChannelID,
+	}
 }
 
-func (b *Bot) detectVoiceChannelPresenceChange(update *discordgo.VoiceState) {
+func (b *Bot) onUserMoveVoiceChannel(from, to *discordgo.VoiceState) interface{} {
+	b.voiceStateLog(to).WithField("from", from.ChannelID).Info("User moved")
+
+	return &VoiceMoveEvent{
+		GuildID:       to.GuildID,
+		UserID:        to.UserID,
+		FromChannelID: from.ChannelID,
+		ToChannelID:   to.ChannelID,
+	}
+}
+
+// detectVoiceChannelPresenceChange updates the voice state cache, returning
+// the event for the user's change of channel, if any. The caller must hold the
+// lock.
+func (b *Bot) detectVoiceChannelPresenceChange(update *discordgo.VoiceState) interface{} {
 	guildVoiceStateCache := b.getOrCreateGuildVoiceStateCache(update.GuildID)
 
+	joinedChannel := update.ChannelID != ""
+
+	var event interface{}
+
 	if cached, wasCached := guildVoiceStateCache[update.UserID]; wasCached {
 		changedChannels := cached.ChannelID != update.ChannelID
 
 		if !changedChannels {
 			b.voiceLog.Info("No channel change detected")
-			return
+			return nil
 		}
 
 		leftChannel := cached.ChannelID != ""
 
-		if leftChannel {
-			b.onUserLeaveVoiceChannel(cached)
+		switch {
+		case leftChannel && joinedChannel:
+			guildVoiceStateCache[update.UserID] = update
+
+			return b.onUserMoveVoiceChannel(cached, update)
+
+		case leftChannel:
+			event = b.onUserLeaveVoiceChannel(cached)
 		}
 
 		delete(guildVoiceStateCache, update.UserID)
 	}
 
-	joinedChannel := update.ChannelID != ""
-
 	if joinedChannel {
-		b.onUserJoinVoiceChannel(update)
+		event = b.onUserJoinVoiceChannel(update)
 
 		guildVoiceStateCache[update.UserID] = update
 	}
+
+	return event
 }
 
 func (b *Bot) onVoiceStateUpdate(_ *discordgo.Session, update *discordgo.VoiceStateUpdate) {
@@ -462,8 +782,35 @@ func (b *Bot) onVoiceStateUpdate(_ *discordgo.Session, update *discordgo.VoiceSt
 		return
 	}
 
+	b.lock.Lock()
+	event := b.detectVoiceChannelPresenceChange(update.VoiceState)
+	b.lock.Unlock()
+
+	// Subscribers may look up who's in a voice channel, which needs the lock.
+	if event != nil {
+		b.bus.Publish(event)
+	}
+}
+
+// voiceChannelUsers lists the IDs of the users in the voice channel, excluding
+// the bot. The caller must hold the lock.
+func (b *Bot) voiceChannelUsers(guildID, channelID string) []string {
+	var users []string
+
+	for userID, voiceState := range b.voiceStateCache[guildID] {
+		if voiceState.ChannelID == channelID && !b.IsSelf(userID) {
+			users = append(users, userID)
+		}
+	}
+
+	return users
+}
+
+// VoiceChannelUsers lists the IDs of the users in the voice channel, excluding
+// the bot.
+func (b *Bot) VoiceChannelUsers(guildID, channelID string) []string {
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
-	b.detectVoiceChannelPresenceChange(update.VoiceState)
+	return b.voiceChannelUsers(guildID, channelID)
 }
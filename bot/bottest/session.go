@@ -0,0 +1,469 @@
+// Package bottest provides a fake Discord session for testing the bot and its
+// plugins without connecting to Discord.
+package bottest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/blaenk/bmo/bot"
+)
+
+// ErrNotFound is returned when looking up something the session wasn't given.
+var ErrNotFound = errors.New("Not found")
+
+// Session is a bot.Session kept in memory. It records the messages the bot
+// sends, and tests emit the Discord events the bot receives.
+type Session struct {
+	lock sync.Mutex
+
+	self     *discordgo.User
+	users    map[string]*discordgo.User
+	guilds   map[string]*discordgo.Guild
+	channels map[string]*discordgo.Channel
+	voice    map[string]*VoiceConnection
+
+	handlers []*handler
+	commands []*discordgo.ApplicationCommand
+
+	messages []*discordgo.Message
+	deleted  []string
+	sent     chan *discordgo.Message
+	nextID   int
+}
+
+type handler struct {
+	fn reflect.Value
+}
+
+// NewSession creates a session for the bot's own user.
+func NewSession(self *discordgo.User) *Session {
+	s := &Session{
+		self:     self,
+		users:    map[string]*discordgo.User{},
+		guilds:   map[string]*discordgo.Guild{},
+		channels: map[string]*discordgo.Channel{},
+		voice:    map[string]*VoiceConnection{},
+
+		// Sent messages are buffered so that the bot doesn't block on tests that
+		// don't wait for them.
+		sent: make(chan *discordgo.Message, 1024),
+	}
+
+	s.AddUser(self)
+
+	return s
+}
+
+// AddUser makes the user known to the session.
+func (s *Session) AddUser(user *discordgo.User) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.users[user.ID] = user
+}
+
+// AddGuild makes the guild known to the session, along with its channels and
+// its members' users.
+func (s *Session) AddGuild(guild *discordgo.Guild) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.guilds[guild.ID] = guild
+
+	for _, channel := range guild.Channels {
+		channel.GuildID = guild.ID
+		s.channels[channel.ID] = channel
+	}
+
+	for _, member := range guild.Members {
+		member.GuildID = guild.ID
+		s.users[member.User.ID] = member.User
+	}
+}
+
+// AddChannel makes the channel known to the session, e.g. a direct message
+// channel.
+func (s *Session) AddChannel(channel *discordgo.Channel) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.channels[channel.ID] = channel
+}
+
+func (s *Session) newID() string {
+	s.nextID++
+
+	return fmt.Sprint(s.nextID)
+}
+
+// Emit sends the event to the handlers for its type, as though it was
+// received from Discord, and returns once they have.
+func (s *Session) Emit(event interface{}) {
+	s.lock.Lock()
+	handlers := append([]*handler(nil), s.handlers...)
+	s.lock.Unlock()
+
+	arguments := []reflect.Value{
+		reflect.Zero(reflect.TypeOf((*discordgo.Session)(nil))),
+		reflect.ValueOf(event),
+	}
+
+	for _, handler := range handlers {
+		if handler.fn.Type().In(1) == arguments[1].Type() {
+			handler.fn.Call(arguments)
+		}
+	}
+}
+
+// Ready emits the Ready event for the guilds that were added.
+func (s *Session) Ready() {
+	s.lock.Lock()
+
+	ready := &discordgo.Ready{User: s.self}
+
+	for _, guild := range s.guilds {
+		ready.Guilds = append(ready.Guilds, guild)
+	}
+
+	s.lock.Unlock()
+
+	s.Emit(ready)
+}
+
+// Message emits the message as though the user sent it in the channel.
+func (s *Session) Message(channelID, userID, content string) *discordgo.Message {
+	s.lock.Lock()
+
+	message := &discordgo.Message{
+		ID:        s.newID(),
+		ChannelID: channelID,
+		Content:   content,
+		Author:    s.users[userID],
+		Timestamp: time.Now(),
+	}
+
+	if channel, ok := s.channels[channelID]; ok {
+		message.GuildID = channel.GuildID
+	}
+
+	s.lock.Unlock()
+
+	s.Emit(&discordgo.MessageCreate{Message: message})
+
+	return message
+}
+
+// Edit emits an edit of the message's content.
+func (s *Session) Edit(message *discordgo.Message, content string) {
+	edited := time.Now()
+
+	update := *message
+	update.Content = content
+	update.EditedTimestamp = &edited
+
+	s.Emit(&discordgo.MessageUpdate{Message: &update})
+}
+
+// Delete emits the deletion of the message.
+func (s *Session) Delete(message *discordgo.Message) {
+	s.Emit(&discordgo.MessageDelete{Message: message})
+}
+
+// VoiceStateUpdate emits a change to the user's voice channel. An empty
+// channel ID means the user left.
+func (s *Session) VoiceStateUpdate(guildID, userID, channelID string) {
+	s.Emit(&discordgo.VoiceStateUpdate{
+		VoiceState: &discordgo.VoiceState{
+			GuildID:   guildID,
+			UserID:    userID,
+			ChannelID: channelID,
+		},
+	})
+}
+
+// Messages are the messages the bot sent, in order.
+func (s *Session) Messages() []*discordgo.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]*discordgo.Message(nil), s.messages...)
+}
+
+// Deleted are the IDs of the messages the bot deleted, in order.
+func (s *Session) Deleted() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]string(nil), s.deleted...)
+}
+
+// NextMessage waits for the bot to send its next message. Each message is
+// only returned once.
+func (s *Session) NextMessage(timeout time.Duration) (*discordgo.Message, error) {
+	select {
+	case message := <-s.sent:
+		return message, nil
+
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("No message was sent within %s", timeout)
+	}
+}
+
+// ApplicationCommands are the application commands the bot registered.
+func (s *Session) ApplicationCommands() []*discordgo.ApplicationCommand {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.commands
+}
+
+// send records a message the bot sent.
+func (s *Session) send(message *discordgo.Message) *discordgo.Message {
+	s.lock.Lock()
+
+	message.ID = s.newID()
+	message.Author = s.self
+	message.Timestamp = time.Now()
+
+	if channel, ok := s.channels[message.ChannelID]; ok {
+		message.GuildID = channel.GuildID
+	}
+
+	s.messages = append(s.messages, message)
+
+	s.lock.Unlock()
+
+	s.sent <- message
+
+	return message
+}
+
+// Open does nothing.
+func (s *Session) Open() error {
+	return nil
+}
+
+// Close disconnects the voice connections.
+func (s *Session) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for guildID := range s.voice {
+		delete(s.voice, guildID)
+	}
+
+	return nil
+}
+
+// AddHandler registers a handler for the events passed to Emit.
+func (s *Session) AddHandler(fn interface{}) func() {
+	added := &handler{fn: reflect.ValueOf(fn)}
+
+	if added.fn.Kind() != reflect.Func || added.fn.Type().NumIn() != 2 {
+		panic(fmt.Sprintf("Invalid handler %T", fn))
+	}
+
+	s.lock.Lock()
+	s.handlers = append(s.handlers, added)
+	s.lock.Unlock()
+
+	return func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		for i, handler := range s.handlers {
+			if handler == added {
+				s.handlers = append(s.handlers[:i:i], s.handlers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// User looks up a user that was added. The ID "@me" is the bot itself.
+func (s *Session) User(userID string) (*discordgo.User, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if userID == "@me" {
+		return s.self, nil
+	}
+
+	if user, ok := s.users[userID]; ok {
+		return user, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// Channel looks up a channel that was added.
+func (s *Session) Channel(channelID string) (*discordgo.Channel, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if channel, ok := s.channels[channelID]; ok {
+		return channel, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// Guild looks up a guild that was added.
+func (s *Session) Guild(guildID string) (*discordgo.Guild, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if guild, ok := s.guilds[guildID]; ok {
+		return guild, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// Member looks up a member of a guild that was added.
+func (s *Session) Member(guildID, userID string) (*discordgo.Member, error) {
+	guild, err := s.Guild(guildID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range guild.Members {
+		if member.User.ID == userID {
+			return member, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// Role looks up a role in a guild that was added.
+func (s *Session) Role(guildID, roleID string) (*discordgo.Role, error) {
+	guild, err := s.Guild(guildID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range guild.Roles {
+		if role.ID == roleID {
+			return role, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// ChannelMessageSend records the message.
+func (s *Session) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{ChannelID: channelID, Content: content}), nil
+}
+
+// ChannelMessageSendEmbed records the embed as a message.
+func (s *Session) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{ChannelID: channelID, Embeds: []*discordgo.MessageEmbed{embed}}), nil
+}
+
+// ChannelMessageSendReply records the message with its reference.
+func (s *Session) ChannelMessageSendReply(channelID, content string, reference *discordgo.MessageReference) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{ChannelID: channelID, Content: content, MessageReference: reference}), nil
+}
+
+// ChannelMessageDelete records the deletion.
+func (s *Session) ChannelMessageDelete(channelID, messageID string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.deleted = append(s.deleted, messageID)
+
+	return nil
+}
+
+// ChannelTyping does nothing.
+func (s *Session) ChannelTyping(channelID string) error {
+	return nil
+}
+
+// ChannelVoiceJoin connects to a voice channel that was added.
+func (s *Session) ChannelVoiceJoin(guildID, channelID string, mute, deaf bool) (bot.VoiceConnection, error) {
+	channel, err := s.Channel(channelID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if channel.GuildID != guildID || channel.Type != discordgo.ChannelTypeGuildVoice {
+		return nil, fmt.Errorf("Channel %s isn't a voice channel in guild %s", channelID, guildID)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if voiceConnection, ok := s.voice[guildID]; ok {
+		voiceConnection.move(channelID)
+		return voiceConnection, nil
+	}
+
+	voiceConnection := newVoiceConnection(s, guildID, channelID)
+	s.voice[guildID] = voiceConnection
+
+	return voiceConnection, nil
+}
+
+// VoiceConnection is the guild's voice connection, if any.
+func (s *Session) VoiceConnection(guildID string) (bot.VoiceConnection, bool) {
+	if voiceConnection := s.Voice(guildID); voiceConnection != nil {
+		return voiceConnection, true
+	}
+
+	return nil, false
+}
+
+// Voice is the guild's fake voice connection, or nil if there isn't one.
+func (s *Session) Voice(guildID string) *VoiceConnection {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.voice[guildID]
+}
+
+// InteractionRespond records the response's content as a message.
+func (s *Session) InteractionRespond(interaction *discordgo.Interaction, response *discordgo.InteractionResponse) error {
+	if response.Data != nil {
+		s.send(&discordgo.Message{ChannelID: interaction.ChannelID, Content: response.Data.Content})
+	}
+
+	return nil
+}
+
+// InteractionResponseEdit records the edited content as a message.
+func (s *Session) InteractionResponseEdit(interaction *discordgo.Interaction, edit *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	content := ""
+
+	if edit.Content != nil {
+		content = *edit.Content
+	}
+
+	return s.send(&discordgo.Message{ChannelID: interaction.ChannelID, Content: content}), nil
+}
+
+// FollowupMessageCreate records the followup as a message.
+func (s *Session) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, params *discordgo.WebhookParams) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{ChannelID: interaction.ChannelID, Content: params.Content}), nil
+}
+
+// ApplicationCommandBulkOverwrite records the commands.
+func (s *Session) ApplicationCommandBulkOverwrite(appID, guildID string, commands []*discordgo.ApplicationCommand) ([]*discordgo.ApplicationCommand, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.commands = commands
+
+	return commands, nil
+}
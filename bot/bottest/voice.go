@@ -0,0 +1,150 @@
+package bottest
+
+import (
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// VoiceConnection is a bot.VoiceConnection that counts the Opus frames sent
+// through it instead of sending them to Discord.
+type VoiceConnection struct {
+	lock sync.Mutex
+	cond *sync.Cond
+
+	session   *Session
+	guildID   string
+	channelID string
+
+	send     chan []byte
+	recv     chan *discordgo.Packet
+	frames   int
+	speaking bool
+
+	speakingHandlers []func(*discordgo.VoiceSpeakingUpdate)
+}
+
+func newVoiceConnection(session *Session, guildID, channelID string) *VoiceConnection {
+	v := &VoiceConnection{
+		session:   session,
+		guildID:   guildID,
+		channelID: channelID,
+		send:      make(chan []byte),
+		recv:      make(chan *discordgo.Packet),
+	}
+
+	v.cond = sync.NewCond(&v.lock)
+
+	go v.receive()
+
+	return v
+}
+
+// receive counts the frames the bot sends.
+func (v *VoiceConnection) receive() {
+	for range v.send {
+		v.lock.Lock()
+		v.frames++
+		v.cond.Broadcast()
+		v.lock.Unlock()
+	}
+}
+
+func (v *VoiceConnection) move(channelID string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.channelID = channelID
+}
+
+// Frames is how many Opus frames were sent.
+func (v *VoiceConnection) Frames() int {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return v.frames
+}
+
+// WaitFrames waits until at least n Opus frames were sent.
+func (v *VoiceConnection) WaitFrames(n int) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	for v.frames < n {
+		v.cond.Wait()
+	}
+}
+
+// IsSpeaking reports whether the bot said it's speaking.
+func (v *VoiceConnection) IsSpeaking() bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return v.speaking
+}
+
+// SpeakingUpdate sends the update to the handlers registered with
+// OnSpeakingUpdate.
+func (v *VoiceConnection) SpeakingUpdate(update *discordgo.VoiceSpeakingUpdate) {
+	v.lock.Lock()
+	handlers := append([](func(*discordgo.VoiceSpeakingUpdate))(nil), v.speakingHandlers...)
+	v.lock.Unlock()
+
+	for _, handler := range handlers {
+		handler(update)
+	}
+}
+
+// ChannelID is the voice channel that's connected to.
+func (v *VoiceConnection) ChannelID() string {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return v.channelID
+}
+
+// Ready is always true.
+func (v *VoiceConnection) Ready() bool {
+	return true
+}
+
+// Speaking records whether the bot is speaking.
+func (v *VoiceConnection) Speaking(speaking bool) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.speaking = speaking
+
+	return nil
+}
+
+// OpusSend receives the frames the bot sends.
+func (v *VoiceConnection) OpusSend() chan<- []byte {
+	return v.send
+}
+
+// OpusRecv never receives anything.
+func (v *VoiceConnection) OpusRecv() <-chan *discordgo.Packet {
+	return v.recv
+}
+
+// OnSpeakingUpdate registers the handler for SpeakingUpdate.
+func (v *VoiceConnection) OnSpeakingUpdate(handler func(*discordgo.VoiceSpeakingUpdate)) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.speakingHandlers = append(v.speakingHandlers, handler)
+}
+
+// Disconnect removes the connection from the session. Frames sent afterwards
+// are still counted.
+func (v *VoiceConnection) Disconnect() error {
+	v.session.lock.Lock()
+	defer v.session.lock.Unlock()
+
+	if v.session.voice[v.guildID] == v {
+		delete(v.session.voice, v.guildID)
+	}
+
+	return nil
+}
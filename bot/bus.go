@@ -0,0 +1,78 @@
+package bot
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Bus delivers the bot's events to whoever subscribed to them, so that e.g.
+// Audio and plugins can react to voice changes without the Bot knowing about
+// them. Events are pointers to structs such as *VoiceLeaveEvent.
+type Bus struct {
+	lock     sync.RWMutex
+	handlers map[reflect.Type][]*busHandler
+}
+
+type busHandler struct {
+	fn reflect.Value
+}
+
+// NewBus creates a Bus with no subscribers.
+func NewBus() *Bus {
+	return &Bus{
+		handlers: map[reflect.Type][]*busHandler{},
+	}
+}
+
+// Subscribe registers the handler for the events its one argument accepts,
+// e.g. func(*TrackStartedEvent). It returns a function that unsubscribes the
+// handler. Handlers that don't take exactly one argument panic.
+func (b *Bus) Subscribe(handler interface{}) func() {
+	fn := reflect.ValueOf(handler)
+
+	if fn.Kind() != reflect.Func || fn.Type().NumIn() != 1 {
+		panic(fmt.Sprintf("Bus handler must be a function of one event, not %T", handler))
+	}
+
+	eventType := fn.Type().In(0)
+	subscription := &busHandler{fn: fn}
+
+	b.lock.Lock()
+	b.handlers[eventType] = append(b.handlers[eventType], subscription)
+	b.lock.Unlock()
+
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		handlers := b.handlers[eventType]
+
+		for i, handler := range handlers {
+			if handler == subscription {
+				b.handlers[eventType] = append(handlers[:i:i], handlers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Publish calls the handlers subscribed to the event's type in the order they
+// subscribed, returning once they all have. Handlers may publish events and
+// subscribe, but the publisher shouldn't hold locks that handlers may need.
+func (b *Bus) Publish(event interface{}) {
+	b.lock.RLock()
+	handlers := b.handlers[reflect.TypeOf(event)]
+	b.lock.RUnlock()
+
+	arguments := []reflect.Value{reflect.ValueOf(event)}
+
+	for _, handler := range handlers {
+		handler.fn.Call(arguments)
+	}
+}
+
+// Bus provides access to the bot's events.
+func (b *Bot) Bus() *Bus {
+	return b.bus
+}
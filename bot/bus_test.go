@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusPublish(t *testing.T) {
+	bus := NewBus()
+	var calls []string
+
+	bus.Subscribe(func(event *VoiceJoinEvent) {
+		calls = append(calls, "first join "+event.UserID)
+	})
+
+	unsubscribe := bus.Subscribe(func(event *VoiceJoinEvent) {
+		calls = append(calls, "second join "+event.UserID)
+	})
+
+	bus.Subscribe(func(event *VoiceLeaveEvent) {
+		calls = append(calls, "leave "+event.UserID)
+	})
+
+	bus.Publish(&VoiceJoinEvent{UserID: "a"})
+	bus.Publish(&VoiceLeaveEvent{UserID: "b"})
+	bus.Publish(&VoiceMoveEvent{UserID: "c"})
+
+	unsubscribe()
+	unsubscribe()
+
+	bus.Publish(&VoiceJoinEvent{UserID: "d"})
+
+	assert.Equal(t, []string{
+		"first join a",
+		"second join a",
+		"leave b",
+		"first join d",
+	}, calls)
+}
+
+func TestBusPublishFromHandler(t *testing.T) {
+	bus := NewBus()
+	var finished []string
+
+	bus.Subscribe(func(event *TrackStartedEvent) {
+		bus.Publish(&TrackFinishedEvent{Event: event.Event})
+	})
+
+	bus.Subscribe(func(event *TrackFinishedEvent) {
+		finished = append(finished, event.Event.Title())
+	})
+
+	bus.Publish(&TrackStartedEvent{Event: testAudioEvent("g", "u", "a")})
+
+	assert.Equal(t, []string{"a"}, finished)
+}
+
+func TestBusSubscribeInvalid(t *testing.T) {
+	bus := NewBus()
+
+	assert.Panics(t, func() { bus.Subscribe("handler") })
+	assert.Panics(t, func() { bus.Subscribe(func() {}) })
+}
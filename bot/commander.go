@@ -1,8 +1,19 @@
 package bot
 
-import "github.com/bwmarrin/discordgo"
+import (
+	"context"
 
-// Commander represents a command responder
+	"github.com/bwmarrin/discordgo"
+)
+
+// Commander represents a command responder. The context is cancelled when the
+// command runs past its plugin's timeout or the bot closes.
 type Commander interface {
-	Command(bot *Bot, message *discordgo.Message)
+	Command(ctx context.Context, bot *Bot, message *discordgo.Message)
+}
+
+// namedCommander is a Commander with the name it was registered under.
+type namedCommander struct {
+	name string
+	Commander
 }
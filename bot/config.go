@@ -0,0 +1,549 @@
+package bot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Duration is a time.Duration written in a config file as a string such as
+// "5m" or "1h30m".
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses the duration.
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+
+	if err != nil {
+		return err
+	}
+
+	d.Duration = duration
+
+	return nil
+}
+
+// MarshalText formats the duration.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// DiscordConfig is how the bot connects to Discord.
+type DiscordConfig struct {
+	Token string `toml:"token"`
+
+	// Owner is the ID of the bot's owner, who has every permission.
+	Owner string `toml:"owner"`
+}
+
+// IvonaConfig holds the Ivona text-to-speech credentials.
+type IvonaConfig struct {
+	AccessKey string `toml:"access_key"`
+	SecretKey string `toml:"secret_key"`
+}
+
+// PathsConfig is where the bot keeps its data. Changes only apply after a
+// restart.
+type PathsConfig struct {
+	Opus    string `toml:"opus"`
+	Speech  string `toml:"speech"`
+	Store   string `toml:"store"`
+	Library string `toml:"library"`
+
+	// Queue and History are deprecated. They're where the queue and history
+	// were kept before they moved into the store, and are only read to import
+	// them into it.
+	Queue   string `toml:"queue"`
+	History string `toml:"history"`
+}
+
+// VoiceConfig controls how the bot behaves in voice channels.
+type VoiceConfig struct {
+	// IdleTimeout is how long the bot stays in a voice channel with nothing to
+	// play. Zero means it stays.
+	IdleTimeout Duration `toml:"idle_timeout"`
+
+	// PresenceDebounce is how long presence changes are coalesced before
+	// they're announced.
+	PresenceDebounce Duration `toml:"presence_debounce"`
+
+	// ResumePlayback resumes the track that was playing before a restart where
+	// it left off instead of from the start.
+	ResumePlayback bool `toml:"resume_playback"`
+}
+
+// WorkersConfig controls how commands and previews are run. Each plugin may set
+// its own timeout in its section.
+type WorkersConfig struct {
+	// Count is how many commands and previews can run at once. Changes only
+	// apply after a restart.
+	Count int `toml:"count"`
+
+	// Queue is how many commands and previews can wait for a worker before
+	// more are dropped. Changes only apply after a restart.
+	Queue int `toml:"queue"`
+
+	// Timeout is how long a command or preview may run before it's cancelled.
+	Timeout Duration `toml:"timeout"`
+}
+
+// GuildConfig is configuration that may differ per guild. The defaults
+// section applies to every guild, and each guild's section overrides the
+// fields it sets.
+type GuildConfig struct {
+	// DisabledCommands are the names of commands that can't be used, either
+	// top-level commands such as radio or subcommands such as "radio add".
+	DisabledCommands []string `toml:"disabled_commands"`
+
+	// DisabledPreviewers are the names of previewers that don't preview links.
+	DisabledPreviewers []string `toml:"disabled_previewers"`
+
+	// Voice is the default text-to-speech voice, which the guild's and users'
+	// voice settings override.
+	Voice VoiceSettings `toml:"voice"`
+
+	// MaxSayLength is the longest text the say command speaks.
+	MaxSayLength int `toml:"max_say_length"`
+
+	// SayCooldown is how long users wait between say commands.
+	SayCooldown Duration `toml:"say_cooldown"`
+
+	// Timezone is the IANA time zone times are shown in.
+	Timezone string `toml:"timezone"`
+
+	// EditWindow is how long after a message is sent that editing it is
+	// handled: commands are run again and new links are previewed.
+	EditWindow Duration `toml:"edit_window"`
+}
+
+// merge overlays the fields other sets on top of g.
+func (g GuildConfig) merge(other GuildConfig) GuildConfig {
+	if other.DisabledCommands != nil {
+		g.DisabledCommands = other.DisabledCommands
+	}
+
+	if other.DisabledPreviewers != nil {
+		g.DisabledPreviewers = other.DisabledPreviewers
+	}
+
+	g.Voice = g.Voice.merge(other.Voice)
+
+	if other.MaxSayLength != 0 {
+		g.MaxSayLength = other.MaxSayLength
+	}
+
+	if other.SayCooldown.Duration != 0 {
+		g.SayCooldown = other.SayCooldown
+	}
+
+	if other.Timezone != "" {
+		g.Timezone = other.Timezone
+	}
+
+	if other.EditWindow.Duration != 0 {
+		g.EditWindow = other.EditWindow
+	}
+
+	return g
+}
+
+// CommandEnabled checks whether the command with the path, such as
+// "radio add", is enabled. Disabling a command disables its subcommands.
+func (g GuildConfig) CommandEnabled(path string) bool {
+	for _, disabled := range g.DisabledCommands {
+		if path == disabled || strings.HasPrefix(path, disabled+" ") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PreviewerEnabled checks whether the previewer with the name is enabled.
+func (g GuildConfig) PreviewerEnabled(name string) bool {
+	for _, disabled := range g.DisabledPreviewers {
+		if name == disabled {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Location is the guild's time zone, or UTC if it isn't set.
+func (g GuildConfig) Location() *time.Location {
+	if location, err := loadLocation(g.Timezone); err == nil {
+		return location
+	}
+
+	return time.UTC
+}
+
+var (
+	locationLock  sync.Mutex
+	locationCache = map[string]*time.Location{}
+)
+
+// loadLocation loads the time zone, caching it since loading it reads the
+// zone database.
+func loadLocation(name string) (*time.Location, error) {
+	locationLock.Lock()
+	defer locationLock.Unlock()
+
+	if location, ok := locationCache[name]; ok {
+		return location, nil
+	}
+
+	location, err := time.LoadLocation(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	locationCache[name] = location
+
+	return location, nil
+}
+
+// Config is the bot's configuration, read from a TOML file.
+type Config struct {
+	Discord DiscordConfig `toml:"discord"`
+	Ivona   IvonaConfig   `toml:"ivona"`
+	Paths   PathsConfig   `toml:"paths"`
+	Voice   VoiceConfig   `toml:"voice"`
+	Workers WorkersConfig `toml:"workers"`
+
+	Defaults GuildConfig            `toml:"defaults"`
+	Guilds   map[string]GuildConfig `toml:"guilds"`
+
+	// Plugins are the plugins' own sections, keyed by the names they were
+	// registered under. Each plugin decodes its section itself.
+	Plugins map[string]toml.Primitive `toml:"plugins"`
+
+	// path is the file the config was loaded from.
+	path string
+
+	// metadata is needed to decode the plugins' sections.
+	metadata toml.MetaData
+
+	// pluginTimeouts are the timeouts set in the plugins' sections.
+	pluginTimeouts map[string]time.Duration
+}
+
+// PluginTimeout is how long the plugin's commands or previews may run.
+func (c *Config) PluginTimeout(name string) time.Duration {
+	if timeout, ok := c.pluginTimeouts[name]; ok {
+		return timeout
+	}
+
+	return c.Workers.Timeout.Duration
+}
+
+// PluginConfig is a plugin's section of the config.
+type PluginConfig struct {
+	metadata  toml.MetaData
+	primitive toml.Primitive
+	present   bool
+}
+
+// Present reports whether the config has a section for the plugin.
+func (c PluginConfig) Present() bool {
+	return c.present
+}
+
+// Decode decodes the plugin's section into v, leaving v as it is if there's no
+// section.
+func (c PluginConfig) Decode(v interface{}) error {
+	if !c.present {
+		return nil
+	}
+
+	return c.metadata.PrimitiveDecode(c.primitive, v)
+}
+
+// Plugin is the plugin's section of the config.
+func (c *Config) Plugin(name string) PluginConfig {
+	primitive, ok := c.Plugins[name]
+
+	return PluginConfig{metadata: c.metadata, primitive: primitive, present: ok}
+}
+
+// DefaultConfig is the configuration that a config file overrides.
+func DefaultConfig() *Config {
+	return &Config{
+		Paths: PathsConfig{
+			Opus:    "./data/opus",
+			Speech:  "./data/speech",
+			Store:   "./data/bmo.db",
+			Library: "./data/library",
+			Queue:   "./data/queue.json",
+			History: "./data/history.jsonl",
+		},
+		Voice: VoiceConfig{
+			IdleTimeout:      Duration{5 * time.Minute},
+			PresenceDebounce: Duration{5 * time.Second},
+			ResumePlayback:   true,
+		},
+		Workers: WorkersConfig{
+			Count:   8,
+			Queue:   100,
+			Timeout: Duration{30 * time.Second},
+		},
+		Defaults: GuildConfig{
+			MaxSayLength: 200,
+			SayCooldown:  Duration{10 * time.Second},
+			Timezone:     "UTC",
+			EditWindow:   Duration{2 * time.Minute},
+		},
+		Guilds: map[string]GuildConfig{},
+	}
+}
+
+// Guild is the configuration for the guild: the defaults overridden by the
+// guild's own section.
+func (c *Config) Guild(guildID string) GuildConfig {
+	return c.Defaults.merge(c.Guilds[guildID])
+}
+
+// ConfigError lists the problems found in a config file.
+type ConfigError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("Invalid config %s:\n  %s", e.Path, strings.Join(e.Problems, "\n  "))
+}
+
+// validateGuild appends the problems with the guild config, whose section is
+// named by section.
+func validateGuild(problems []string, section string, guild GuildConfig) []string {
+	if guild.MaxSayLength < 0 {
+		problems = append(problems, section+".max_say_length can't be negative")
+	}
+
+	if guild.SayCooldown.Duration < 0 {
+		problems = append(problems, section+".say_cooldown can't be negative")
+	}
+
+	if guild.EditWindow.Duration < 0 {
+		problems = append(problems, section+".edit_window can't be negative")
+	}
+
+	if guild.Timezone != "" {
+		if _, err := loadLocation(guild.Timezone); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.timezone %q isn't a known time zone", section, guild.Timezone))
+		}
+	}
+
+	if name := guild.Voice.Name; strings.IndexFunc(name, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+		problems = append(problems, fmt.Sprintf("%s.voice.name %q isn't a voice name", section, name))
+	}
+
+	switch guild.Voice.Rate {
+	case "", "x-slow", "slow", "medium", "fast", "x-fast":
+	default:
+		problems = append(problems, fmt.Sprintf("%s.voice.rate %q isn't x-slow, slow, medium, fast or x-fast", section, guild.Voice.Rate))
+	}
+
+	return problems
+}
+
+// Validate checks the config for problems, reporting all of them at once.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Discord.Token == "" {
+		problems = append(problems, "discord.token is required")
+	}
+
+	if c.Voice.IdleTimeout.Duration < 0 {
+		problems = append(problems, "voice.idle_timeout can't be negative")
+	}
+
+	if c.Voice.PresenceDebounce.Duration < 0 {
+		problems = append(problems, "voice.presence_debounce can't be negative")
+	}
+
+	if c.Workers.Count <= 0 {
+		problems = append(problems, "workers.count must be positive")
+	}
+
+	if c.Workers.Queue <= 0 {
+		problems = append(problems, "workers.queue must be positive")
+	}
+
+	if c.Workers.Timeout.Duration <= 0 {
+		problems = append(problems, "workers.timeout must be positive")
+	}
+
+	for name, timeout := range c.pluginTimeouts {
+		if timeout <= 0 {
+			problems = append(problems, fmt.Sprintf("plugins.%s.timeout must be positive", name))
+		}
+	}
+
+	for name, value := range map[string]string{
+		"paths.opus":    c.Paths.Opus,
+		"paths.speech":  c.Paths.Speech,
+		"paths.store":   c.Paths.Store,
+		"paths.library": c.Paths.Library,
+	} {
+		if value == "" {
+			problems = append(problems, name+" can't be empty")
+		}
+	}
+
+	problems = validateGuild(problems, "defaults", c.Defaults)
+
+	for guildID, guild := range c.Guilds {
+		problems = validateGuild(problems, "guilds."+guildID, guild)
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+
+	return &ConfigError{Path: c.path, Problems: problems}
+}
+
+// LoadConfig reads and validates the config file at the path.
+func LoadConfig(configPath string) (*Config, error) {
+	config := DefaultConfig()
+	config.path = configPath
+
+	metadata, err := toml.DecodeFile(configPath, config)
+
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read config %s: %s", configPath, err)
+	}
+
+	config.metadata = metadata
+	config.pluginTimeouts = map[string]time.Duration{}
+
+	var problems []string
+
+	for name, primitive := range config.Plugins {
+		var section struct {
+			Timeout *Duration `toml:"timeout"`
+		}
+
+		if err := metadata.PrimitiveDecode(primitive, &section); err != nil {
+			problems = append(problems, fmt.Sprintf("plugins.%s: %s", name, err))
+		} else if section.Timeout != nil {
+			config.pluginTimeouts[name] = section.Timeout.Duration
+		}
+	}
+
+	for _, key := range metadata.Undecoded() {
+		// Plugins check their own sections when they decode them.
+		if len(key) > 1 && key[0] == "plugins" {
+			continue
+		}
+
+		problems = append(problems, "Unknown key "+key.String())
+	}
+
+	if err := config.Validate(); err != nil {
+		problems = append(problems, err.(*ConfigError).Problems...)
+	}
+
+	if len(problems) > 0 {
+		return nil, &ConfigError{Path: configPath, Problems: problems}
+	}
+
+	return config, nil
+}
+
+// Config is the bot's current configuration. Reloading replaces it rather than
+// modifying it, so it must not be modified.
+func (b *Bot) Config() *Config {
+	b.configLock.RLock()
+	defer b.configLock.RUnlock()
+
+	return b.config
+}
+
+// checkNames reports the disabled commands and previewers, and the plugin
+// sections, in the config that weren't registered, which are likely typos.
+func (b *Bot) checkNames(config *Config) error {
+	var problems []string
+
+	check := func(section string, guild GuildConfig) {
+		for _, path := range guild.DisabledCommands {
+			if !b.router.exists(path) {
+				problems = append(problems, fmt.Sprintf("%s.disabled_commands: unknown command %q", section, path))
+			}
+		}
+
+		for _, name := range guild.DisabledPreviewers {
+			known := false
+
+			for _, previewer := range b.previewers {
+				known = known || previewer.name == name
+			}
+
+			if !known {
+				problems = append(problems, fmt.Sprintf("%s.disabled_previewers: unknown previewer %q", section, name))
+			}
+		}
+	}
+
+	check("defaults", config.Defaults)
+
+	for guildID, guild := range config.Guilds {
+		check("guilds."+guildID, guild)
+	}
+
+	for name := range config.Plugins {
+		known := false
+
+		for _, plugin := range b.plugins {
+			known = known || plugin.name == name
+		}
+
+		if !known {
+			problems = append(problems, fmt.Sprintf("plugins.%s: unknown plugin", name))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+
+	sort.Strings(problems)
+
+	return &ConfigError{Path: config.path, Problems: problems}
+}
+
+// ReloadConfig rereads the config file, keeping the current config if the new
+// one is invalid. The discord, ivona and paths sections only take effect after
+// a restart.
+func (b *Bot) ReloadConfig() error {
+	config, err := LoadConfig(b.Config().path)
+
+	if err != nil {
+		return err
+	}
+
+	if err := b.checkNames(config); err != nil {
+		return err
+	}
+
+	b.configLock.Lock()
+	b.config = config
+	b.configLock.Unlock()
+
+	b.sessionLog.WithField("path", config.path).Info("Reloaded config")
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, contents string) (string, func()) {
+	dir, err := ioutil.TempDir("", "bmo")
+
+	assert.NoError(t, err)
+
+	configPath := path.Join(dir, "bmo.toml")
+
+	assert.NoError(t, ioutil.WriteFile(configPath, []byte(contents), 0644))
+
+	return configPath, func() { os.RemoveAll(dir) }
+}
+
+func TestLoadConfig(t *testing.T) {
+	configPath, cleanup := writeConfig(t, `
+[discord]
+token = "token"
+
+[voice]
+idle_timeout = "1m"
+
+[defaults]
+disabled_commands = ["radio add"]
+
+[defaults.voice]
+name = "Brian"
+
+[guilds.1]
+max_say_length = 50
+timezone = "America/Los_Angeles"
+disabled_commands = []
+
+[guilds.1.voice]
+rate = "fast"
+
+[plugins.hn]
+poll_interval = "10m"
+timeout = "5s"
+`)
+
+	defer cleanup()
+
+	config, err := LoadConfig(configPath)
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "token", config.Discord.Token)
+	assert.Equal(t, time.Minute, config.Voice.IdleTimeout.Duration)
+	assert.Equal(t, 5*time.Second, config.Voice.PresenceDebounce.Duration)
+	assert.True(t, config.Voice.ResumePlayback)
+	assert.Equal(t, "./data/opus", config.Paths.Opus)
+
+	defaults := config.Guild("2")
+
+	assert.Equal(t, 200, defaults.MaxSayLength)
+	assert.Equal(t, VoiceSettings{Name: "Brian"}, defaults.Voice)
+	assert.False(t, defaults.CommandEnabled("radio add"))
+	assert.True(t, defaults.CommandEnabled("radio list"))
+	assert.Equal(t, time.UTC, defaults.Location())
+
+	guild := config.Guild("1")
+
+	assert.Equal(t, 50, guild.MaxSayLength)
+	assert.Equal(t, 10*time.Second, guild.SayCooldown.Duration)
+	assert.Equal(t, VoiceSettings{Name: "Brian", Rate: "fast"}, guild.Voice)
+	assert.True(t, guild.CommandEnabled("radio add"))
+	assert.Equal(t, "America/Los_Angeles", guild.Location().String())
+	assert.Equal(t, 2*time.Minute, guild.EditWindow.Duration)
+
+	var hn struct {
+		PollInterval Duration `toml:"poll_interval"`
+	}
+
+	assert.True(t, config.Plugin("hn").Present())
+	assert.NoError(t, config.Plugin("hn").Decode(&hn))
+	assert.Equal(t, 10*time.Minute, hn.PollInterval.Duration)
+
+	assert.Equal(t, 5*time.Second, config.PluginTimeout("hn"))
+	assert.Equal(t, 30*time.Second, config.PluginTimeout("reddit"))
+
+	assert.False(t, config.Plugin("reddit").Present())
+	assert.NoError(t, config.Plugin("reddit").Decode(&hn))
+}
+
+func TestLoadConfigDeprecatedPaths(t *testing.T) {
+	configPath, cleanup := writeConfig(t, `
+[discord]
+token = "token"
+
+[paths]
+queue = "./old/queue.json"
+history = "./old/history.jsonl"
+`)
+
+	defer cleanup()
+
+	config, err := LoadConfig(configPath)
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "./old/queue.json", config.Paths.Queue)
+	assert.Equal(t, "./old/history.jsonl", config.Paths.History)
+}
+
+func TestLoadConfigProblems(t *testing.T) {
+	configPath, cleanup := writeConfig(t, `
+[voice]
+idle_timeout = "-1m"
+typo = true
+
+[guilds.1]
+timezone = "Nowhere/Special"
+
+[guilds.1.voice]
+rate = "ludicrous"
+`)
+
+	defer cleanup()
+
+	_, err := LoadConfig(configPath)
+
+	configErr, ok := err.(*ConfigError)
+
+	assert.True(t, ok)
+	assert.Equal(t, []string{
+		"Unknown key voice.typo",
+		"discord.token is required",
+		`guilds.1.timezone "Nowhere/Special" isn't a known time zone`,
+		`guilds.1.voice.rate "ludicrous" isn't x-slow, slow, medium, fast or x-fast`,
+		"voice.idle_timeout can't be negative",
+	}, configErr.Problems)
+}
+
+func TestLoadConfigSyntaxError(t *testing.T) {
+	configPath, cleanup := writeConfig(t, `[discord`)
+
+	defer cleanup()
+
+	_, err := LoadConfig(configPath)
+
+	assert.Error(t, err)
+}
+
+func TestCheckNames(t *testing.T) {
+	b := &Bot{router: testRouter()}
+	b.RegisterPreviewer("hn", nil)
+
+	config := DefaultConfig()
+	config.Defaults.DisabledCommands = []string{"voice rate", "say"}
+	config.Defaults.DisabledPreviewers = []string{"hn"}
+
+	assert.NoError(t, b.checkNames(config))
+
+	config.Guilds["1"] = GuildConfig{
+		DisabledCommands:   []string{"voice pitch", "p"},
+		DisabledPreviewers: []string{"reddit"},
+	}
+
+	config.Plugins = map[string]toml.Primitive{"hn": {}, "reddit": {}}
+
+	err := b.checkNames(config)
+
+	assert.Equal(t, []string{
+		`guilds.1.disabled_commands: unknown command "p"`,
+		`guilds.1.disabled_commands: unknown command "voice pitch"`,
+		`guilds.1.disabled_previewers: unknown previewer "reddit"`,
+		"plugins.reddit: unknown plugin",
+	}, err.(*ConfigError).Problems)
+}
+
+func TestOpenSessionInvalidNames(t *testing.T) {
+	config := DefaultConfig()
+	config.Defaults.DisabledCommands = []string{"nope"}
+
+	b, err := New(config, NewMemoryStore())
+	assert.NoError(t, err)
+
+	// The names are checked before the session is used.
+	err = b.OpenSession(nil)
+
+	if assert.IsType(t, &ConfigError{}, err) {
+		assert.Equal(t, []string{`defaults.disabled_commands: unknown command "nope"`}, err.(*ConfigError).Problems)
+	}
+}
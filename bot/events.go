@@ -0,0 +1,68 @@
+package bot
+
+import "net/url"
+
+// VoiceJoinEvent is published when a user joins a voice channel.
+type VoiceJoinEvent struct {
+	GuildID   string
+	UserID    string
+	ChannelID string
+}
+
+// VoiceLeaveEvent is published when a user leaves a voice channel without
+// joining another.
+type VoiceLeaveEvent struct {
+	GuildID   string
+	UserID    string
+	ChannelID string
+}
+
+// VoiceMoveEvent is published when a user moves from one voice channel to
+// another.
+type VoiceMoveEvent struct {
+	GuildID       string
+	UserID        string
+	FromChannelID string
+	ToChannelID   string
+}
+
+// TrackQueuedEvent is published when audio is queued, including speech.
+type TrackQueuedEvent struct {
+	Event *AudioEvent
+}
+
+// TrackStartedEvent is published when queued audio starts playing. Paused
+// audio that resumes starts again.
+type TrackStartedEvent struct {
+	Event *AudioEvent
+}
+
+// TrackFinishedEvent is published when audio stops playing because it ended,
+// was skipped or was cleared, but not when it was paused.
+type TrackFinishedEvent struct {
+	Event *AudioEvent
+}
+
+// TrackFailedEvent is published when audio couldn't be played.
+type TrackFailedEvent struct {
+	Event *AudioEvent
+	Err   error
+}
+
+// CommandExecutedEvent is published after a command's handler returns.
+type CommandExecutedEvent struct {
+	Invocation *Invocation
+}
+
+// PreviewSentEvent is published when a previewer previews a URL.
+type PreviewSentEvent struct {
+	Previewer string
+	GuildID   string
+	ChannelID string
+
+	// MessageID is the message with the URL and PreviewID is the preview.
+	MessageID string
+	PreviewID string
+
+	URL *url.URL
+}
@@ -0,0 +1,301 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/bwmarrin/discordgo"
+)
+
+// applicationCommandName is the pattern Discord requires of application command
+// and option names.
+var applicationCommandName = regexp.MustCompile(`^[-_a-z0-9]{1,32}$`)
+
+// applicationCommandDescription truncates or fills in a description to satisfy
+// Discord's 1 to 100 character limit.
+func applicationCommandDescription(description, fallback string) string {
+	if description == "" {
+		description = fallback
+	}
+
+	if utf8.RuneCountInString(description) > 100 {
+		description = string([]rune(description)[:97]) + "..."
+	}
+
+	return description
+}
+
+func (k ArgKind) applicationCommandOptionType() discordgo.ApplicationCommandOptionType {
+	switch k {
+	case ArgInt:
+		return discordgo.ApplicationCommandOptionInteger
+	case ArgBool:
+		return discordgo.ApplicationCommandOptionBoolean
+	default:
+		return discordgo.ApplicationCommandOptionString
+	}
+}
+
+// applicationCommandOptions describes the command's arguments, flags and
+// subcommands as application command options. Discord only allows subcommands
+// to be nested two levels deep, so depth is the current level.
+func (c *Command) applicationCommandOptions(depth int) ([]*discordgo.ApplicationCommandOption, error) {
+	var options []*discordgo.ApplicationCommandOption
+
+	if len(c.Subcommands) > 0 {
+		if depth >= 2 {
+			return nil, fmt.Errorf("Subcommands of %q are nested too deeply", c.Name)
+		}
+
+		if c.Handler != nil {
+			return nil, fmt.Errorf("Command %q has both subcommands and a handler", c.Name)
+		}
+
+		for _, subcommand := range c.Subcommands {
+			if !applicationCommandName.MatchString(subcommand.Name) {
+				return nil, fmt.Errorf("Invalid application command name %q", subcommand.Name)
+			}
+
+			suboptions, err := subcommand.applicationCommandOptions(depth + 1)
+
+			if err != nil {
+				return nil, err
+			}
+
+			optionType := discordgo.ApplicationCommandOptionSubCommand
+
+			if len(subcommand.Subcommands) > 0 {
+				optionType = discordgo.ApplicationCommandOptionSubCommandGroup
+			}
+
+			options = append(options, &discordgo.ApplicationCommandOption{
+				Type:        optionType,
+				Name:        subcommand.Name,
+				Description: applicationCommandDescription(subcommand.Description, subcommand.Name),
+				Options:     suboptions,
+			})
+		}
+
+		return options, nil
+	}
+
+	for _, arg := range c.Args {
+		if !applicationCommandName.MatchString(arg.Name) {
+			return nil, fmt.Errorf("Invalid application command option name %q", arg.Name)
+		}
+
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Type:        arg.Kind.applicationCommandOptionType(),
+			Name:        arg.Name,
+			Description: applicationCommandDescription(arg.Description, arg.Name),
+			Required:    !arg.Optional,
+		})
+	}
+
+	for _, flag := range c.Flags {
+		if !applicationCommandName.MatchString(flag.Name) {
+			return nil, fmt.Errorf("Invalid application command option name %q", flag.Name)
+		}
+
+		options = append(options, &discordgo.ApplicationCommandOption{
+			Type:        flag.Kind.applicationCommandOptionType(),
+			Name:        flag.Name,
+			Description: applicationCommandDescription(flag.Description, flag.Name),
+		})
+	}
+
+	return options, nil
+}
+
+// ApplicationCommands describes the registered commands as Discord application
+// commands. Commands that can't be expressed as application commands are
+// skipped and logged.
+func (r *Router) ApplicationCommands() []*discordgo.ApplicationCommand {
+	var commands []*discordgo.ApplicationCommand
+
+	for _, command := range r.commands {
+		logger := log.WithFields(log.Fields{
+			"topic":   "chat",
+			"command": command.Name,
+		})
+
+		if !applicationCommandName.MatchString(command.Name) {
+			logger.Warn("Skipping command with invalid application command name")
+			continue
+		}
+
+		options, err := command.applicationCommandOptions(0)
+
+		if err != nil {
+			logger.WithError(err).Warn("Skipping command that can't be an application command")
+			continue
+		}
+
+		commands = append(commands, &discordgo.ApplicationCommand{
+			Name:        command.Name,
+			Description: applicationCommandDescription(command.Description, command.Name),
+			Options:     options,
+		})
+	}
+
+	return commands
+}
+
+// routeInteraction resolves the application command interaction to a command
+// and its parsed invocation.
+func (r *Router) routeInteraction(data discordgo.ApplicationCommandInteractionData) (*Invocation, error) {
+	command := r.lookup(data.Name)
+
+	if command == nil {
+		return nil, fmt.Errorf("I don't know the command `%s`.", data.Name)
+	}
+
+	path := command.Name
+	permission := command.Permission
+	cooldown := command.Cooldown
+	options := data.Options
+
+	for len(options) == 1 && (options[0].Type == discordgo.ApplicationCommandOptionSubCommand ||
+		options[0].Type == discordgo.ApplicationCommandOptionSubCommandGroup) {
+		subcommand := command.subcommand(options[0].Name)
+
+		if subcommand == nil {
+			return nil, fmt.Errorf("I don't know the command `%s %s`.", path, options[0].Name)
+		}
+
+		command = subcommand
+		path += " " + subcommand.Name
+		options = options[0].Options
+
+		if subcommand.Permission != "" {
+			permission = subcommand.Permission
+		}
+
+		if !subcommand.Cooldown.isZero() {
+			cooldown = subcommand.Cooldown
+		}
+	}
+
+	if command.Handler == nil {
+		return nil, &UsageError{Usage: command.usage(path), Reason: "Which one?"}
+	}
+
+	invocation := &Invocation{
+		Command:    command,
+		path:       path,
+		permission: permission,
+		cooldown:   cooldown,
+		args:       map[string]interface{}{},
+		flags:      map[string]interface{}{},
+	}
+
+	kinds := map[string]ArgKind{}
+	isArg := map[string]bool{}
+
+	for _, flag := range command.Flags {
+		kinds[flag.Name] = flag.Kind
+	}
+
+	for _, arg := range command.Args {
+		kinds[arg.Name] = arg.Kind
+		isArg[arg.Name] = true
+	}
+
+	for _, option := range options {
+		kind, ok := kinds[option.Name]
+
+		if !ok {
+			return nil, &UsageError{Usage: command.usage(path), Reason: "Unknown option " + option.Name}
+		}
+
+		values := invocation.flags
+
+		if isArg[option.Name] {
+			values = invocation.args
+		}
+
+		switch kind {
+		case ArgInt:
+			values[option.Name] = int(option.IntValue())
+
+		case ArgBool:
+			values[option.Name] = option.BoolValue()
+
+		default:
+			parsed, err := parseValue(kind, option.StringValue())
+
+			if err != nil {
+				return nil, &UsageError{Usage: command.usage(path), Reason: option.Name + ": " + err.Error()}
+			}
+
+			values[option.Name] = parsed
+		}
+	}
+
+	return invocation, nil
+}
+
+// registerApplicationCommands registers the Router's commands as global
+// application commands, replacing any that were registered before.
+func (b *Bot) registerApplicationCommands() {
+	commands := b.router.ApplicationCommands()
+
+	if _, err := b.session.ApplicationCommandBulkOverwrite(b.userID, "", commands); err != nil {
+		b.sessionLog.WithError(err).Error("Couldn't register application commands")
+		return
+	}
+
+	b.sessionLog.WithField("count", len(commands)).Info("Registered application commands")
+}
+
+func (b *Bot) onInteractionCreate(_ *discordgo.Session, event *discordgo.InteractionCreate) {
+	if event.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
+
+	interaction := event.Interaction
+
+	author := interaction.User
+
+	if interaction.Member != nil {
+		author = interaction.Member.User
+	}
+
+	data := interaction.ApplicationCommandData()
+
+	logger := b.chatLog.WithFields(log.Fields{
+		"command": data.Name,
+		"user":    author.ID,
+	})
+
+	invocation, err := b.router.routeInteraction(data)
+
+	if err != nil {
+		invocation = &Invocation{}
+	}
+
+	invocation.Bot = b
+	invocation.Author = author
+	invocation.ChannelID = interaction.ChannelID
+	invocation.GuildID = interaction.GuildID
+	invocation.interaction = interaction
+
+	if err != nil {
+		invocation.Reply(strings.TrimSpace(err.Error()))
+		return
+	}
+
+	if !invocation.permitted() || !invocation.withinCooldown() {
+		return
+	}
+
+	logger.Info("Dispatching application command")
+
+	b.dispatch(invocation.orderKey(), invocation.Command.plugin, func(ctx context.Context) {
+		b.router.execute(ctx, invocation)
+	})
+}
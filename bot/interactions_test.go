@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplicationCommands(t *testing.T) {
+	commands := testRouter().ApplicationCommands()
+
+	names := map[string]*discordgo.ApplicationCommand{}
+
+	for _, command := range commands {
+		names[command.Name] = command
+	}
+
+	assert.Len(t, commands, 5)
+
+	play := names["play"]
+
+	if assert.NotNil(t, play) && assert.Len(t, play.Options, 3) {
+		assert.True(t, play.Options[0].Required)
+		assert.Equal(t, discordgo.ApplicationCommandOptionBoolean, play.Options[2].Type)
+	}
+
+	voice := names["voice"]
+
+	if assert.NotNil(t, voice) && assert.Len(t, voice.Options, 1) {
+		assert.Equal(t, discordgo.ApplicationCommandOptionSubCommand, voice.Options[0].Type)
+		assert.Equal(t, discordgo.ApplicationCommandOptionInteger, voice.Options[0].Options[0].Type)
+	}
+}
+
+func TestApplicationCommandDescription(t *testing.T) {
+	assert.Equal(t, "fallback", applicationCommandDescription("", "fallback"))
+	assert.Equal(t, "short", applicationCommandDescription("short", "fallback"))
+
+	description := applicationCommandDescription(strings.Repeat("é", 150), "")
+
+	assert.True(t, utf8.ValidString(description))
+	assert.Equal(t, 100, utf8.RuneCountInString(description))
+	assert.Equal(t, strings.Repeat("é", 97)+"...", description)
+}
+
+func TestRouteInteraction(t *testing.T) {
+	router := testRouter()
+
+	invocation, err := router.routeInteraction(discordgo.ApplicationCommandInteractionData{
+		Name: "play",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{Name: "url", Type: discordgo.ApplicationCommandOptionString, Value: "http://example.com"},
+			{Name: "from", Type: discordgo.ApplicationCommandOptionString, Value: "1:30"},
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "http://example.com", invocation.String("url"))
+	assert.Equal(t, 90*time.Second, invocation.Duration("from"))
+
+	invocation, err = router.routeInteraction(discordgo.ApplicationCommandInteractionData{
+		Name: "voice",
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{
+				Name: "rate",
+				Type: discordgo.ApplicationCommandOptionSubCommand,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					// Discord sends integers as JSON numbers.
+					{Name: "n", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(4)},
+				},
+			},
+		},
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "rate", invocation.Command.Name)
+	assert.Equal(t, 4, invocation.Int("n"))
+}
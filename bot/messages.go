@@ -0,0 +1,219 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxMessageRecords is how many received messages are remembered. Older
+// messages are forgotten, so edits and deletes of them are ignored.
+const maxMessageRecords = 1000
+
+// messageRecord is what the bot remembers about a message it received, so that
+// it can handle the message being edited or deleted.
+type messageRecord struct {
+	channelID string
+	received  time.Time
+
+	// commandText is the command the message last invoked, if any.
+	commandText string
+
+	// previewed are the URLs in the message that were previewed.
+	previewed map[string]bool
+
+	// responses are the IDs of the messages the bot sent in response, such as
+	// replies and previews.
+	responses []string
+}
+
+// messageRecords remembers the most recently received messages.
+type messageRecords struct {
+	lock    sync.Mutex
+	records map[string]*messageRecord
+
+	// order is the IDs of the messages in the order they were received. It may
+	// contain messages that were already forgotten.
+	order []string
+}
+
+func newMessageRecords() *messageRecords {
+	return &messageRecords{records: map[string]*messageRecord{}}
+}
+
+// add remembers the message, forgetting the oldest message if there are too
+// many.
+func (r *messageRecords) add(msg *discordgo.Message) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.records[msg.ID]; ok {
+		return
+	}
+
+	r.records[msg.ID] = &messageRecord{
+		channelID: msg.ChannelID,
+		received:  time.Now(),
+		previewed: map[string]bool{},
+	}
+
+	r.order = append(r.order, msg.ID)
+
+	for len(r.order) > maxMessageRecords {
+		delete(r.records, r.order[0])
+		r.order = r.order[1:]
+	}
+}
+
+// receivedAt is when the message was received, if it's remembered.
+func (r *messageRecords) receivedAt(messageID string) (time.Time, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	record, ok := r.records[messageID]
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return record.received, true
+}
+
+// setCommandText records the command the message invokes, reporting whether
+// it's different from the one it invoked before.
+func (r *messageRecords) setCommandText(messageID, text string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	record, ok := r.records[messageID]
+
+	if !ok {
+		return true
+	}
+
+	changed := record.commandText != text
+	record.commandText = text
+
+	return changed
+}
+
+// markPreviewed records that the message's URL is being previewed, reporting
+// whether it wasn't already.
+func (r *messageRecords) markPreviewed(messageID, url string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	record, ok := r.records[messageID]
+
+	if !ok {
+		return true
+	}
+
+	if record.previewed[url] {
+		return false
+	}
+
+	record.previewed[url] = true
+
+	return true
+}
+
+// addResponse records that the bot responded to the message with another.
+func (r *messageRecords) addResponse(messageID, responseID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if record, ok := r.records[messageID]; ok {
+		record.responses = append(record.responses, responseID)
+	}
+}
+
+// remove forgets the message, returning its record if it was remembered.
+func (r *messageRecords) remove(messageID string) (*messageRecord, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	record, ok := r.records[messageID]
+
+	if ok {
+		delete(r.records, messageID)
+	}
+
+	return record, ok
+}
+
+// RecordResponse records that the bot sent the response because of the
+// message, so that the response is deleted if the message is. Replies sent
+// with ReplyToMessage and previews announced with PreviewSentEvent are
+// recorded already.
+func (b *Bot) RecordResponse(msg, response *discordgo.Message) {
+	b.messages.addResponse(msg.ID, response.ID)
+}
+
+func (b *Bot) onPreviewSent(event *PreviewSentEvent) {
+	b.messages.addResponse(event.MessageID, event.PreviewID)
+}
+
+// withinEditWindow checks whether the message was received recently enough
+// that editing it is handled like sending it again.
+func (b *Bot) withinEditWindow(msg *discordgo.Message) bool {
+	received, ok := b.messages.receivedAt(msg.ID)
+
+	if !ok {
+		return false
+	}
+
+	guildID, _ := b.MessageGuildID(msg)
+
+	return time.Since(received) <= b.Config().Guild(guildID).EditWindow.Duration
+}
+
+// onMessageUpdate handles an edit to a recent message: it's routed again if it
+// now invokes a different command, and URLs added to it are previewed.
+// Commanders registered through RegisterCommand only see new messages.
+func (b *Bot) onMessageUpdate(_ *discordgo.Session, update *discordgo.MessageUpdate) {
+	msg := update.Message
+
+	// Updates that only add embeds to a message aren't edits.
+	if msg.Author == nil || msg.EditedTimestamp == nil || b.IsSelf(msg.Author.ID) {
+		return
+	}
+
+	if !b.withinEditWindow(msg) {
+		return
+	}
+
+	b.chatLog.WithField("message", msg.ID).Info("Received message edit")
+
+	b.previewURLs(msg)
+
+	b.routeCommand(msg)
+}
+
+// onMessageDelete deletes the bot's responses to the deleted message.
+func (b *Bot) onMessageDelete(_ *discordgo.Session, msg *discordgo.MessageDelete) {
+	b.deleteResponses(msg.ID)
+}
+
+func (b *Bot) onMessageDeleteBulk(_ *discordgo.Session, bulk *discordgo.MessageDeleteBulk) {
+	for _, messageID := range bulk.Messages {
+		b.deleteResponses(messageID)
+	}
+}
+
+// deleteResponses deletes the messages the bot sent in response to the
+// message, which is forgotten.
+func (b *Bot) deleteResponses(messageID string) {
+	record, ok := b.messages.remove(messageID)
+
+	if !ok {
+		return
+	}
+
+	for _, responseID := range record.responses {
+		if err := b.session.ChannelMessageDelete(record.channelID, responseID); err != nil {
+			b.chatLog.WithError(err).WithField("message", responseID).Error("Couldn't delete response")
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageRecords(t *testing.T) {
+	records := newMessageRecords()
+
+	records.add(&discordgo.Message{ID: "1", ChannelID: "channel"})
+
+	assert.True(t, records.markPreviewed("1", "https://example.com"))
+	assert.False(t, records.markPreviewed("1", "https://example.com"))
+	assert.True(t, records.markPreviewed("1", "https://example.org"))
+
+	assert.False(t, records.setCommandText("1", ""))
+	assert.True(t, records.setCommandText("1", "ping"))
+	assert.False(t, records.setCommandText("1", "ping"))
+
+	records.addResponse("1", "2")
+	records.addResponse("1", "3")
+
+	record, ok := records.remove("1")
+
+	assert.True(t, ok)
+	assert.Equal(t, "channel", record.channelID)
+	assert.Equal(t, []string{"2", "3"}, record.responses)
+
+	_, ok = records.remove("1")
+	assert.False(t, ok)
+}
+
+func TestMessageRecordsForgetOldest(t *testing.T) {
+	records := newMessageRecords()
+
+	for i := 0; i <= maxMessageRecords; i++ {
+		records.add(&discordgo.Message{ID: fmt.Sprint(i)})
+	}
+
+	_, ok := records.receivedAt("0")
+	assert.False(t, ok)
+
+	_, ok = records.receivedAt("1")
+	assert.True(t, ok)
+
+	_, ok = records.receivedAt(fmt.Sprint(maxMessageRecords))
+	assert.True(t, ok)
+}
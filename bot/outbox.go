@@ -0,0 +1,203 @@
+package bot
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxSendAttempts is how many times a part of a message is sent before giving
+// up on it, if sending fails transiently.
+const maxSendAttempts = 3
+
+// outgoing is content waiting to be sent to a channel.
+type outgoing struct {
+	ctx   context.Context
+	parts []string
+
+	// post sends a part, e.g. as a message or as an interaction's follow-up.
+	post func(part string) (*discordgo.Message, error)
+
+	sent chan outgoingResult
+}
+
+type outgoingResult struct {
+	messages []*discordgo.Message
+	err      error
+}
+
+// outbox sends messages to each channel in the order they're queued, one at a
+// time, so that the parts of a long message aren't interleaved with others.
+type outbox struct {
+	bot *Bot
+
+	lock sync.Mutex
+
+	// queues are the messages waiting for each channel. A channel has a queue
+	// while its messages are being sent.
+	queues map[string][]*outgoing
+
+	// retryDelay is how long to wait before sending again after the first
+	// transient failure. It doubles after each one.
+	retryDelay time.Duration
+}
+
+func newOutbox(bot *Bot) *outbox {
+	return &outbox{
+		bot:        bot,
+		queues:     map[string][]*outgoing{},
+		retryDelay: time.Second,
+	}
+}
+
+// send queues the parts for the channel and waits until post sent them, or
+// until the context is done, returning the messages that were sent.
+func (o *outbox) send(ctx context.Context, channelID string, parts []string, post func(string) (*discordgo.Message, error)) ([]*discordgo.Message, error) {
+	item := &outgoing{
+		ctx:   ctx,
+		parts: parts,
+		post:  post,
+		sent:  make(chan outgoingResult, 1),
+	}
+
+	o.lock.Lock()
+
+	queue, sending := o.queues[channelID]
+	o.queues[channelID] = append(queue, item)
+
+	if !sending {
+		go o.drain(channelID)
+	}
+
+	o.lock.Unlock()
+
+	select {
+	case result := <-item.sent:
+		return result.messages, result.err
+
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// drain sends the channel's queued messages until there are none left.
+func (o *outbox) drain(channelID string) {
+	for {
+		o.lock.Lock()
+
+		queue := o.queues[channelID]
+
+		if len(queue) == 0 {
+			delete(o.queues, channelID)
+			o.lock.Unlock()
+
+			return
+		}
+
+		item := queue[0]
+		o.queues[channelID] = queue[1:]
+
+		o.lock.Unlock()
+
+		messages, err := o.deliver(channelID, item)
+
+		item.sent <- outgoingResult{messages: messages, err: err}
+	}
+}
+
+// deliver sends each part in order, stopping at the first that can't be sent.
+func (o *outbox) deliver(channelID string, item *outgoing) ([]*discordgo.Message, error) {
+	var messages []*discordgo.Message
+
+	for _, part := range item.parts {
+		message, err := o.sendPart(item.ctx, channelID, part, item.post)
+
+		if err != nil {
+			return messages, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
+
+// sendPart sends a part, retrying with increasing delays if it fails
+// transiently.
+func (o *outbox) sendPart(ctx context.Context, channelID, part string, post func(string) (*discordgo.Message, error)) (*discordgo.Message, error) {
+	delay := o.retryDelay
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		message, err := post(part)
+
+		if err == nil || !transient(err) || attempt == maxSendAttempts {
+			return message, err
+		}
+
+		o.bot.chatLog.WithError(err).WithFields(log.Fields{
+			"channel": channelID,
+			"attempt": attempt,
+		}).Warn("Couldn't send message, retrying")
+
+		select {
+		case <-time.After(delay):
+			delay *= 2
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// transient checks whether the error is one that sending again may not run
+// into, such as Discord failing or the connection dropping.
+func transient(err error) bool {
+	switch err := err.(type) {
+	case *discordgo.RESTError:
+		return err.Response != nil && err.Response.StatusCode >= 500
+
+	case net.Error:
+		return true
+	}
+
+	return false
+}
+
+// SendMessage sends the content to the channel, split into as many messages as
+// it takes. Messages sent with SendMessage and Reply are sent to each channel
+// in order, and retried if sending fails transiently. It returns the messages
+// that were sent, which may be some of them if it fails.
+func (b *Bot) SendMessage(ctx context.Context, channelID, content string) ([]*discordgo.Message, error) {
+	return b.outbox.send(ctx, channelID, SplitMessage(content, MaxMessageLength), func(part string) (*discordgo.Message, error) {
+		return b.Session().ChannelMessageSend(channelID, part)
+	})
+}
+
+// Reply sends the content in reply to the message like SendMessage, with each
+// part referencing the message so that they stay threaded to it. The parts are
+// deleted if the message is.
+func (b *Bot) Reply(ctx context.Context, msg *discordgo.Message, content string) ([]*discordgo.Message, error) {
+	reference := &discordgo.MessageReference{
+		MessageID: msg.ID,
+		ChannelID: msg.ChannelID,
+		GuildID:   msg.GuildID,
+	}
+
+	messages, err := b.outbox.send(ctx, msg.ChannelID, SplitMessage(content, MaxMessageLength), func(part string) (*discordgo.Message, error) {
+		return b.Session().ChannelMessageSendReply(msg.ChannelID, part, reference)
+	})
+
+	for _, message := range messages {
+		b.RecordResponse(msg, message)
+	}
+
+	return messages, err
+}
@@ -0,0 +1,201 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakySession fails the first sends with a server error.
+type flakySession struct {
+	Session
+
+	lock     sync.Mutex
+	failures int
+	sent     []*discordgo.Message
+}
+
+func (s *flakySession) send(message *discordgo.Message) (*discordgo.Message, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+
+		return nil, &discordgo.RESTError{Response: &http.Response{StatusCode: http.StatusBadGateway}}
+	}
+
+	s.sent = append(s.sent, message)
+
+	return message, nil
+}
+
+func (s *flakySession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{ChannelID: channelID, Content: content})
+}
+
+func (s *flakySession) ChannelMessageSendReply(channelID, content string, reference *discordgo.MessageReference) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{ChannelID: channelID, Content: content, MessageReference: reference})
+}
+
+func (s *flakySession) InteractionRespond(interaction *discordgo.Interaction, response *discordgo.InteractionResponse) error {
+	_, err := s.send(&discordgo.Message{ChannelID: interaction.ChannelID, Content: response.Data.Content})
+	return err
+}
+
+func (s *flakySession) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, params *discordgo.WebhookParams) (*discordgo.Message, error) {
+	return s.send(&discordgo.Message{ChannelID: interaction.ChannelID, Content: params.Content, Type: discordgo.MessageTypeReply})
+}
+
+func newOutboxBot(session Session) *Bot {
+	b := &Bot{
+		session:  session,
+		messages: newMessageRecords(),
+		chatLog:  log.WithField("topic", "chat"),
+	}
+
+	b.outbox = newOutbox(b)
+	b.outbox.retryDelay = time.Millisecond
+
+	return b
+}
+
+func TestOutboxRetriesTransientErrors(t *testing.T) {
+	session := &flakySession{failures: 2}
+	b := newOutboxBot(session)
+
+	messages, err := b.SendMessage(context.Background(), "channel", "hello")
+
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Len(t, session.sent, 1)
+
+	session.failures = maxSendAttempts
+
+	_, err = b.SendMessage(context.Background(), "channel", "hello")
+
+	assert.Error(t, err)
+	assert.Len(t, session.sent, 1)
+}
+
+func TestOutboxKeepsPartsTogether(t *testing.T) {
+	session := &flakySession{}
+	b := newOutboxBot(session)
+
+	var wait sync.WaitGroup
+
+	for _, letter := range []string{"a", "b", "c"} {
+		wait.Add(1)
+
+		go func(letter string) {
+			defer wait.Done()
+
+			content := strings.Repeat(strings.Repeat(letter, 600)+"\n\n", 5)
+
+			messages, err := b.SendMessage(context.Background(), "channel", content)
+
+			assert.NoError(t, err)
+			assert.Len(t, messages, 2)
+		}(letter)
+	}
+
+	wait.Wait()
+
+	assert.Len(t, session.sent, 6)
+
+	// Each message's parts are sent one after another.
+	for i := 0; i < len(session.sent); i += 2 {
+		letter := session.sent[i].Content[:1]
+
+		for _, part := range session.sent[i : i+2] {
+			assert.True(t, strings.HasPrefix(part.Content, letter))
+		}
+	}
+}
+
+func TestReplyReferencesMessage(t *testing.T) {
+	session := &flakySession{}
+	b := newOutboxBot(session)
+
+	msg := &discordgo.Message{ID: "1", ChannelID: "channel", GuildID: "guild"}
+	b.messages.add(msg)
+
+	messages, err := b.Reply(context.Background(), msg, strings.Repeat("word ", 500))
+
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+
+	for _, message := range messages {
+		assert.Equal(t, "1", message.MessageReference.MessageID)
+		assert.Equal(t, "guild", message.MessageReference.GuildID)
+	}
+
+	record, _ := b.messages.remove("1")
+
+	assert.Len(t, record.responses, 2)
+}
+
+func TestOutboxCancelled(t *testing.T) {
+	b := newOutboxBot(&flakySession{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := b.SendMessage(ctx, "channel", "hello")
+
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestInvocationReplySplits(t *testing.T) {
+	session := &flakySession{}
+	b := newOutboxBot(session)
+
+	msg := &discordgo.Message{ID: "1", ChannelID: "channel", Author: &discordgo.User{ID: "user"}}
+	b.messages.add(msg)
+
+	invocation := &Invocation{Bot: b, Message: msg, Author: msg.Author, ChannelID: "channel"}
+	invocation.Reply(strings.Repeat("word ", 500))
+
+	if assert.Len(t, session.sent, 2) {
+		assert.True(t, strings.HasPrefix(session.sent[0].Content, "<@user>: word"))
+	}
+
+	record, _ := b.messages.remove("1")
+
+	assert.Len(t, record.responses, 2)
+}
+
+func TestInteractionReplySplits(t *testing.T) {
+	session := &flakySession{}
+	b := newOutboxBot(session)
+
+	invocation := &Invocation{
+		Bot:         b,
+		Author:      &discordgo.User{ID: "user"},
+		ChannelID:   "channel",
+		interaction: &discordgo.Interaction{ChannelID: "channel"},
+	}
+
+	invocation.Reply(strings.Repeat("word ", 500))
+
+	// The response is the first part, and the rest follow up on it.
+	if assert.Len(t, session.sent, 2) {
+		assert.Equal(t, discordgo.MessageTypeDefault, session.sent[0].Type)
+		assert.Equal(t, discordgo.MessageTypeReply, session.sent[1].Type)
+	}
+
+	assert.True(t, invocation.responded)
+
+	invocation.Reply("more")
+
+	if assert.Len(t, session.sent, 3) {
+		assert.Equal(t, discordgo.MessageTypeReply, session.sent[2].Type)
+	}
+}
@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// EveryoneSubject is the grant subject that matches every user.
+	EveryoneSubject = "everyone"
+
+	// PermissionCommanders is needed to use the Commanders registered through
+	// RegisterCommand, which can't declare their own permissions.
+	PermissionCommanders = "commanders"
+)
+
+// UserSubject is the grant subject for a single user.
+func UserSubject(userID string) string {
+	return "user:" + userID
+}
+
+// RoleSubject is the grant subject for everyone with a role.
+func RoleSubject(roleID string) string {
+	return "role:" + roleID
+}
+
+var (
+	userMentionPattern = regexp.MustCompile(`^<@!?(\d+)>$`)
+	roleMentionPattern = regexp.MustCompile(`^<@&(\d+)>$`)
+	subjectPattern     = regexp.MustCompile(`^(user|role):\d+$`)
+	permissionPattern  = regexp.MustCompile(`^(\*|[a-z]+(\.[a-z]+)*(\.\*)?)$`)
+)
+
+// ParseGrantSubject parses a user mention, a role mention, everyone, or a
+// subject written as user:<id> or role:<id>.
+func ParseGrantSubject(text string) (string, error) {
+	switch {
+	case strings.EqualFold(text, EveryoneSubject), text == "@everyone":
+		return EveryoneSubject, nil
+
+	case userMentionPattern.MatchString(text):
+		return UserSubject(userMentionPattern.FindStringSubmatch(text)[1]), nil
+
+	case roleMentionPattern.MatchString(text):
+		return RoleSubject(roleMentionPattern.FindStringSubmatch(text)[1]), nil
+
+	case subjectPattern.MatchString(text):
+		return text, nil
+	}
+
+	return "", fmt.Errorf("%q isn't a user, a role, or everyone", text)
+}
+
+// ValidPermission checks whether the permission is well-formed.
+func ValidPermission(permission string) bool {
+	return permissionPattern.MatchString(permission)
+}
+
+// permissionCovers checks whether the granted permission covers the wanted one.
+func permissionCovers(granted, wanted string) bool {
+	if granted == "*" || granted == wanted {
+		return true
+	}
+
+	if strings.HasSuffix(granted, ".*") {
+		return strings.HasPrefix(wanted, strings.TrimSuffix(granted, "*"))
+	}
+
+	return false
+}
+
+// Grant grants a permission to a subject.
+type Grant struct {
+	Permission string
+	Subject    string
+}
+
+// HasPermission checks whether the user has the permission in the guild. The
+// owner has every permission, and an empty permission is available to all.
+//
+// Permissions are dotted names such as audio.skip. A granted permission ending
+// in .* covers every permission under it, so admin.* covers admin.prefix, and *
+// on its own covers everything.
+func (b *Bot) HasPermission(guildID, userID, permission string) bool {
+	if permission == "" || b.IsOwner(userID) {
+		return true
+	}
+
+	grants := b.settings.Guild(guildID).Grants
+
+	if len(grants) == 0 {
+		return false
+	}
+
+	subjects := map[string]bool{
+		EveryoneSubject:     true,
+		UserSubject(userID): true,
+	}
+
+	if member, err := b.session.Member(guildID, userID); err == nil {
+		for _, roleID := range member.Roles {
+			subjects[RoleSubject(roleID)] = true
+		}
+	}
+
+	for _, grant := range grants {
+		if subjects[grant.Subject] && permissionCovers(grant.Permission, permission) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionCovers(t *testing.T) {
+	assert.True(t, permissionCovers("audio.skip", "audio.skip"))
+	assert.True(t, permissionCovers("audio.*", "audio.skip"))
+	assert.True(t, permissionCovers("*", "admin.prefix"))
+
+	assert.False(t, permissionCovers("audio.skip", "audio.play"))
+	assert.False(t, permissionCovers("admin.*", "audio.skip"))
+	assert.False(t, permissionCovers("audio.*", "audiobook.play"))
+}
+
+func TestParseGrantSubject(t *testing.T) {
+	cases := map[string]string{
+		"everyone":  EveryoneSubject,
+		"@everyone": EveryoneSubject,
+		"<@123>":    "user:123",
+		"<@!123>":   "user:123",
+		"<@&456>":   "role:456",
+		"role:456":  "role:456",
+	}
+
+	for text, expected := range cases {
+		subject, err := ParseGrantSubject(text)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, subject, text)
+	}
+
+	_, err := ParseGrantSubject("bob")
+
+	assert.NotNil(t, err)
+}
+
+func TestValidPermission(t *testing.T) {
+	assert.True(t, ValidPermission("audio.skip"))
+	assert.True(t, ValidPermission("admin.*"))
+	assert.True(t, ValidPermission("*"))
+
+	assert.False(t, ValidPermission("audio."))
+	assert.False(t, ValidPermission("audio.*.skip"))
+}
@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// plugin is anything registered with the bot: a CommandSet, Commander or
+// Previewer. Plugins may implement any of Initializer, Readier and Shutdowner
+// to be told about the bot's lifecycle.
+type plugin struct {
+	name  string
+	value interface{}
+}
+
+// Initializer is implemented by plugins that need to set up before the bot
+// connects, e.g. to load their state. An error stops the bot from opening.
+type Initializer interface {
+	Init(context *PluginContext) error
+}
+
+// Readier is implemented by plugins that need to start work once the bot is
+// connected, e.g. a poller. It's only called for the first connection, not
+// after reconnecting.
+type Readier interface {
+	Ready(context *PluginContext) error
+}
+
+// Shutdowner is implemented by plugins that need to clean up when the bot
+// closes, e.g. to flush their state. Plugins are shut down in the reverse of
+// the order they were registered in.
+type Shutdowner interface {
+	Shutdown(context *PluginContext) error
+}
+
+// PluginContext is what a plugin's lifecycle hooks are given.
+type PluginContext struct {
+	Bot  *Bot
+	Name string
+
+	// Log is scoped with the plugin's name.
+	Log *log.Entry
+}
+
+// Config is the plugin's section of the current config, [plugins.<name>].
+func (c *PluginContext) Config() PluginConfig {
+	return c.Bot.Config().Plugin(c.Name)
+}
+
+// Bucket is the bucket the plugin keeps its state in.
+func (c *PluginContext) Bucket() Bucket {
+	return c.Bot.PluginBucket(c.Name)
+}
+
+// registerPlugin records the plugin under the name, which must be unique.
+func (b *Bot) registerPlugin(name string, value interface{}) error {
+	for _, registered := range b.plugins {
+		if registered.name == name {
+			return fmt.Errorf("Plugin %q is already registered", name)
+		}
+	}
+
+	b.plugins = append(b.plugins, plugin{name: name, value: value})
+
+	return nil
+}
+
+func (b *Bot) pluginContext(name string) *PluginContext {
+	return &PluginContext{
+		Bot:  b,
+		Name: name,
+		Log:  log.WithField("plugin", name),
+	}
+}
+
+// initPlugins initializes the plugins in the order they were registered,
+// stopping at the first failure.
+func (b *Bot) initPlugins() error {
+	for _, plugin := range b.plugins {
+		if initializer, ok := plugin.value.(Initializer); ok {
+			if err := initializer.Init(b.pluginContext(plugin.name)); err != nil {
+				return fmt.Errorf("Plugin %q couldn't initialize: %s", plugin.name, err)
+			}
+		}
+
+		b.initialized++
+	}
+
+	return nil
+}
+
+// readyPlugins tells the plugins that the bot is connected, logging failures
+// since there's no caller to return them to.
+func (b *Bot) readyPlugins() {
+	for _, plugin := range b.plugins {
+		readier, ok := plugin.value.(Readier)
+
+		if !ok {
+			continue
+		}
+
+		context := b.pluginContext(plugin.name)
+
+		if err := readier.Ready(context); err != nil {
+			context.Log.WithError(err).Error("Plugin couldn't get ready")
+		}
+	}
+}
+
+// shutdownPlugins shuts down the initialized plugins in the reverse of the
+// order they were registered in, returning the first failure.
+func (b *Bot) shutdownPlugins() error {
+	var first error
+
+	for i := b.initialized - 1; i >= 0; i-- {
+		plugin := b.plugins[i]
+		shutdowner, ok := plugin.value.(Shutdowner)
+
+		if !ok {
+			continue
+		}
+
+		context := b.pluginContext(plugin.name)
+
+		if err := shutdowner.Shutdown(context); err != nil {
+			context.Log.WithError(err).Error("Plugin couldn't shut down")
+
+			if first == nil {
+				first = fmt.Errorf("Plugin %q couldn't shut down: %s", plugin.name, err)
+			}
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPlugin struct {
+	name    string
+	calls   *[]string
+	initErr error
+}
+
+func (p *testPlugin) Commands() []*Command {
+	return nil
+}
+
+func (p *testPlugin) Init(context *PluginContext) error {
+	*p.calls = append(*p.calls, "init "+context.Name)
+	return p.initErr
+}
+
+func (p *testPlugin) Ready(context *PluginContext) error {
+	*p.calls = append(*p.calls, "ready "+context.Name)
+	return nil
+}
+
+func (p *testPlugin) Shutdown(context *PluginContext) error {
+	*p.calls = append(*p.calls, "shutdown "+context.Name)
+	return nil
+}
+
+func testPluginBot() *Bot {
+	return &Bot{router: NewRouter(), store: NewMemoryStore(), config: DefaultConfig()}
+}
+
+func TestPluginLifecycle(t *testing.T) {
+	var calls []string
+
+	b := testPluginBot()
+	b.RegisterCommands("a", &testPlugin{calls: &calls})
+	b.RegisterPreviewer("b", nil)
+	b.RegisterCommands("c", &testPlugin{calls: &calls})
+
+	assert.NoError(t, b.initPlugins())
+	b.readyOnce.Do(b.readyPlugins)
+	b.readyOnce.Do(b.readyPlugins)
+	assert.NoError(t, b.Close())
+	assert.NoError(t, b.Close())
+
+	assert.Equal(t, []string{
+		"init a", "init c",
+		"ready a", "ready c",
+		"shutdown c", "shutdown a",
+	}, calls)
+}
+
+func TestPluginInitFailure(t *testing.T) {
+	var calls []string
+
+	b := testPluginBot()
+	b.RegisterCommands("a", &testPlugin{calls: &calls})
+	b.RegisterCommands("b", &testPlugin{calls: &calls, initErr: errors.New("broken")})
+	b.RegisterCommands("c", &testPlugin{calls: &calls})
+
+	err := b.initPlugins()
+
+	assert.EqualError(t, err, `Plugin "b" couldn't initialize: broken`)
+	assert.NoError(t, b.Close())
+
+	// The failed plugin and those after it were never initialized.
+	assert.Equal(t, []string{"init a", "init b", "shutdown a"}, calls)
+}
+
+func TestPluginDuplicateName(t *testing.T) {
+	b := testPluginBot()
+
+	assert.NoError(t, b.registerPlugin("a", nil))
+	assert.Error(t, b.registerPlugin("a", nil))
+}
@@ -0,0 +1,215 @@
+package bot
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/bwmarrin/discordgo"
+)
+
+// PresenceKind is the kind of voice channel presence change.
+type PresenceKind int
+
+const (
+	// PresenceJoin means a user joined a voice channel.
+	PresenceJoin PresenceKind = iota
+
+	// PresenceLeave means a user left a voice channel.
+	PresenceLeave
+
+	// PresenceMove means a user moved from one voice channel to another.
+	PresenceMove
+)
+
+// PresenceTemplates are the templates used to announce voice channel presence
+// changes. The following placeholders are available:
+//
+//	{name}      the user's nickname or username
+//	{channel}   the channel the announcement is spoken in
+//	{from}      the channel the user left
+//	{to}        the channel the user joined
+//	{time}      the current time, e.g. 3:04 PM
+//	{timeofday} the current time of day, e.g. morning
+type PresenceTemplates struct {
+	Join  string
+	Leave string
+	Move  string
+}
+
+// Template returns the template for the given kind of presence change.
+func (t PresenceTemplates) Template(kind PresenceKind) string {
+	switch kind {
+	case PresenceJoin:
+		return t.Join
+	case PresenceLeave:
+		return t.Leave
+	case PresenceMove:
+		return t.Move
+	}
+
+	return ""
+}
+
+// SetTemplate sets the template for the given kind of presence change.
+func (t *PresenceTemplates) SetTemplate(kind PresenceKind, template string) {
+	switch kind {
+	case PresenceJoin:
+		t.Join = template
+	case PresenceLeave:
+		t.Leave = template
+	case PresenceMove:
+		t.Move = template
+	}
+}
+
+// DefaultLanguage is the language used when a guild hasn't chosen one.
+const DefaultLanguage = "en"
+
+// DefaultPresenceTemplates are the built-in templates for each supported
+// language.
+var DefaultPresenceTemplates = map[string]PresenceTemplates{
+	"en": {
+		Join:  "{name} joined the channel",
+		Leave: "{name} left the channel",
+		Move:  "{name} moved from {from} to {to}",
+	},
+	"es": {
+		Join:  "{name} entró al canal",
+		Leave: "{name} salió del canal",
+		Move:  "{name} se cambió de {from} a {to}",
+	},
+	"fr": {
+		Join:  "{name} a rejoint le salon",
+		Leave: "{name} a quitté le salon",
+		Move:  "{name} est passé de {from} à {to}",
+	},
+	"de": {
+		Join:  "{name} ist dem Kanal beigetreten",
+		Leave: "{name} hat den Kanal verlassen",
+		Move:  "{name} ist von {from} nach {to} gewechselt",
+	},
+}
+
+// timesOfDay are the night, morning, afternoon and evening words for each
+// supported language.
+var timesOfDay = map[string][4]string{
+	"en": {"night", "morning", "afternoon", "evening"},
+	"es": {"noche", "mañana", "tarde", "noche"},
+	"fr": {"nuit", "matin", "après-midi", "soir"},
+	"de": {"Nacht", "Morgen", "Nachmittag", "Abend"},
+}
+
+func timeOfDay(language string, t time.Time) string {
+	words, ok := timesOfDay[language]
+
+	if !ok {
+		words = timesOfDay[DefaultLanguage]
+	}
+
+	switch hour := t.Hour(); {
+	case hour >= 5 && hour < 12:
+		return words[1]
+	case hour >= 12 && hour < 17:
+		return words[2]
+	case hour >= 17 && hour < 22:
+		return words[3]
+	default:
+		return words[0]
+	}
+}
+
+// presenceTemplate resolves the template for the guild's language, preferring
+// the guild's own templates over the built-in ones.
+func (g GuildSettings) presenceTemplate(kind PresenceKind) string {
+	language := g.Language
+
+	if language == "" {
+		language = DefaultLanguage
+	}
+
+	if template := g.PresenceTemplates[language].Template(kind); template != "" {
+		return template
+	}
+
+	if template := DefaultPresenceTemplates[language].Template(kind); template != "" {
+		return template
+	}
+
+	return DefaultPresenceTemplates[DefaultLanguage].Template(kind)
+}
+
+// presenceChange describes a change in a user's voice channel presence.
+type presenceChange struct {
+	kind    PresenceKind
+	guildID string
+	userID  string
+
+	// The channel the user left, if any.
+	fromChannelID string
+
+	// The channel the user joined, if any.
+	toChannelID string
+}
+
+func (b *Bot) voiceChannelName(channelID string) string {
+	if channel, err := b.session.Channel(channelID); err == nil {
+		return channel.Name
+	}
+
+	return "another channel"
+}
+
+// presenceText renders the guild's template for the presence change as it
+// should be spoken in the given channel.
+func (b *Bot) presenceText(change *presenceChange, member *discordgo.Member, channelID string) string {
+	settings := b.settings.Guild(change.guildID)
+	now := time.Now()
+
+	replacer := strings.NewReplacer(
+		"{name}", memberFriendlyName(member),
+		"{channel}", b.voiceChannelName(channelID),
+		"{from}", b.voiceChannelName(change.fromChannelID),
+		"{to}", b.voiceChannelName(change.toChannelID),
+		"{time}", now.Format("3:04 PM"),
+		"{timeofday}", timeOfDay(settings.Language, now),
+	)
+
+	return replacer.Replace(settings.presenceTemplate(change.kind))
+}
+
+func (b *Bot) speakPresenceUpdate(change *presenceChange) {
+	if b.settings.User(change.userID).AnnounceOptOut {
+		return
+	}
+
+	member, err := b.session.Member(change.guildID, change.userID)
+
+	if err != nil {
+		b.sessionLog.WithFields(log.Fields{
+			"guild": change.guildID,
+			"user":  change.userID,
+		}).WithError(err).Error("Couldn't find user")
+
+		return
+	}
+
+	// A move is announced to both the channel that was left and the one that was
+	// joined.
+	for _, channelID := range []string{change.fromChannelID, change.toChannelID} {
+		// Nobody is left to hear it.
+		if channelID == "" || len(b.VoiceChannelUsers(change.guildID, channelID)) == 0 {
+			continue
+		}
+
+		speech := &Speech{
+			Text:  b.presenceText(change, member, channelID),
+			Voice: b.Voice(change.guildID, ""),
+			Tag:   change.tag(),
+		}
+
+		if err := b.SpeakWith(change.guildID, channelID, speech); err != nil {
+			b.sessionLog.WithError(err).Error("Couldn't speak with Ivona")
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// then merges the change with the one that followed it into the net change.
+func (c *presenceChange) then(next *presenceChange) *presenceChange {
+	merged := &presenceChange{
+		guildID:       c.guildID,
+		userID:        c.userID,
+		fromChannelID: c.fromChannelID,
+		toChannelID:   next.toChannelID,
+	}
+
+	switch {
+	case merged.fromChannelID == "":
+		merged.kind = PresenceJoin
+	case merged.toChannelID == "":
+		merged.kind = PresenceLeave
+	default:
+		merged.kind = PresenceMove
+	}
+
+	return merged
+}
+
+// isNoop reports whether the change leaves the user where they started, such as
+// a leave followed by a rejoin.
+func (c *presenceChange) isNoop() bool {
+	return c.fromChannelID == c.toChannelID
+}
+
+// tag identifies the user's announcements in the audio queue.
+func (c *presenceChange) tag() string {
+	return "presence:" + c.guildID + ":" + c.userID
+}
+
+type pendingPresence struct {
+	change *presenceChange
+	timer  *time.Timer
+}
+
+// presenceDebouncer coalesces each user's presence changes over a window so
+// that flapping connections don't flood the audio queue with announcements.
+type presenceDebouncer struct {
+	bot *Bot
+
+	lock sync.Mutex
+
+	// pending are the changes that are waiting for the window to elapse.
+	pending map[string]*pendingPresence
+
+	// queued are the changes that were last sent to the audio queue. They're
+	// used to cancel out announcements that are still queued when the user
+	// changes channels again.
+	queued map[string]*presenceChange
+}
+
+func newPresenceDebouncer(bot *Bot) *presenceDebouncer {
+	d := &presenceDebouncer{
+		bot:     bot,
+		pending: map[string]*pendingPresence{},
+		queued:  map[string]*presenceChange{},
+	}
+
+	bot.bus.Subscribe(d.onJoin)
+	bot.bus.Subscribe(d.onLeave)
+	bot.bus.Subscribe(d.onMove)
+
+	return d
+}
+
+func (d *presenceDebouncer) onJoin(event *VoiceJoinEvent) {
+	d.onChange(&presenceChange{
+		kind:        PresenceJoin,
+		guildID:     event.GuildID,
+		userID:      event.UserID,
+		toChannelID: event.ChannelID,
+	})
+}
+
+func (d *presenceDebouncer) onLeave(event *VoiceLeaveEvent) {
+	d.onChange(&presenceChange{
+		kind:          PresenceLeave,
+		guildID:       event.GuildID,
+		userID:        event.UserID,
+		fromChannelID: event.ChannelID,
+	})
+}
+
+func (d *presenceDebouncer) onMove(event *VoiceMoveEvent) {
+	d.onChange(&presenceChange{
+		kind:          PresenceMove,
+		guildID:       event.GuildID,
+		userID:        event.UserID,
+		fromChannelID: event.FromChannelID,
+		toChannelID:   event.ToChannelID,
+	})
+}
+
+func (d *presenceDebouncer) onChange(change *presenceChange) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	tag := change.tag()
+
+	if pending, ok := d.pending[tag]; ok {
+		pending.timer.Stop()
+		change = pending.change.then(change)
+	} else if queued, ok := d.queued[tag]; ok {
+		// The previous announcement is stale. If it hasn't been played yet, drop it
+		// and fold it into this change so that e.g. a queued leave and this rejoin
+		// cancel out.
+		if d.bot.audio.DropTagged(tag) > 0 {
+			d.bot.voiceLog.WithField("user", change.userID).Info("Dropped stale presence announcement")
+
+			change = queued.then(change)
+		}
+	}
+
+	delete(d.queued, tag)
+
+	pending := &pendingPresence{change: change}
+	window := d.bot.Config().Voice.PresenceDebounce.Duration
+
+	pending.timer = time.AfterFunc(window, func() { d.flush(tag, pending) })
+
+	d.pending[tag] = pending
+}
+
+func (d *presenceDebouncer) flush(tag string, pending *pendingPresence) {
+	d.lock.Lock()
+
+	// The change was superseded by a later one with its own timer.
+	if d.pending[tag] != pending {
+		d.lock.Unlock()
+		return
+	}
+
+	delete(d.pending, tag)
+
+	if pending.change.isNoop() {
+		d.lock.Unlock()
+
+		d.bot.voiceLog.WithFields(log.Fields{
+			"guild": pending.change.guildID,
+			"user":  pending.change.userID,
+		}).Info("Suppressed presence announcement")
+
+		return
+	}
+
+	d.queued[tag] = pending.change
+
+	d.lock.Unlock()
+
+	d.bot.speakPresenceUpdate(pending.change)
+}
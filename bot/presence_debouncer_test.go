@@ -0,0 +1,25 @@
+package bot
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresenceChangeThen(t *testing.T) {
+	leave := &presenceChange{kind: PresenceLeave, fromChannelID: "a"}
+	join := &presenceChange{kind: PresenceJoin, toChannelID: "a"}
+	joinOther := &presenceChange{kind: PresenceJoin, toChannelID: "b"}
+
+	assert.True(t, leave.then(join).isNoop())
+	assert.True(t, join.then(leave).isNoop())
+
+	moved := leave.then(joinOther)
+
+	assert.False(t, moved.isNoop())
+	assert.Equal(t, PresenceMove, moved.kind)
+	assert.Equal(t, "a", moved.fromChannelID)
+	assert.Equal(t, "b", moved.toChannelID)
+
+	assert.Equal(t, PresenceLeave, moved.then(&presenceChange{fromChannelID: "b"}).kind)
+}
@@ -0,0 +1,40 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresenceTemplate(t *testing.T) {
+	settings := GuildSettings{}
+
+	assert.Equal(t, "{name} joined the channel", settings.presenceTemplate(PresenceJoin))
+
+	settings.Language = "fr"
+
+	assert.Equal(t, "{name} a quitté le salon", settings.presenceTemplate(PresenceLeave))
+
+	settings.PresenceTemplates = map[string]PresenceTemplates{
+		"fr": {Leave: "au revoir {name}"},
+	}
+
+	assert.Equal(t, "au revoir {name}", settings.presenceTemplate(PresenceLeave))
+	assert.Equal(t, "{name} est passé de {from} à {to}", settings.presenceTemplate(PresenceMove))
+
+	settings.Language = "xx"
+
+	assert.Equal(t, "{name} joined the channel", settings.presenceTemplate(PresenceJoin))
+}
+
+func TestTimeOfDay(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2016, 11, 1, hour, 0, 0, 0, time.UTC)
+	}
+
+	assert.Equal(t, "night", timeOfDay("en", at(2)))
+	assert.Equal(t, "morning", timeOfDay("en", at(9)))
+	assert.Equal(t, "Nachmittag", timeOfDay("de", at(13)))
+	assert.Equal(t, "evening", timeOfDay("xx", at(19)))
+}
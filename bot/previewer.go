@@ -1,12 +1,21 @@
 package bot
 
 import (
+	"context"
 	"net/url"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-// Previewer represents a type that is capable of previewing a given URL.
+// Previewer represents a type that is capable of previewing a given URL. The
+// context is cancelled when the preview runs past its plugin's timeout or the
+// bot closes.
 type Previewer interface {
-	Preview(bot *Bot, message *discordgo.Message, url *url.URL)
+	Preview(ctx context.Context, bot *Bot, message *discordgo.Message, url *url.URL)
+}
+
+// namedPreviewer is a Previewer with the name it was registered under.
+type namedPreviewer struct {
+	name string
+	Previewer
 }
@@ -0,0 +1,164 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Cooldown is how long after a command is used that it can't be used again by
+// the same user, in the same channel or in the same guild. Zero durations
+// don't limit.
+type Cooldown struct {
+	User    time.Duration
+	Channel time.Duration
+	Guild   time.Duration
+
+	// PerGuild, if set, decides the cooldown from the config of the guild the
+	// command is used in instead.
+	PerGuild func(config GuildConfig) Cooldown
+}
+
+// isZero reports whether the cooldown doesn't limit anything.
+func (c Cooldown) isZero() bool {
+	return c.User == 0 && c.Channel == 0 && c.Guild == 0 && c.PerGuild == nil
+}
+
+// inGuild is the cooldown that applies in the guild with the config.
+func (c Cooldown) inGuild(config GuildConfig) Cooldown {
+	if c.PerGuild == nil {
+		return c
+	}
+
+	return c.PerGuild(config)
+}
+
+// maxCooldownEntries is how many cooldowns are kept before the ones that
+// ended are forgotten.
+const maxCooldownEntries = 1000
+
+// rateLimiter enforces the commands' cooldowns.
+type rateLimiter struct {
+	lock sync.Mutex
+
+	// ends are when each command's cooldowns for each user, channel and guild
+	// end.
+	ends map[string]time.Time
+
+	// warned are when the cooldowns end that users were told to slow down
+	// for, for each command.
+	warned map[string]time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		ends:   map[string]time.Time{},
+		warned: map[string]time.Time{},
+	}
+}
+
+// cooldownKeys are the keys of the invocation's command's cooldowns, each
+// mapped to its duration.
+func cooldownKeys(i *Invocation) map[string]time.Duration {
+	keys := map[string]time.Duration{}
+
+	if i.cooldown.User > 0 {
+		keys["user:"+i.Author.ID+":"+i.path] = i.cooldown.User
+	}
+
+	if i.cooldown.Channel > 0 {
+		keys["channel:"+i.ChannelID+":"+i.path] = i.cooldown.Channel
+	}
+
+	if i.cooldown.Guild > 0 && i.GuildID != "" {
+		keys["guild:"+i.GuildID+":"+i.path] = i.cooldown.Guild
+	}
+
+	return keys
+}
+
+// allow records the invocation unless one of its command's cooldowns hasn't
+// ended, in which case it returns how long until they all have, and whether
+// the user should be warned, which they are once until they're allowed again.
+func (l *rateLimiter) allow(i *Invocation, now time.Time) (wait time.Duration, warn, ok bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	keys := cooldownKeys(i)
+
+	for key := range keys {
+		if end := l.ends[key]; end.After(now) && end.Sub(now) > wait {
+			wait = end.Sub(now)
+		}
+	}
+
+	userKey := i.Author.ID + ":" + i.path
+
+	if wait > 0 {
+		warn = !l.warned[userKey].After(now)
+
+		if warn {
+			l.warned[userKey] = now.Add(wait)
+		}
+
+		return wait, warn, false
+	}
+
+	if len(l.ends)+len(l.warned) > maxCooldownEntries {
+		l.forgetEnded(now)
+	}
+
+	for key, duration := range keys {
+		l.ends[key] = now.Add(duration)
+	}
+
+	delete(l.warned, userKey)
+
+	return 0, false, true
+}
+
+// forgetEnded forgets the cooldowns and warnings that ended.
+func (l *rateLimiter) forgetEnded(now time.Time) {
+	for _, times := range []map[string]time.Time{l.ends, l.warned} {
+		for key, end := range times {
+			if !end.After(now) {
+				delete(times, key)
+			}
+		}
+	}
+}
+
+// withinCooldown checks whether the invocation may run now, telling the
+// invoker to slow down if it may not. Users who keep trying are only told once,
+// except for interactions, which always need a response. The owner has no
+// cooldowns.
+func (i *Invocation) withinCooldown() bool {
+	if i.cooldown.isZero() || i.Bot.IsOwner(i.Author.ID) {
+		return true
+	}
+
+	i.cooldown = i.cooldown.inGuild(i.Bot.Config().Guild(i.GuildID))
+
+	wait, warn, ok := i.Bot.rateLimiter.allow(i, time.Now())
+
+	if ok {
+		return true
+	}
+
+	i.Bot.chatLog.WithFields(log.Fields{
+		"command": i.Command.Name,
+		"user":    i.Author.ID,
+		"wait":    wait,
+	}).Info("Command is cooling down")
+
+	if warn || i.interaction != nil {
+		seconds := int(math.Ceil(wait.Seconds()))
+
+		i.Reply(fmt.Sprintf("Slow down! Try `%s` again in %ds.", i.path, seconds))
+	}
+
+	return false
+}
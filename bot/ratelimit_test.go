@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func cooldownInvocation(userID, channelID string, cooldown Cooldown) *Invocation {
+	return &Invocation{
+		Author:    &discordgo.User{ID: userID},
+		ChannelID: channelID,
+		GuildID:   "guild",
+		path:      "play",
+		cooldown:  cooldown,
+	}
+}
+
+func TestRateLimiterUserCooldown(t *testing.T) {
+	limiter := newRateLimiter()
+	cooldown := Cooldown{User: 10 * time.Second}
+	now := time.Now()
+
+	_, _, ok := limiter.allow(cooldownInvocation("1", "a", cooldown), now)
+	assert.True(t, ok)
+
+	// Other users aren't limited.
+	_, _, ok = limiter.allow(cooldownInvocation("2", "a", cooldown), now)
+	assert.True(t, ok)
+
+	wait, warn, ok := limiter.allow(cooldownInvocation("1", "b", cooldown), now.Add(4*time.Second))
+	assert.False(t, ok)
+	assert.True(t, warn)
+	assert.Equal(t, 6*time.Second, wait)
+
+	// Users are only warned once per cooldown.
+	_, warn, ok = limiter.allow(cooldownInvocation("1", "b", cooldown), now.Add(5*time.Second))
+	assert.False(t, ok)
+	assert.False(t, warn)
+
+	_, _, ok = limiter.allow(cooldownInvocation("1", "b", cooldown), now.Add(10*time.Second))
+	assert.True(t, ok)
+
+	_, warn, ok = limiter.allow(cooldownInvocation("1", "b", cooldown), now.Add(11*time.Second))
+	assert.False(t, ok)
+	assert.True(t, warn)
+}
+
+func TestRateLimiterChannelAndGuildCooldowns(t *testing.T) {
+	limiter := newRateLimiter()
+	cooldown := Cooldown{Channel: 5 * time.Second, Guild: 20 * time.Second}
+	now := time.Now()
+
+	_, _, ok := limiter.allow(cooldownInvocation("1", "a", cooldown), now)
+	assert.True(t, ok)
+
+	// The guild's cooldown is longer than the channel's.
+	wait, _, ok := limiter.allow(cooldownInvocation("2", "b", cooldown), now.Add(time.Second))
+	assert.False(t, ok)
+	assert.Equal(t, 19*time.Second, wait)
+
+	_, _, ok = limiter.allow(cooldownInvocation("2", "a", cooldown), now.Add(20*time.Second))
+	assert.True(t, ok)
+}
+
+func TestRateLimiterForgetsEndedCooldowns(t *testing.T) {
+	limiter := newRateLimiter()
+	now := time.Now()
+
+	for i := 0; i < maxCooldownEntries+2; i++ {
+		invocation := cooldownInvocation(fmt.Sprint(i), "a", Cooldown{User: time.Second})
+		limiter.allow(invocation, now.Add(time.Duration(i)*time.Second))
+	}
+
+	// Every cooldown but the last one ended.
+	assert.Len(t, limiter.ends, 1)
+}
+
+func TestCooldownPerGuild(t *testing.T) {
+	config := DefaultConfig()
+	config.Guilds["1"] = GuildConfig{SayCooldown: Duration{time.Minute}}
+
+	cooldown := Cooldown{
+		PerGuild: func(config GuildConfig) Cooldown {
+			return Cooldown{User: config.SayCooldown.Duration}
+		},
+	}
+
+	assert.False(t, cooldown.isZero())
+	assert.Equal(t, Cooldown{User: time.Minute}, cooldown.inGuild(config.Guild("1")))
+	assert.Equal(t, Cooldown{User: 10 * time.Second}, cooldown.inGuild(config.Guild("2")))
+
+	fixed := Cooldown{Channel: time.Second}
+
+	assert.Equal(t, fixed, fixed.inGuild(config.Guild("1")))
+}
@@ -0,0 +1,233 @@
+package bot
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/bwmarrin/discordgo"
+	"github.com/mvdan/xurls"
+)
+
+const (
+	// readAloudWindow is how long to wait for a burst of messages to settle
+	// before speaking them.
+	readAloudWindow = 3 * time.Second
+
+	// readAloudMaxLines is the most messages that are spoken from a single
+	// burst. The rest are summarized.
+	readAloudMaxLines = 4
+
+	// readAloudMaxLength is the most characters spoken from a single message.
+	readAloudMaxLength = 200
+)
+
+var (
+	// Matches user, nickname, channel and role mentions: <@id>, <@!id>, <#id>,
+	// <@&id>.
+	mentionPattern = regexp.MustCompile(`<(@!?|#|@&)(\d+)>`)
+
+	// Matches static and animated custom emoji: <:name:id>, <a:name:id>.
+	customEmojiPattern = regexp.MustCompile(`<a?:(\w+):\d+>`)
+)
+
+// speakable normalizes message content into text that can be spoken. Mentions
+// are resolved to names through resolve, custom emoji are replaced by their
+// names, URLs are replaced by their hosts, and other emoji are dropped.
+func speakable(content string, resolve func(kind, id string) string) string {
+	content = mentionPattern.ReplaceAllStringFunc(content, func(mention string) string {
+		match := mentionPattern.FindStringSubmatch(mention)
+		return resolve(match[1], match[2])
+	})
+
+	content = customEmojiPattern.ReplaceAllString(content, "$1")
+
+	content = xurls.Relaxed.ReplaceAllStringFunc(content, func(link string) string {
+		if parsed, err := url.Parse(link); err == nil && parsed.Host != "" {
+			return "a link to " + strings.TrimPrefix(parsed.Host, "www.")
+		}
+
+		return "a link"
+	})
+
+	content = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.So, r) || unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Sk, r) {
+			return -1
+		}
+
+		return r
+	}, content)
+
+	content = strings.Join(strings.Fields(content), " ")
+
+	if utf8.RuneCountInString(content) > readAloudMaxLength {
+		content = string([]rune(content)[:readAloudMaxLength])
+	}
+
+	return content
+}
+
+type readAloudLine struct {
+	author string
+	text   string
+}
+
+// readAloudBurst collects the messages posted in a guild's read-aloud channel
+// within readAloudWindow of the first one.
+type readAloudBurst struct {
+	voiceChannelID string
+	lines          []readAloudLine
+}
+
+// text collapses the burst into a single utterance, grouping consecutive
+// messages by the same author.
+func (burst *readAloudBurst) text() string {
+	lines := burst.lines
+	omitted := 0
+
+	if len(lines) > readAloudMaxLines {
+		omitted = len(lines) - readAloudMaxLines
+		lines = lines[:readAloudMaxLines]
+	}
+
+	var sentences []string
+
+	for i, line := range lines {
+		if i > 0 && lines[i-1].author == line.author {
+			sentences[len(sentences)-1] += ". " + line.text
+			continue
+		}
+
+		sentences = append(sentences, line.author+" says: "+line.text)
+	}
+
+	switch omitted {
+	case 0:
+	case 1:
+		sentences = append(sentences, "and one more message")
+	default:
+		sentences = append(sentences, fmt.Sprintf("and %d more messages", omitted))
+	}
+
+	return strings.Join(sentences, ". ")
+}
+
+// readAloud speaks the messages posted in each guild's read-aloud channel.
+type readAloud struct {
+	bot *Bot
+
+	lock   sync.Mutex
+	bursts map[string]*readAloudBurst
+}
+
+func newReadAloud(bot *Bot) *readAloud {
+	return &readAloud{
+		bot:    bot,
+		bursts: map[string]*readAloudBurst{},
+	}
+}
+
+func (r *readAloud) resolveMention(guildID string) func(kind, id string) string {
+	session := r.bot.session
+
+	return func(kind, id string) string {
+		switch kind {
+		case "@", "@!":
+			if member, err := session.Member(guildID, id); err == nil {
+				return memberFriendlyName(member)
+			}
+
+		case "#":
+			if channel, err := session.Channel(id); err == nil {
+				return channel.Name
+			}
+
+		case "@&":
+			if role, err := session.Role(guildID, id); err == nil {
+				return role.Name
+			}
+		}
+
+		return "someone"
+	}
+}
+
+func (r *readAloud) authorName(guildID string, author *discordgo.User) string {
+	if member, err := r.bot.session.Member(guildID, author.ID); err == nil {
+		return memberFriendlyName(member)
+	}
+
+	return author.Username
+}
+
+// onMessage queues the message to be read aloud if it was posted in the
+// guild's read-aloud channel.
+func (r *readAloud) onMessage(msg *discordgo.Message) {
+	if msg.Author.Bot || r.bot.MessageCommandsBot(msg) {
+		return
+	}
+
+	guildID, err := r.bot.MessageGuildID(msg)
+
+	if err != nil || guildID == "" {
+		return
+	}
+
+	settings := r.bot.settings.Guild(guildID).ReadAloud
+
+	if !settings.Enabled() || settings.TextChannelID != msg.ChannelID {
+		return
+	}
+
+	text := speakable(msg.Content, r.resolveMention(guildID))
+
+	if text == "" {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	burst, pending := r.bursts[guildID]
+
+	if !pending {
+		burst = &readAloudBurst{voiceChannelID: settings.VoiceChannelID}
+		r.bursts[guildID] = burst
+
+		time.AfterFunc(readAloudWindow, func() { r.flush(guildID) })
+	}
+
+	burst.lines = append(burst.lines, readAloudLine{
+		author: r.authorName(guildID, msg.Author),
+		text:   text,
+	})
+}
+
+func (r *readAloud) flush(guildID string) {
+	r.lock.Lock()
+
+	burst := r.bursts[guildID]
+	delete(r.bursts, guildID)
+
+	r.lock.Unlock()
+
+	if burst == nil {
+		return
+	}
+
+	r.bot.voiceLog.WithFields(log.Fields{
+		"guild":    guildID,
+		"channel":  burst.voiceChannelID,
+		"messages": len(burst.lines),
+	}).Info("Reading messages aloud")
+
+	if err := r.bot.Speak(guildID, burst.voiceChannelID, burst.text()); err != nil {
+		r.bot.voiceLog.WithError(err).Error("Couldn't read messages aloud")
+	}
+}
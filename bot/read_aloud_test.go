@@ -0,0 +1,37 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpeakable(t *testing.T) {
+	resolve := func(kind, id string) string {
+		return kind + id
+	}
+
+	assert.Equal(t, "hi @1 and @!2 in #3", speakable("hi <@1> and <@!2> in <#3>", resolve))
+	assert.Equal(t, "nice party", speakable("nice <a:party:123> 🎉", resolve))
+	assert.Equal(t, "look at a link to example.com", speakable("look at https://www.example.com/foo?bar", resolve))
+	assert.Equal(t, 200, len(speakable(strings.Repeat("a", 300), resolve)))
+}
+
+func TestReadAloudBurstText(t *testing.T) {
+	burst := &readAloudBurst{
+		lines: []readAloudLine{
+			{"alice", "hi"},
+			{"alice", "anyone here"},
+			{"bob", "yes"},
+		},
+	}
+
+	assert.Equal(t, "alice says: hi. anyone here. bob says: yes", burst.text())
+
+	for i := 0; i < 4; i++ {
+		burst.lines = append(burst.lines, readAloudLine{"carol", "spam"})
+	}
+
+	assert.Equal(t, "alice says: hi. anyone here. bob says: yes. carol says: spam. and 3 more messages", burst.text())
+}
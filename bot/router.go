@@ -0,0 +1,959 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/bwmarrin/discordgo"
+)
+
+// ArgKind is the type of a command argument or flag.
+type ArgKind int
+
+const (
+	// ArgString is a single word or quoted string.
+	ArgString ArgKind = iota
+
+	// ArgInt is an integer.
+	ArgInt
+
+	// ArgDuration is a duration such as 90, 1:30 or 1m30s.
+	ArgDuration
+
+	// ArgBool is a flag that is either present or not. It's only valid for
+	// flags.
+	ArgBool
+
+	// ArgText is the rest of the command's text, as it was written. It's only
+	// valid for the last argument.
+	ArgText
+)
+
+func (k ArgKind) String() string {
+	switch k {
+	case ArgInt:
+		return "number"
+	case ArgDuration:
+		return "duration"
+	case ArgBool:
+		return "bool"
+	case ArgText:
+		return "text"
+	default:
+		return "string"
+	}
+}
+
+// Arg describes a positional command argument.
+type Arg struct {
+	Name        string
+	Kind        ArgKind
+	Description string
+	Optional    bool
+}
+
+// Flag describes a named command flag, written as --name value, --name=value,
+// or just --name for ArgBool flags.
+type Flag struct {
+	Name        string
+	Kind        ArgKind
+	Description string
+}
+
+// Command is a command that can be dispatched by the Router.
+type Command struct {
+	Name        string
+	Aliases     []string
+	Description string
+
+	Args  []Arg
+	Flags []Flag
+
+	// Permission is the permission needed to invoke the command. Subcommands
+	// without their own Permission inherit it.
+	Permission string
+
+	// Cooldown limits how often the command can be used. Subcommands without
+	// their own Cooldown inherit it.
+	Cooldown Cooldown
+
+	// Subcommands are dispatched when their name follows the command's name. A
+	// command with subcommands may still have its own Handler, which is used
+	// when no subcommand matches.
+	Subcommands []*Command
+
+	Handler func(*Invocation)
+
+	// Immediate commands don't wait for the commands sent before them in the
+	// guild to finish, so that e.g. pausing isn't held up by a slow download.
+	Immediate bool
+
+	// plugin is the name of the plugin that registered the command.
+	plugin string
+}
+
+// CommandSet is implemented by plugins that provide structured commands.
+type CommandSet interface {
+	Commands() []*Command
+}
+
+func (c *Command) matches(name string) bool {
+	if strings.EqualFold(c.Name, name) {
+		return true
+	}
+
+	for _, alias := range c.Aliases {
+		if strings.EqualFold(alias, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setPlugin records the plugin that registered the command and its
+// subcommands.
+func (c *Command) setPlugin(name string) {
+	c.plugin = name
+
+	for _, subcommand := range c.Subcommands {
+		subcommand.setPlugin(name)
+	}
+}
+
+func (c *Command) subcommand(name string) *Command {
+	for _, subcommand := range c.Subcommands {
+		if subcommand.matches(name) {
+			return subcommand
+		}
+	}
+
+	return nil
+}
+
+// Usage describes how the command is invoked, e.g.
+//
+//	play <url> [--from <duration>]
+func (c *Command) Usage() string {
+	return c.usage(c.Name)
+}
+
+func (c *Command) usage(path string) string {
+	if len(c.Subcommands) > 0 && c.Handler == nil {
+		names := make([]string, 0, len(c.Subcommands))
+
+		for _, subcommand := range c.Subcommands {
+			names = append(names, subcommand.Name)
+		}
+
+		return path + " <" + strings.Join(names, "|") + ">"
+	}
+
+	parts := []string{path}
+
+	for _, arg := range c.Args {
+		if arg.Optional {
+			parts = append(parts, "["+arg.Name+"]")
+		} else {
+			parts = append(parts, "<"+arg.Name+">")
+		}
+	}
+
+	for _, flag := range c.Flags {
+		if flag.Kind == ArgBool {
+			parts = append(parts, "[--"+flag.Name+"]")
+		} else {
+			parts = append(parts, "[--"+flag.Name+" <"+flag.Kind.String()+">]")
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// UsageError is returned when a command is invoked incorrectly.
+type UsageError struct {
+	Usage  string
+	Reason string
+}
+
+func (e *UsageError) Error() string {
+	return e.Reason + "\nUsage: `" + e.Usage + "`"
+}
+
+// UnknownCommandError is returned when a message doesn't name a command.
+type UnknownCommandError struct {
+	Name string
+
+	// Suggestion is the command the name is probably a typo of, if any.
+	Suggestion string
+}
+
+func (e *UnknownCommandError) Error() string {
+	if e.Name == "" {
+		return "No command given. Try `help`."
+	}
+
+	if e.Suggestion != "" {
+		return fmt.Sprintf("I don't know the command `%s`. Did you mean `%s`?", e.Name, e.Suggestion)
+	}
+
+	return fmt.Sprintf("I don't know the command `%s`. Try `help`.", e.Name)
+}
+
+// maxSuggestionDistance is how many edits a name may be from a command's for
+// the command to be suggested.
+const maxSuggestionDistance = 2
+
+// Invocation is a single invocation of a command, either through a message or
+// through an application command interaction.
+type Invocation struct {
+	Bot       *Bot
+	Command   *Command
+	Author    *discordgo.User
+	ChannelID string
+	GuildID   string
+
+	// Context is cancelled when the command runs past its plugin's timeout or
+	// the bot closes. Handlers should pass it to anything slow.
+	Context context.Context
+
+	// Message is the message that invoked the command. It's nil for application
+	// command interactions.
+	Message *discordgo.Message
+
+	args  map[string]interface{}
+	flags map[string]interface{}
+
+	// path is the command's full name, such as "radio add".
+	path string
+
+	// permission is the permission needed to invoke the command.
+	permission string
+
+	// cooldown limits how often the command can be used.
+	cooldown Cooldown
+
+	interaction *discordgo.Interaction
+
+	lock      sync.Mutex
+	deferred  bool
+	responded bool
+}
+
+// orderKey is the key that orders the invocation's job. Immediate commands
+// get a key of their own.
+func (i *Invocation) orderKey() string {
+	switch {
+	case !i.Command.Immediate:
+		return orderKey(i.GuildID, i.ChannelID)
+
+	case i.interaction != nil:
+		return "interaction:" + i.interaction.ID
+
+	default:
+		return "message:" + i.Message.ID
+	}
+}
+
+// Path is the command's full name, such as "radio add".
+func (i *Invocation) Path() string {
+	return i.path
+}
+
+// Has reports whether the argument or flag was given.
+func (i *Invocation) Has(name string) bool {
+	if _, ok := i.args[name]; ok {
+		return true
+	}
+
+	_, ok := i.flags[name]
+
+	return ok
+}
+
+func (i *Invocation) value(name string) interface{} {
+	if value, ok := i.args[name]; ok {
+		return value
+	}
+
+	return i.flags[name]
+}
+
+// String returns the string or text argument or flag with the given name.
+func (i *Invocation) String(name string) string {
+	value, _ := i.value(name).(string)
+	return value
+}
+
+// Int returns the integer argument or flag with the given name.
+func (i *Invocation) Int(name string) int {
+	value, _ := i.value(name).(int)
+	return value
+}
+
+// Duration returns the duration argument or flag with the given name.
+func (i *Invocation) Duration(name string) time.Duration {
+	value, _ := i.value(name).(time.Duration)
+	return value
+}
+
+// Bool returns the boolean flag with the given name.
+func (i *Invocation) Bool(name string) bool {
+	value, _ := i.value(name).(bool)
+	return value
+}
+
+// Defer acknowledges the invocation ahead of slow work. Interactions must be
+// responded to within three seconds, so this shows that the bot is thinking
+// and the next Reply fills in the response. For messages it shows that the bot
+// is typing.
+func (i *Invocation) Defer() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.interaction == nil {
+		_ = i.Bot.Session().ChannelTyping(i.ChannelID)
+		return
+	}
+
+	if i.deferred || i.responded {
+		return
+	}
+
+	err := i.Bot.Session().InteractionRespond(i.interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	if err != nil {
+		i.Bot.chatLog.WithError(err).Error("Couldn't defer interaction response")
+		return
+	}
+
+	i.deferred = true
+}
+
+// Reply replies to the invoker. Long replies are split into several messages,
+// which are sent in order with the bot's other messages to the channel.
+func (i *Invocation) Reply(content string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	var err error
+
+	if i.interaction == nil {
+		_, err = i.Bot.ReplyToMessage(i.Message, content)
+	} else {
+		err = i.replyToInteraction(content)
+	}
+
+	if err != nil {
+		i.Bot.chatLog.WithError(err).Error("Couldn't reply to command")
+	}
+}
+
+// replyToInteraction responds to the interaction with the first part of the
+// content, or follows up on its response, then follows up with the rest.
+func (i *Invocation) replyToInteraction(content string) error {
+	session := i.Bot.Session()
+	responded, deferred := i.responded, i.deferred
+
+	post := func(part string) (*discordgo.Message, error) {
+		switch {
+		case responded:
+			return session.FollowupMessageCreate(i.interaction, true, &discordgo.WebhookParams{
+				Content: part,
+			})
+
+		case deferred:
+			message, err := session.InteractionResponseEdit(i.interaction, &discordgo.WebhookEdit{
+				Content: &part,
+			})
+
+			responded = err == nil
+
+			return message, err
+
+		default:
+			err := session.InteractionRespond(i.interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{Content: part},
+			})
+
+			responded = err == nil
+
+			return nil, err
+		}
+	}
+
+	sent, err := i.Bot.outbox.send(context.Background(), i.ChannelID, SplitMessage(content, MaxMessageLength), post)
+
+	if len(sent) > 0 {
+		i.responded = true
+	}
+
+	return err
+}
+
+// permitted checks whether the invoker has the command's permission, letting
+// them know if they don't.
+func (i *Invocation) permitted() bool {
+	if !i.Bot.Config().Guild(i.GuildID).CommandEnabled(i.path) {
+		i.Reply("Sorry, `" + i.path + "` is disabled here.")
+		return false
+	}
+
+	if i.Bot.HasPermission(i.GuildID, i.Author.ID, i.permission) {
+		return true
+	}
+
+	i.Bot.chatLog.WithFields(log.Fields{
+		"command":    i.Command.Name,
+		"user":       i.Author.ID,
+		"permission": i.permission,
+	}).Info("Denied command")
+
+	i.Reply("Sorry, you need the `" + i.permission + "` permission to do that.")
+
+	return false
+}
+
+// finish ensures that an interaction gets a response even if the handler
+// didn't reply, otherwise Discord reports that the interaction failed.
+func (i *Invocation) finish() {
+	i.lock.Lock()
+	needsResponse := i.interaction != nil && !i.responded
+	i.lock.Unlock()
+
+	if needsResponse {
+		i.Reply("Done.")
+	}
+}
+
+// token is a word of the command's text along with its offset in the text.
+type token struct {
+	text  string
+	start int
+}
+
+// tokenize splits the text into words, treating text within single or double
+// quotes as a single word. A backslash escapes the character after it.
+func tokenize(text string) ([]token, error) {
+	var (
+		tokens  []token
+		current []rune
+		start   = -1
+		quote   rune
+		escaped bool
+	)
+
+	flush := func() {
+		if start >= 0 {
+			tokens = append(tokens, token{text: string(current), start: start})
+		}
+
+		current, start = nil, -1
+	}
+
+	for offset, r := range text {
+		switch {
+		case escaped:
+			current = append(current, r)
+			escaped = false
+
+		case r == '\\':
+			if start < 0 {
+				start = offset
+			}
+
+			escaped = true
+
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current = append(current, r)
+			}
+
+		case r == '"' || r == '\'':
+			if start < 0 {
+				start = offset
+			}
+
+			quote = r
+
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+
+		default:
+			if start < 0 {
+				start = offset
+			}
+
+			current = append(current, r)
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated quote")
+	}
+
+	flush()
+
+	return tokens, nil
+}
+
+// ParseDuration parses durations written as seconds (90), as clock time (1:30,
+// 1:02:03), or in Go's duration format (1m30s).
+func ParseDuration(text string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(text); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	if strings.Contains(text, ":") {
+		parts := strings.Split(text, ":")
+
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("Invalid duration: %s", text)
+		}
+
+		var total int
+
+		for _, part := range parts {
+			n, err := strconv.Atoi(part)
+
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("Invalid duration: %s", text)
+			}
+
+			total = total*60 + n
+		}
+
+		return time.Duration(total) * time.Second, nil
+	}
+
+	return time.ParseDuration(text)
+}
+
+func parseValue(kind ArgKind, text string) (interface{}, error) {
+	switch kind {
+	case ArgInt:
+		n, err := strconv.Atoi(text)
+
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a number", text)
+		}
+
+		return n, nil
+
+	case ArgDuration:
+		duration, err := ParseDuration(text)
+
+		if err != nil {
+			return nil, fmt.Errorf("%q isn't a duration", text)
+		}
+
+		return duration, nil
+
+	default:
+		return text, nil
+	}
+}
+
+func (c *Command) flag(name string) (Flag, bool) {
+	for _, flag := range c.Flags {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+
+	return Flag{}, false
+}
+
+// parse fills in the invocation's arguments and flags from the tokens that
+// follow the command's name in text.
+func (c *Command) parse(text, path string, tokens []token, invocation *Invocation) error {
+	usageError := func(format string, args ...interface{}) error {
+		return &UsageError{Usage: c.usage(path), Reason: fmt.Sprintf(format, args...)}
+	}
+
+	invocation.args = map[string]interface{}{}
+	invocation.flags = map[string]interface{}{}
+
+	position := 0
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		if position < len(c.Args) && c.Args[position].Kind == ArgText {
+			// A single quoted token is taken without its quotes, otherwise the text
+			// is taken as it was written.
+			if i == len(tokens)-1 {
+				invocation.args[c.Args[position].Name] = tok.text
+			} else {
+				invocation.args[c.Args[position].Name] = strings.TrimSpace(text[tok.start:])
+			}
+
+			position++
+			break
+		}
+
+		if strings.HasPrefix(tok.text, "--") && len(tok.text) > 2 {
+			name, value := tok.text[2:], ""
+			hasValue := false
+
+			if equals := strings.Index(name, "="); equals >= 0 {
+				name, value, hasValue = name[:equals], name[equals+1:], true
+			}
+
+			flag, ok := c.flag(name)
+
+			if !ok {
+				return usageError("Unknown flag --%s", name)
+			}
+
+			if flag.Kind == ArgBool {
+				invocation.flags[name] = value != "false"
+				continue
+			}
+
+			if !hasValue {
+				if i+1 >= len(tokens) {
+					return usageError("Flag --%s needs a %s", name, flag.Kind)
+				}
+
+				i++
+				value = tokens[i].text
+			}
+
+			parsed, err := parseValue(flag.Kind, value)
+
+			if err != nil {
+				return usageError("Flag --%s: %s", name, err)
+			}
+
+			invocation.flags[name] = parsed
+			continue
+		}
+
+		if position >= len(c.Args) {
+			return usageError("Too many arguments")
+		}
+
+		arg := c.Args[position]
+		parsed, err := parseValue(arg.Kind, tok.text)
+
+		if err != nil {
+			return usageError("Argument %s: %s", arg.Name, err)
+		}
+
+		invocation.args[arg.Name] = parsed
+		position++
+	}
+
+	for _, arg := range c.Args[position:] {
+		if !arg.Optional {
+			return usageError("Missing %s", arg.Name)
+		}
+	}
+
+	return nil
+}
+
+// Router dispatches commands to exactly one registered handler.
+type Router struct {
+	commands []*Command
+}
+
+// NewRouter creates a Router with a built-in help command.
+func NewRouter() *Router {
+	router := &Router{}
+
+	router.commands = append(router.commands, &Command{
+		Name:        "help",
+		Description: "Lists the commands or describes one of them",
+		Args:        []Arg{{Name: "command", Optional: true}},
+		Handler:     router.help,
+	})
+
+	return router
+}
+
+// Register registers a command, failing if its name or any of its aliases are
+// already taken.
+func (r *Router) Register(command *Command) error {
+	names := append([]string{command.Name}, command.Aliases...)
+
+	for _, name := range names {
+		if existing := r.lookup(name); existing != nil {
+			return fmt.Errorf("Command name %q is already taken by %q", name, existing.Name)
+		}
+	}
+
+	r.commands = append(r.commands, command)
+
+	return nil
+}
+
+func (r *Router) lookup(name string) *Command {
+	for _, command := range r.commands {
+		if command.matches(name) {
+			return command
+		}
+	}
+
+	return nil
+}
+
+// suggest returns the name of the command closest to the unknown name, or ""
+// if none is close enough to be what was meant. Short names must be closer,
+// since every short name is a few edits from every other.
+func (r *Router) suggest(name string) string {
+	name = strings.ToLower(name)
+
+	best, bestDistance := "", maxSuggestionDistance+1
+
+	if limit := len([]rune(name)) / 2; limit < maxSuggestionDistance {
+		bestDistance = limit + 1
+	}
+
+	for _, command := range r.commands {
+		for _, candidate := range append([]string{command.Name}, command.Aliases...) {
+			if distance := editDistance(name, strings.ToLower(candidate)); distance < bestDistance {
+				best, bestDistance = command.Name, distance
+			}
+		}
+	}
+
+	return best
+}
+
+// editDistance is the Levenshtein distance between the strings: how many
+// runes must be inserted, deleted or substituted to turn one into the other.
+func editDistance(a, b string) int {
+	source, target := []rune(a), []rune(b)
+
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+
+			current[j] = previous[j-1] + cost
+
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(target)]
+}
+
+// exists checks whether a command has the path, such as "radio add". Only
+// commands' names are matched, not their aliases.
+func (r *Router) exists(path string) bool {
+	names := strings.Fields(path)
+
+	if len(names) == 0 {
+		return false
+	}
+
+	var command *Command
+
+	for _, candidate := range r.commands {
+		if candidate.Name == names[0] {
+			command = candidate
+		}
+	}
+
+	for _, name := range names[1:] {
+		if command == nil {
+			return false
+		}
+
+		var subcommand *Command
+
+		for _, candidate := range command.Subcommands {
+			if candidate.Name == name {
+				subcommand = candidate
+			}
+		}
+
+		command = subcommand
+	}
+
+	return command != nil
+}
+
+// route resolves the command text to a command and its parsed invocation.
+func (r *Router) route(text string) (*Invocation, error) {
+	tokens, err := tokenize(text)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, &UnknownCommandError{}
+	}
+
+	command := r.lookup(tokens[0].text)
+
+	if command == nil {
+		return nil, &UnknownCommandError{Name: tokens[0].text, Suggestion: r.suggest(tokens[0].text)}
+	}
+
+	path := command.Name
+	permission := command.Permission
+	cooldown := command.Cooldown
+	tokens = tokens[1:]
+
+	for len(tokens) > 0 {
+		subcommand := command.subcommand(tokens[0].text)
+
+		if subcommand == nil {
+			break
+		}
+
+		command = subcommand
+		path += " " + subcommand.Name
+		tokens = tokens[1:]
+
+		if subcommand.Permission != "" {
+			permission = subcommand.Permission
+		}
+
+		if !subcommand.Cooldown.isZero() {
+			cooldown = subcommand.Cooldown
+		}
+	}
+
+	if command.Handler == nil {
+		return nil, &UsageError{Usage: command.usage(path), Reason: "Which one?"}
+	}
+
+	invocation := &Invocation{Command: command, path: path, permission: permission, cooldown: cooldown}
+
+	if err := command.parse(text, path, tokens, invocation); err != nil {
+		return nil, err
+	}
+
+	return invocation, nil
+}
+
+func (r *Router) help(invocation *Invocation) {
+	if name := invocation.String("command"); name != "" {
+		command := r.lookup(name)
+
+		if command == nil {
+			invocation.Reply(fmt.Sprintf("I don't know the command `%s`.", name))
+			return
+		}
+
+		lines := []string{"`" + command.Usage() + "` " + command.Description}
+
+		for _, subcommand := range command.Subcommands {
+			lines = append(lines, "`"+subcommand.usage(command.Name+" "+subcommand.Name)+"` "+subcommand.Description)
+		}
+
+		invocation.Reply(strings.Join(lines, "\n"))
+		return
+	}
+
+	names := make([]string, 0, len(r.commands))
+
+	for _, command := range r.commands {
+		names = append(names, "`"+command.Name+"`")
+	}
+
+	sort.Strings(names)
+
+	invocation.Reply("Commands: " + strings.Join(names, ", "))
+}
+
+// Command runs the command in a message that commands the bot. This allows
+// the Router to be used as a Commander.
+func (r *Router) Command(ctx context.Context, b *Bot, msg *discordgo.Message) {
+	if invocation, ok := r.prepare(b, msg); ok {
+		r.execute(ctx, invocation)
+	}
+}
+
+// prepare routes a message that commands the bot, replying with the usage if
+// it's malformed. It reports whether the author may run the command.
+func (r *Router) prepare(b *Bot, msg *discordgo.Message) (*Invocation, bool) {
+	text, prefixed, ok := b.commandText(msg)
+
+	if !ok {
+		return nil, false
+	}
+
+	invocation, err := r.route(text)
+
+	if err != nil {
+		// Direct messages that don't start with a command are just messages, and
+		// prefixed ones are only answered if they look like a mistyped command,
+		// since prefixes are easily used by accident.
+		if unknown, ok := err.(*UnknownCommandError); ok {
+			if !prefixed || (unknown.Name != "" && unknown.Suggestion == "") {
+				return nil, false
+			}
+		}
+
+		if _, err := b.ReplyToMessage(msg, err.Error()); err != nil {
+			b.chatLog.WithError(err).Error("Couldn't reply with usage")
+		}
+
+		return nil, false
+	}
+
+	invocation.Bot = b
+	invocation.Message = msg
+	invocation.Author = msg.Author
+	invocation.ChannelID = msg.ChannelID
+	invocation.GuildID, _ = b.MessageGuildID(msg)
+
+	if !invocation.permitted() || !invocation.withinCooldown() {
+		return nil, false
+	}
+
+	b.chatLog.WithFields(log.Fields{
+		"command": invocation.Command.Name,
+		"user":    msg.Author.ID,
+	}).Info("Dispatching command")
+
+	return invocation, true
+}
+
+// execute runs the invocation's handler with the context.
+func (r *Router) execute(ctx context.Context, invocation *Invocation) {
+	invocation.Context = ctx
+
+	invocation.Command.Handler(invocation)
+	invocation.finish()
+
+	invocation.Bot.bus.Publish(&CommandExecutedEvent{Invocation: invocation})
+}
@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens, err := tokenize(`play "some song" --from 1:30 it\'s`)
+
+	assert.Nil(t, err)
+
+	var texts []string
+
+	for _, tok := range tokens {
+		texts = append(texts, tok.text)
+	}
+
+	assert.Equal(t, []string{"play", "some song", "--from", "1:30", "it's"}, texts)
+	assert.Equal(t, 5, tokens[1].start)
+
+	_, err = tokenize(`say "unterminated`)
+
+	assert.NotNil(t, err)
+}
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"90":      90 * time.Second,
+		"1:30":    90 * time.Second,
+		"1:02:03": time.Hour + 2*time.Minute + 3*time.Second,
+		"1m30s":   90 * time.Second,
+	}
+
+	for text, expected := range cases {
+		duration, err := ParseDuration(text)
+
+		assert.Nil(t, err)
+		assert.Equal(t, expected, duration, text)
+	}
+
+	_, err := ParseDuration("soon")
+
+	assert.NotNil(t, err)
+}
+
+func testRouter() *Router {
+	router := NewRouter()
+	noop := func(*Invocation) {}
+
+	router.Register(&Command{
+		Name:    "play",
+		Aliases: []string{"p"},
+		Args:    []Arg{{Name: "url"}},
+		Flags: []Flag{
+			{Name: "from", Kind: ArgDuration},
+			{Name: "loud", Kind: ArgBool},
+		},
+		Handler: noop,
+	})
+
+	router.Register(&Command{Name: "player", Handler: noop})
+
+	router.Register(&Command{
+		Name: "voice",
+		Subcommands: []*Command{
+			{Name: "rate", Args: []Arg{{Name: "n", Kind: ArgInt}}, Handler: noop},
+		},
+	})
+
+	router.Register(&Command{
+		Name:    "say",
+		Args:    []Arg{{Name: "text", Kind: ArgText}},
+		Handler: noop,
+	})
+
+	return router
+}
+
+func TestRouterDispatchesExactlyOne(t *testing.T) {
+	router := testRouter()
+
+	invocation, err := router.route("player")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "player", invocation.Command.Name)
+
+	invocation, err = router.route("p http://example.com --from=1:30 --loud")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "play", invocation.Command.Name)
+	assert.Equal(t, "http://example.com", invocation.String("url"))
+	assert.Equal(t, 90*time.Second, invocation.Duration("from"))
+	assert.True(t, invocation.Bool("loud"))
+
+	invocation, err = router.route("voice rate 3")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 3, invocation.Int("n"))
+
+	invocation, err = router.route(`say  hello "there"  friend`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `hello "there"  friend`, invocation.String("text"))
+
+	assert.NotNil(t, router.Register(&Command{Name: "p"}))
+}
+
+func TestRouterUsageErrors(t *testing.T) {
+	router := testRouter()
+
+	_, err := router.route("play")
+
+	if assert.IsType(t, &UsageError{}, err) {
+		assert.Equal(t, "play <url> [--from <duration>] [--loud]", err.(*UsageError).Usage)
+	}
+
+	_, err = router.route("voice rate fast")
+
+	assert.IsType(t, &UsageError{}, err)
+
+	_, err = router.route("voice")
+
+	if assert.IsType(t, &UsageError{}, err) {
+		assert.Equal(t, "voice <rate>", err.(*UsageError).Usage)
+	}
+
+	_, err = router.route("play a b")
+
+	assert.IsType(t, &UsageError{}, err)
+
+	_, err = router.route("dance")
+
+	if assert.IsType(t, &UnknownCommandError{}, err) {
+		assert.Empty(t, err.(*UnknownCommandError).Suggestion)
+	}
+
+	_, err = router.route("pley a")
+
+	if assert.IsType(t, &UnknownCommandError{}, err) {
+		assert.Equal(t, "play", err.(*UnknownCommandError).Suggestion)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("play", "play"))
+	assert.Equal(t, 1, editDistance("pley", "play"))
+	assert.Equal(t, 2, editDistance("hello", "help"))
+	assert.Equal(t, 4, editDistance("", "play"))
+	assert.Equal(t, 1, editDistance("écho", "echo"))
+}
+
+func TestInvocationOrderKey(t *testing.T) {
+	invocation := &Invocation{
+		Command:   &Command{Name: "play"},
+		GuildID:   "g",
+		ChannelID: "c",
+		Message:   &discordgo.Message{ID: "m"},
+	}
+
+	assert.Equal(t, "guild:g", invocation.orderKey())
+
+	// Immediate commands don't wait behind the guild's other commands.
+	invocation.Command = &Command{Name: "pause", Immediate: true}
+
+	assert.Equal(t, "message:m", invocation.orderKey())
+
+	invocation.Message = nil
+	invocation.interaction = &discordgo.Interaction{ID: "i"}
+
+	assert.Equal(t, "interaction:i", invocation.orderKey())
+}
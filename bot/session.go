@@ -0,0 +1,220 @@
+package bot
+
+import "github.com/bwmarrin/discordgo"
+
+// Session is the part of the Discord session the bot uses. It's an interface
+// so that tests can use a fake session instead of connecting to Discord.
+type Session interface {
+	Open() error
+	Close() error
+
+	// AddHandler registers a handler for Discord events, which is a function
+	// taking a *discordgo.Session and the event, e.g. *discordgo.MessageCreate.
+	// Fake sessions may pass a nil *discordgo.Session. It returns a function
+	// that removes the handler.
+	AddHandler(handler interface{}) func()
+
+	// User looks up a user. The ID "@me" is the bot itself.
+	User(userID string) (*discordgo.User, error)
+
+	// Channel looks up a channel, from the state if possible.
+	Channel(channelID string) (*discordgo.Channel, error)
+
+	// Guild, Member and Role look up what's in the state.
+	Guild(guildID string) (*discordgo.Guild, error)
+	Member(guildID, userID string) (*discordgo.Member, error)
+	Role(guildID, roleID string) (*discordgo.Role, error)
+
+	ChannelMessageSend(channelID, content string) (*discordgo.Message, error)
+	ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+	ChannelMessageSendReply(channelID, content string, reference *discordgo.MessageReference) (*discordgo.Message, error)
+	ChannelMessageDelete(channelID, messageID string) error
+	ChannelTyping(channelID string) error
+
+	// ChannelVoiceJoin joins the voice channel, moving the guild's connection
+	// if there already is one.
+	ChannelVoiceJoin(guildID, channelID string, mute, deaf bool) (VoiceConnection, error)
+
+	// VoiceConnection is the guild's voice connection, if any.
+	VoiceConnection(guildID string) (VoiceConnection, bool)
+
+	InteractionRespond(interaction *discordgo.Interaction, response *discordgo.InteractionResponse) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, edit *discordgo.WebhookEdit) (*discordgo.Message, error)
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, params *discordgo.WebhookParams) (*discordgo.Message, error)
+	ApplicationCommandBulkOverwrite(appID, guildID string, commands []*discordgo.ApplicationCommand) ([]*discordgo.ApplicationCommand, error)
+}
+
+// VoiceConnection is the part of a Discord voice connection the bot uses.
+type VoiceConnection interface {
+	ChannelID() string
+
+	// Ready reports whether the connection can send and receive audio.
+	Ready() bool
+
+	Speaking(speaking bool) error
+
+	// OpusSend and OpusRecv carry Opus frames. They're nil until the
+	// connection is ready.
+	OpusSend() chan<- []byte
+	OpusRecv() <-chan *discordgo.Packet
+
+	// OnSpeakingUpdate registers a handler for users starting and stopping
+	// speaking.
+	OnSpeakingUpdate(handler func(*discordgo.VoiceSpeakingUpdate))
+
+	Disconnect() error
+}
+
+// discordSession is a Session connected to Discord.
+type discordSession struct {
+	session *discordgo.Session
+}
+
+// NewDiscordSession creates a Session that connects to Discord with the bot
+// token.
+func NewDiscordSession(token string) (Session, error) {
+	session, err := discordgo.New("Bot " + token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// backoffSession handles rate limits itself, which it can only do if
+	// discordgo returns them instead of sleeping and retrying.
+	session.ShouldRetryOnRateLimit = false
+
+	return &discordSession{session: session}, nil
+}
+
+func (s *discordSession) Open() error {
+	return s.session.Open()
+}
+
+func (s *discordSession) Close() error {
+	return s.session.Close()
+}
+
+func (s *discordSession) AddHandler(handler interface{}) func() {
+	return s.session.AddHandler(handler)
+}
+
+func (s *discordSession) User(userID string) (*discordgo.User, error) {
+	return s.session.User(userID)
+}
+
+func (s *discordSession) Channel(channelID string) (*discordgo.Channel, error) {
+	if channel, err := s.session.State.Channel(channelID); err == nil {
+		return channel, nil
+	}
+
+	return s.session.Channel(channelID)
+}
+
+func (s *discordSession) Guild(guildID string) (*discordgo.Guild, error) {
+	return s.session.State.Guild(guildID)
+}
+
+func (s *discordSession) Member(guildID, userID string) (*discordgo.Member, error) {
+	return s.session.State.Member(guildID, userID)
+}
+
+func (s *discordSession) Role(guildID, roleID string) (*discordgo.Role, error) {
+	return s.session.State.Role(guildID, roleID)
+}
+
+// ChannelMessageSend returns rate limit errors rather than retrying, so that
+// the bot can back off sending to the channel.
+func (s *discordSession) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	return s.session.ChannelMessageSend(channelID, content, discordgo.WithRetryOnRatelimit(false))
+}
+
+// ChannelMessageSendEmbed returns rate limit errors like ChannelMessageSend.
+func (s *discordSession) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return s.session.ChannelMessageSendEmbed(channelID, embed, discordgo.WithRetryOnRatelimit(false))
+}
+
+// ChannelMessageSendReply returns rate limit errors like ChannelMessageSend.
+func (s *discordSession) ChannelMessageSendReply(channelID, content string, reference *discordgo.MessageReference) (*discordgo.Message, error) {
+	return s.session.ChannelMessageSendReply(channelID, content, reference, discordgo.WithRetryOnRatelimit(false))
+}
+
+func (s *discordSession) ChannelMessageDelete(channelID, messageID string) error {
+	return s.session.ChannelMessageDelete(channelID, messageID)
+}
+
+func (s *discordSession) ChannelTyping(channelID string) error {
+	return s.session.ChannelTyping(channelID)
+}
+
+func (s *discordSession) ChannelVoiceJoin(guildID, channelID string, mute, deaf bool) (VoiceConnection, error) {
+	voiceConnection, err := s.session.ChannelVoiceJoin(guildID, channelID, mute, deaf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return discordVoiceConnection{voiceConnection}, nil
+}
+
+func (s *discordSession) VoiceConnection(guildID string) (VoiceConnection, bool) {
+	s.session.RLock()
+	voiceConnection, ok := s.session.VoiceConnections[guildID]
+	s.session.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	return discordVoiceConnection{voiceConnection}, true
+}
+
+func (s *discordSession) InteractionRespond(interaction *discordgo.Interaction, response *discordgo.InteractionResponse) error {
+	return s.session.InteractionRespond(interaction, response)
+}
+
+func (s *discordSession) InteractionResponseEdit(interaction *discordgo.Interaction, edit *discordgo.WebhookEdit) (*discordgo.Message, error) {
+	return s.session.InteractionResponseEdit(interaction, edit)
+}
+
+func (s *discordSession) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, params *discordgo.WebhookParams) (*discordgo.Message, error) {
+	return s.session.FollowupMessageCreate(interaction, wait, params)
+}
+
+func (s *discordSession) ApplicationCommandBulkOverwrite(appID, guildID string, commands []*discordgo.ApplicationCommand) ([]*discordgo.ApplicationCommand, error) {
+	return s.session.ApplicationCommandBulkOverwrite(appID, guildID, commands)
+}
+
+// discordVoiceConnection is a VoiceConnection to a Discord voice channel.
+type discordVoiceConnection struct {
+	connection *discordgo.VoiceConnection
+}
+
+func (v discordVoiceConnection) ChannelID() string {
+	return v.connection.ChannelID
+}
+
+func (v discordVoiceConnection) Ready() bool {
+	return v.connection.Ready
+}
+
+func (v discordVoiceConnection) Speaking(speaking bool) error {
+	return v.connection.Speaking(speaking)
+}
+
+func (v discordVoiceConnection) OpusSend() chan<- []byte {
+	return v.connection.OpusSend
+}
+
+func (v discordVoiceConnection) OpusRecv() <-chan *discordgo.Packet {
+	return v.connection.OpusRecv
+}
+
+func (v discordVoiceConnection) OnSpeakingUpdate(handler func(*discordgo.VoiceSpeakingUpdate)) {
+	v.connection.AddHandler(func(_ *discordgo.VoiceConnection, update *discordgo.VoiceSpeakingUpdate) {
+		handler(update)
+	})
+}
+
+func (v discordVoiceConnection) Disconnect() error {
+	return v.connection.Disconnect()
+}
@@ -0,0 +1,246 @@
+package bot
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// VoiceSettings describe the voice the TTS backend should speak with. Empty
+// fields fall back to the backend's defaults.
+type VoiceSettings struct {
+	Name string
+	Rate string
+}
+
+// merge overlays the non-empty fields of other on top of v.
+func (v VoiceSettings) merge(other VoiceSettings) VoiceSettings {
+	if other.Name != "" {
+		v.Name = other.Name
+	}
+
+	if other.Rate != "" {
+		v.Rate = other.Rate
+	}
+
+	return v
+}
+
+// ReadAloudSettings bind a text channel whose messages are read aloud in a
+// voice channel.
+type ReadAloudSettings struct {
+	TextChannelID  string
+	VoiceChannelID string
+}
+
+// Enabled reports whether read-aloud mode is bound to a channel.
+func (r ReadAloudSettings) Enabled() bool {
+	return r.TextChannelID != "" && r.VoiceChannelID != ""
+}
+
+// GuildSettings are settings that apply to an entire guild.
+type GuildSettings struct {
+	Voice     VoiceSettings
+	ReadAloud ReadAloudSettings
+
+	// Language selects the presence announcement templates.
+	Language string
+
+	// PresenceTemplates are the guild's own announcement templates, keyed by
+	// language.
+	PresenceTemplates map[string]PresenceTemplates
+
+	// Prefixes are the text prefixes that invoke commands in addition to
+	// mentioning the bot, e.g. ! or bmo,
+	Prefixes []string
+
+	// Grants are the permissions granted to the guild's users and roles.
+	Grants []Grant
+
+	// VoteSkipFraction is the fraction of listeners that must vote to skip a
+	// track. Zero means DefaultVoteSkipFraction.
+	VoteSkipFraction float64
+
+	// FairQueue plays queued tracks round-robin by requester instead of in the
+	// order they were queued.
+	FairQueue bool
+
+	// Autoplay is where tracks are picked from when the queue runs dry.
+	Autoplay AutoplaySource
+
+	// RadioStations are the guild's saved live stream URLs.
+	RadioStations []RadioStation
+}
+
+// RadioStation is a saved live stream.
+type RadioStation struct {
+	Name string
+	URL  string
+}
+
+// RadioStation finds the guild's station with the name, ignoring case.
+func (g GuildSettings) RadioStation(name string) (RadioStation, bool) {
+	for _, station := range g.RadioStations {
+		if strings.EqualFold(station.Name, name) {
+			return station, true
+		}
+	}
+
+	return RadioStation{}, false
+}
+
+// DefaultVoteSkipFraction is the fraction of listeners that must vote to skip a
+// track unless the guild sets its own.
+const DefaultVoteSkipFraction = 0.5
+
+// VoteSkipThreshold is the fraction of listeners that must vote to skip a track.
+func (g GuildSettings) VoteSkipThreshold() float64 {
+	if g.VoteSkipFraction <= 0 {
+		return DefaultVoteSkipFraction
+	}
+
+	return g.VoteSkipFraction
+}
+
+// clone creates a copy of the settings that shares no state with them.
+func (g *GuildSettings) clone() GuildSettings {
+	clone := *g
+
+	clone.PresenceTemplates = make(map[string]PresenceTemplates, len(g.PresenceTemplates))
+
+	for language, templates := range g.PresenceTemplates {
+		clone.PresenceTemplates[language] = templates
+	}
+
+	clone.Prefixes = append([]string(nil), g.Prefixes...)
+	clone.Grants = append([]Grant(nil), g.Grants...)
+	clone.RadioStations = append([]RadioStation(nil), g.RadioStations...)
+
+	return clone
+}
+
+// UserSettings are settings that follow a user across guilds.
+type UserSettings struct {
+	Voice VoiceSettings
+
+	// AnnounceOptOut means the user's presence changes aren't announced.
+	AnnounceOptOut bool
+}
+
+// settingsBucket holds the guilds' and users' settings, in buckets of their
+// own, keyed by ID.
+var settingsBucket = []string{"settings"}
+
+// Settings holds the per-guild and per-user settings. Every update is saved in
+// the store.
+type Settings struct {
+	lock sync.RWMutex
+
+	guilds map[string]*GuildSettings
+	users  map[string]*UserSettings
+
+	bucket Bucket
+}
+
+// NewSettings creates a Settings with the settings saved in the store.
+func NewSettings(store Store) (*Settings, error) {
+	s := &Settings{
+		guilds: map[string]*GuildSettings{},
+		users:  map[string]*UserSettings{},
+		bucket: NewBucket(store, settingsBucket...),
+	}
+
+	err := s.bucket.Bucket("guilds").ForEach(func(guildID string, value []byte) error {
+		guild := &GuildSettings{}
+		s.guilds[guildID] = guild
+
+		return json.Unmarshal(value, guild)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.bucket.Bucket("users").ForEach(func(userID string, value []byte) error {
+		user := &UserSettings{}
+		s.users[userID] = user
+
+		return json.Unmarshal(value, user)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Guild returns a copy of the settings for the given guild.
+func (s *Settings) Guild(guildID string) GuildSettings {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if guild, ok := s.guilds[guildID]; ok {
+		return guild.clone()
+	}
+
+	return GuildSettings{}
+}
+
+// User returns a copy of the settings for the given user.
+func (s *Settings) User(userID string) UserSettings {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if user, ok := s.users[userID]; ok {
+		return *user
+	}
+
+	return UserSettings{}
+}
+
+// UpdateGuild atomically modifies the settings for the given guild.
+func (s *Settings) UpdateGuild(guildID string, update func(*GuildSettings)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	guild, ok := s.guilds[guildID]
+
+	if !ok {
+		guild = &GuildSettings{}
+		s.guilds[guildID] = guild
+	}
+
+	update(guild)
+
+	if err := s.bucket.Bucket("guilds").PutJSON(guildID, guild); err != nil {
+		log.WithField("guild", guildID).WithError(err).Error("Couldn't save guild settings")
+	}
+}
+
+// UpdateUser atomically modifies the settings for the given user.
+func (s *Settings) UpdateUser(userID string, update func(*UserSettings)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	user, ok := s.users[userID]
+
+	if !ok {
+		user = &UserSettings{}
+		s.users[userID] = user
+	}
+
+	update(user)
+
+	if err := s.bucket.Bucket("users").PutJSON(userID, user); err != nil {
+		log.WithField("user", userID).WithError(err).Error("Couldn't save user settings")
+	}
+}
+
+// Voice resolves the voice a user should speak with in a guild. The user's own
+// settings take precedence over the guild's.
+func (s *Settings) Voice(guildID, userID string) VoiceSettings {
+	return s.Guild(guildID).Voice.merge(s.User(userID).Voice)
+}
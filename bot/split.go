@@ -0,0 +1,186 @@
+package bot
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxMessageLength is the longest message Discord accepts.
+const MaxMessageLength = 2000
+
+const (
+	// closingFence closes a code block that's split across messages.
+	closingFence = "\n```"
+
+	// maxFenceLength is the longest line that opens a code block: the fence
+	// and a language of up to 20 characters.
+	maxFenceLength = len("```") + 20
+)
+
+// fenceLanguage is what the language that follows an opening fence looks like.
+// Anything else after it is code, not a language.
+var fenceLanguage = regexp.MustCompile(`^[A-Za-z0-9_+#.-]{1,20}$`)
+
+// SplitMessage splits the content into parts no longer than the limit, in
+// bytes, preferring to split between paragraphs and around code blocks, then
+// between lines, then between words. A code block that's split anyway is closed
+// at the end of one part and opened again, with the same language, at the start
+// of the next, so that each part renders on its own. Code blocks are left alone
+// if the limit is too short to close and reopen them.
+func SplitMessage(content string, limit int) []string {
+	var parts []string
+
+	balance := limit > maxFenceLength+len("\n")+len(closingFence)
+
+	// fence is the line that opened the code block the rest of the content is
+	// in, if it's in one.
+	fence := ""
+
+	for {
+		prefix := ""
+
+		if fence != "" {
+			prefix = fence + "\n"
+		}
+
+		if len(prefix)+len(content) <= limit {
+			return append(parts, prefix+content)
+		}
+
+		room := limit
+
+		if balance {
+			room -= len(prefix) + len(closingFence)
+		}
+
+		cut, skip := splitPoint(content, fence, room)
+		piece := strings.TrimRight(content[:cut], " \n")
+
+		if fence == "" && piece == "" {
+			piece = content[:cut]
+		}
+
+		part := prefix + piece
+
+		if balance {
+			fence = fenceAfter(content[:cut], fence)
+		}
+
+		if fence != "" {
+			part += closingFence
+		}
+
+		parts = append(parts, part)
+		content = content[cut+skip:]
+
+		if fence == "" {
+			content = strings.TrimLeft(content, "\n")
+		}
+
+		if content == "" {
+			return parts
+		}
+	}
+}
+
+// splitPoint finds where to split the text so that the part before it fits in
+// the room, and how many separating bytes to drop there. fence is the code
+// block the text starts in, if any.
+func splitPoint(text, fence string, room int) (cut, skip int) {
+	paragraph, line := -1, -1
+
+	for start := 0; start < len(text); {
+		end := strings.IndexByte(text[start:], '\n')
+
+		if end < 0 || start+end > room {
+			break
+		}
+
+		end += start
+		current := text[start:end]
+		next := text[end+1:]
+
+		fence = fenceAfter(current, fence)
+
+		// Blank lines and the lines around code blocks separate paragraphs, but
+		// splitting inside a code block never does.
+		if fence == "" && (strings.HasPrefix(next, "\n") ||
+			strings.HasPrefix(next, "```") ||
+			strings.HasPrefix(current, "```")) {
+			paragraph = end
+		}
+
+		line = end
+		start = end + 1
+	}
+
+	window := text
+
+	if len(window) > room+1 {
+		window = window[:room+1]
+	}
+
+	space := strings.LastIndexByte(window, ' ')
+
+	for _, candidate := range []int{paragraph, line, space} {
+		if candidate >= room/2 {
+			return candidate, 1
+		}
+	}
+
+	for _, candidate := range []int{line, space} {
+		if candidate > 0 {
+			return candidate, 1
+		}
+	}
+
+	// Split inside a word, though not inside a character.
+	cut = room
+
+	if cut >= len(text) {
+		return len(text), 0
+	}
+
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+
+	if cut == 0 {
+		cut = room
+	}
+
+	return cut, 0
+}
+
+// fenceAfter is the fence line of the code block that's open after the text,
+// given the one that was open before it, or empty if none is.
+func fenceAfter(text, fence string) string {
+	for {
+		i := strings.Index(text, "```")
+
+		if i < 0 {
+			return fence
+		}
+
+		text = text[i+3:]
+
+		if fence != "" {
+			fence = ""
+			continue
+		}
+
+		// The language follows the opening fence on the same line.
+		language := text
+
+		if end := strings.IndexAny(language, "\n`"); end >= 0 {
+			language = language[:end]
+		}
+
+		if !fenceLanguage.MatchString(language) {
+			language = ""
+		}
+
+		fence = "```" + language
+	}
+}
@@ -0,0 +1,104 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// assertParts checks that the parts fit and that their code blocks are
+// balanced.
+func assertParts(t *testing.T, parts []string, limit int) {
+	for _, part := range parts {
+		assert.True(t, len(part) <= limit, "part is %d bytes: %q", len(part), part)
+		assert.Equal(t, 0, strings.Count(part, "```")%2, "unbalanced code block: %q", part)
+		assert.True(t, utf8.ValidString(part))
+	}
+}
+
+func TestSplitMessageShort(t *testing.T) {
+	assert.Equal(t, []string{"hello"}, SplitMessage("hello", 10))
+	assert.Equal(t, []string{"0123456789"}, SplitMessage("0123456789", 10))
+}
+
+func TestSplitMessageParagraphs(t *testing.T) {
+	first := strings.Repeat("a", 30)
+	second := strings.Repeat("b", 30)
+	third := strings.Repeat("c", 30)
+
+	parts := SplitMessage(first+"\n\n"+second+"\nmore "+third, 50)
+
+	assertParts(t, parts, 50)
+	assert.Equal(t, []string{first, second, "more " + third}, parts)
+}
+
+func TestSplitMessageWords(t *testing.T) {
+	parts := SplitMessage("the quick brown fox jumps over the lazy dog", 20)
+
+	assertParts(t, parts, 20)
+	assert.Equal(t, []string{"the quick brown fox", "jumps over the lazy", "dog"}, parts)
+}
+
+func TestSplitMessageLongWord(t *testing.T) {
+	parts := SplitMessage(strings.Repeat("é", 15), 10)
+
+	assertParts(t, parts, 10)
+	assert.Equal(t, strings.Repeat("é", 15), strings.Join(parts, ""))
+}
+
+func TestSplitMessageKeepsCodeBlocksTogether(t *testing.T) {
+	code := "```go\nfmt.Println()\n```"
+	content := strings.Repeat("x", 20) + "\n" + code + "\n" + strings.Repeat("y", 20)
+
+	parts := SplitMessage(content, 40)
+
+	assertParts(t, parts, 40)
+	assert.Contains(t, parts, code)
+}
+
+func TestSplitMessageBalancesCodeBlocks(t *testing.T) {
+	var lines []string
+
+	for i := 0; i < 20; i++ {
+		lines = append(lines, "line of code")
+	}
+
+	content := "Look:\n```go\n" + strings.Join(lines, "\n") + "\n```\nDone."
+
+	parts := SplitMessage(content, 100)
+
+	assertParts(t, parts, 100)
+	assert.True(t, len(parts) > 2)
+
+	for _, part := range parts[1 : len(parts)-1] {
+		assert.True(t, strings.HasPrefix(part, "```go\n"), "part isn't reopened: %q", part)
+		assert.True(t, strings.HasSuffix(part, "\n```"), "part isn't closed: %q", part)
+	}
+
+	assert.Equal(t, strings.Count(content, "line of code"), strings.Count(strings.Join(parts, ""), "line of code"))
+}
+
+func TestSplitMessageLongInlineFence(t *testing.T) {
+	for _, content := range []string{
+		"```" + strings.Repeat("a", 2500) + "```",
+		"intro\n```" + strings.Repeat("ab", 1500) + "\n```",
+	} {
+		parts := SplitMessage(content, MaxMessageLength)
+
+		assertParts(t, parts, MaxMessageLength)
+		assert.True(t, len(parts) <= 3, "split into %d parts", len(parts))
+	}
+}
+
+func TestFenceAfter(t *testing.T) {
+	assert.Equal(t, "", fenceAfter("no code", ""))
+	assert.Equal(t, "```go", fenceAfter("```go\ncode", ""))
+	assert.Equal(t, "", fenceAfter("```go\ncode\n```", ""))
+	assert.Equal(t, "", fenceAfter("inline ```code``` here", ""))
+	assert.Equal(t, "```", fenceAfter("```not a language\n", ""))
+	assert.Equal(t, "```c++", fenceAfter("```c++\n", ""))
+	assert.Equal(t, "```", fenceAfter("```"+strings.Repeat("a", 21), ""))
+	assert.Equal(t, "", fenceAfter("code\n```", "```go"))
+}
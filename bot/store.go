@@ -0,0 +1,107 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrNotFound is returned when a key isn't in the store.
+var ErrNotFound = errors.New("Not found")
+
+// Store is a key-value store for state that has to survive restarts. Keys
+// live in buckets, which are named by paths such as settings/guilds so that
+// each part of the bot, and each plugin and guild, gets its own namespace.
+type Store interface {
+	// Get returns the value of the key in the bucket, or ErrNotFound.
+	Get(bucket []string, key string) ([]byte, error)
+
+	// Put sets the value of the key in the bucket, creating the bucket if
+	// needed.
+	Put(bucket []string, key string, value []byte) error
+
+	// Delete removes the key from the bucket. Missing keys aren't an error.
+	Delete(bucket []string, key string) error
+
+	// ForEach calls fn with each key in the bucket and its value, in key order.
+	// Nested buckets aren't included.
+	ForEach(bucket []string, fn func(key string, value []byte) error) error
+
+	Close() error
+}
+
+// Bucket is a namespace within a Store.
+type Bucket struct {
+	store Store
+	path  []string
+}
+
+// NewBucket creates the bucket with the path in the store.
+func NewBucket(store Store, path ...string) Bucket {
+	return Bucket{store: store, path: path}
+}
+
+// Bucket is the bucket nested within this one under the names.
+func (b Bucket) Bucket(names ...string) Bucket {
+	path := make([]string, 0, len(b.path)+len(names))
+	path = append(path, b.path...)
+	path = append(path, names...)
+
+	return Bucket{store: b.store, path: path}
+}
+
+// Get returns the value of the key, or ErrNotFound.
+func (b Bucket) Get(key string) ([]byte, error) {
+	return b.store.Get(b.path, key)
+}
+
+// Put sets the value of the key.
+func (b Bucket) Put(key string, value []byte) error {
+	return b.store.Put(b.path, key, value)
+}
+
+// Delete removes the key.
+func (b Bucket) Delete(key string) error {
+	return b.store.Delete(b.path, key)
+}
+
+// ForEach calls fn with each key and its value, in key order.
+func (b Bucket) ForEach(fn func(key string, value []byte) error) error {
+	return b.store.ForEach(b.path, fn)
+}
+
+// GetJSON decodes the JSON value of the key into v.
+func (b Bucket) GetJSON(key string, v interface{}) error {
+	value, err := b.Get(key)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(value, v)
+}
+
+// PutJSON sets the value of the key to v encoded as JSON.
+func (b Bucket) PutJSON(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+
+	if err != nil {
+		return err
+	}
+
+	return b.Put(key, value)
+}
+
+// Store provides access to the bot's persistent store.
+func (b *Bot) Store() Store {
+	return b.store
+}
+
+// PluginBucket is the bucket a plugin keeps its state in.
+func (b *Bot) PluginBucket(plugin string) Bucket {
+	return NewBucket(b.store, "plugins", plugin)
+}
+
+// GuildBucket is the bucket a plugin keeps its state for a guild in.
+func (b *Bot) GuildBucket(plugin, guildID string) Bucket {
+	return b.PluginBucket(plugin).Bucket("guilds", guildID)
+}
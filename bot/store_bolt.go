@@ -0,0 +1,160 @@
+package bot
+
+import (
+	"os"
+	"path"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStore is a Store kept in a bbolt database file. Each part of a bucket's
+// path is a nested bbolt bucket.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens the database at the path, creating it if needed.
+func OpenBoltStore(dbPath string) (*BoltStore, error) {
+	if err := os.MkdirAll(path.Dir(dbPath), 0755); err != nil {
+		return nil, err
+	}
+
+	// Only one process can open the database, so don't wait forever if another
+	// instance of the bot is running.
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 5 * time.Second})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// lookupBucket finds the nested bucket, returning nil if it doesn't exist.
+func lookupBucket(tx *bolt.Tx, names []string) *bolt.Bucket {
+	if len(names) == 0 {
+		return nil
+	}
+
+	bucket := tx.Bucket([]byte(names[0]))
+
+	for _, name := range names[1:] {
+		if bucket == nil {
+			return nil
+		}
+
+		bucket = bucket.Bucket([]byte(name))
+	}
+
+	return bucket
+}
+
+// createBucket finds the nested bucket, creating it if it doesn't exist.
+func createBucket(tx *bolt.Tx, names []string) (*bolt.Bucket, error) {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(names[0]))
+
+	for _, name := range names[1:] {
+		if err != nil {
+			return nil, err
+		}
+
+		bucket, err = bucket.CreateBucketIfNotExists([]byte(name))
+	}
+
+	return bucket, err
+}
+
+// Get returns the value of the key in the bucket, or ErrNotFound.
+func (s *BoltStore) Get(names []string, key string) ([]byte, error) {
+	var value []byte
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := lookupBucket(tx, names)
+
+		if bucket == nil {
+			return ErrNotFound
+		}
+
+		stored := bucket.Get([]byte(key))
+
+		if stored == nil {
+			return ErrNotFound
+		}
+
+		// Values are only valid during the transaction.
+		value = append([]byte(nil), stored...)
+
+		return nil
+	})
+
+	return value, err
+}
+
+// Put sets the value of the key in the bucket, creating the bucket if needed.
+func (s *BoltStore) Put(names []string, key string, value []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := createBucket(tx, names)
+
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put([]byte(key), value)
+	})
+}
+
+// Delete removes the key from the bucket.
+func (s *BoltStore) Delete(names []string, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := lookupBucket(tx, names)
+
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.Delete([]byte(key))
+	})
+}
+
+// ForEach calls fn with each key in the bucket and its value, in key order.
+// The values are read up front so that fn may write to the store.
+func (s *BoltStore) ForEach(names []string, fn func(key string, value []byte) error) error {
+	var keys []string
+	var values [][]byte
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := lookupBucket(tx, names)
+
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key, value []byte) error {
+			// Nested buckets have nil values.
+			if value != nil {
+				keys = append(keys, string(key))
+				values = append(values, append([]byte(nil), value...))
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if err := fn(key, values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close closes the database.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is a Store that's kept in memory, mainly for tests.
+type MemoryStore struct {
+	lock    sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]map[string][]byte{},
+	}
+}
+
+func memoryBucketName(bucket []string) string {
+	return strings.Join(bucket, "\x00")
+}
+
+// Get returns the value of the key in the bucket, or ErrNotFound.
+func (s *MemoryStore) Get(bucket []string, key string) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	value, ok := s.buckets[memoryBucketName(bucket)][key]
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return append([]byte(nil), value...), nil
+}
+
+// Put sets the value of the key in the bucket.
+func (s *MemoryStore) Put(bucket []string, key string, value []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	name := memoryBucketName(bucket)
+
+	if _, ok := s.buckets[name]; !ok {
+		s.buckets[name] = map[string][]byte{}
+	}
+
+	s.buckets[name][key] = append([]byte(nil), value...)
+
+	return nil
+}
+
+// Delete removes the key from the bucket.
+func (s *MemoryStore) Delete(bucket []string, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.buckets[memoryBucketName(bucket)], key)
+
+	return nil
+}
+
+// ForEach calls fn with each key in the bucket and its value, in key order.
+func (s *MemoryStore) ForEach(bucket []string, fn func(key string, value []byte) error) error {
+	s.lock.RLock()
+
+	values := s.buckets[memoryBucketName(bucket)]
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	copied := make([][]byte, len(keys))
+
+	for i, key := range keys {
+		copied[i] = append([]byte(nil), values[key]...)
+	}
+
+	// Don't hold the lock while calling fn, which may write to the store.
+	s.lock.RUnlock()
+
+	for i, key := range keys {
+		if err := fn(key, copied[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Close does nothing.
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// Migration upgrades the store's data to a new schema version.
+type Migration struct {
+	Version     int
+	Description string
+	Migrate     func(Store) error
+}
+
+// migrations upgrade the store in order of their versions. Never change or
+// remove a migration once it's been released; add another instead.
+func migrations(config *Config) []Migration {
+	return []Migration{
+		{
+			Version:     1,
+			Description: "Import the queue and history files",
+			Migrate: func(store Store) error {
+				return importDataFiles(store, config.Paths.Queue, config.Paths.History)
+			},
+		},
+	}
+}
+
+// schemaBucket holds the store's schema version.
+var schemaBucket = []string{"meta"}
+
+// SchemaVersion is the version of the schema the store's data is in. A new
+// store is at version 0.
+func SchemaVersion(store Store) (int, error) {
+	value, err := store.Get(schemaBucket, "schema_version")
+
+	if err == ErrNotFound {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(string(value))
+}
+
+// Migrate applies the migrations newer than the store's schema version in
+// order, recording the version after each one so that a failed migration is
+// retried on the next start.
+func Migrate(store Store, migrations []Migration) error {
+	version, err := SchemaVersion(store)
+
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+
+		logger := log.WithFields(log.Fields{
+			"topic":       "store",
+			"version":     migration.Version,
+			"description": migration.Description,
+		})
+
+		logger.Info("Migrating store")
+
+		if err := migration.Migrate(store); err != nil {
+			return fmt.Errorf("Migration %d (%s) failed: %s", migration.Version, migration.Description, err)
+		}
+
+		if err := store.Put(schemaBucket, "schema_version", []byte(strconv.Itoa(migration.Version))); err != nil {
+			return err
+		}
+
+		version = migration.Version
+	}
+
+	return nil
+}
+
+// importDataFiles moves the queue and history files, where they were kept
+// before they moved into the store, into the store. They're renamed so that
+// they're kept around but not imported again.
+func importDataFiles(store Store, queueFile, historyFile string) error {
+	records, err := readQueueRecords(queueFile)
+
+	if err != nil {
+		return err
+	}
+
+	if records != nil {
+		if err := NewBucket(store, queueBucket...).PutJSON("records", records); err != nil {
+			return err
+		}
+
+		if err := os.Rename(queueFile, queueFile+".imported"); err != nil {
+			return err
+		}
+	}
+
+	entries, err := readHistoryFile(historyFile)
+
+	if err != nil {
+		return err
+	}
+
+	if entries != nil {
+		history := NewHistory(NewBucket(store, historyBucket...))
+
+		for _, entry := range entries {
+			if err := history.Record(entry); err != nil {
+				return err
+			}
+		}
+
+		if err := os.Rename(historyFile, historyFile+".imported"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, store Store) {
+	_, err := store.Get([]string{"a"}, "missing")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, store.Put([]string{"a"}, "2", []byte("two")))
+	assert.NoError(t, store.Put([]string{"a"}, "1", []byte("one")))
+	assert.NoError(t, store.Put([]string{"a", "b"}, "1", []byte("nested")))
+
+	value, err := store.Get([]string{"a"}, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("one"), value)
+
+	value, err = store.Get([]string{"a", "b"}, "1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("nested"), value)
+
+	var keys []string
+
+	err = store.ForEach([]string{"a"}, func(key string, value []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, keys)
+
+	assert.NoError(t, store.ForEach([]string{"none"}, func(string, []byte) error {
+		t.Error("Missing bucket has no keys")
+		return nil
+	}))
+
+	assert.NoError(t, store.Delete([]string{"a"}, "1"))
+	assert.NoError(t, store.Delete([]string{"a"}, "1"))
+	assert.NoError(t, store.Delete([]string{"none"}, "1"))
+
+	_, err = store.Get([]string{"a"}, "1")
+	assert.Equal(t, ErrNotFound, err)
+
+	_, err = store.Get([]string{"a", "b"}, "1")
+	assert.NoError(t, err)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestBoltStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bmo")
+
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	store, err := OpenBoltStore(path.Join(dir, "data", "bmo.db"))
+
+	assert.NoError(t, err)
+
+	testStore(t, store)
+
+	assert.NoError(t, store.Close())
+}
+
+func TestBucket(t *testing.T) {
+	store := NewMemoryStore()
+	bucket := NewBucket(store, "plugins", "hn").Bucket("guilds", "g")
+
+	assert.NoError(t, bucket.PutJSON("seen", []string{"a", "b"}))
+
+	var seen []string
+
+	assert.NoError(t, NewBucket(store, "plugins", "hn", "guilds", "g").GetJSON("seen", &seen))
+	assert.Equal(t, []string{"a", "b"}, seen)
+
+	assert.Equal(t, ErrNotFound, NewBucket(store, "plugins", "hn").GetJSON("seen", &seen))
+}
+
+func TestMigrate(t *testing.T) {
+	store := NewMemoryStore()
+	var applied []int
+
+	migration := func(version int, err error) Migration {
+		return Migration{
+			Version: version,
+			Migrate: func(Store) error {
+				applied = append(applied, version)
+				return err
+			},
+		}
+	}
+
+	version, err := SchemaVersion(store)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	assert.NoError(t, Migrate(store, []Migration{migration(1, nil), migration(2, nil)}))
+	assert.Equal(t, []int{1, 2}, applied)
+
+	applied = nil
+
+	err = Migrate(store, []Migration{
+		migration(1, nil),
+		migration(2, nil),
+		migration(3, nil),
+		migration(4, errors.New("failed")),
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, []int{3, 4}, applied)
+
+	version, err = SchemaVersion(store)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+}
+
+func TestImportDataFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bmo-import")
+	assert.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	config := DefaultConfig()
+	config.Paths.Queue = path.Join(dir, "queue.json")
+	config.Paths.History = path.Join(dir, "history.jsonl")
+
+	assert.NoError(t, ioutil.WriteFile(config.Paths.Queue, []byte(`[{"origin": "a", "guildID": "g"}]`), 0644))
+	assert.NoError(t, ioutil.WriteFile(config.Paths.History, []byte(`{"guildID": "g", "origin": "b"}`+"\n"), 0644))
+
+	store := NewMemoryStore()
+
+	assert.NoError(t, Migrate(store, migrations(config)))
+
+	var records []QueueRecord
+
+	assert.NoError(t, NewBucket(store, queueBucket...).GetJSON("records", &records))
+	assert.Equal(t, []QueueRecord{{Origin: "a", GuildID: "g"}}, records)
+
+	recent := NewHistory(NewBucket(store, historyBucket...)).Recent("g", 10)
+
+	if assert.Len(t, recent, 1) {
+		assert.Equal(t, "b", recent[0].Origin)
+	}
+
+	for _, file := range []string{config.Paths.Queue, config.Paths.History} {
+		_, err := os.Stat(file + ".imported")
+		assert.NoError(t, err)
+	}
+}
+
+func TestSettingsPersist(t *testing.T) {
+	store := NewMemoryStore()
+	settings, err := NewSettings(store)
+
+	assert.NoError(t, err)
+
+	settings.UpdateGuild("g", func(guild *GuildSettings) {
+		guild.Prefixes = []string{"!"}
+		guild.FairQueue = true
+	})
+
+	settings.UpdateUser("u", func(user *UserSettings) {
+		user.AnnounceOptOut = true
+	})
+
+	reloaded, err := NewSettings(store)
+
+	assert.NoError(t, err)
+	assert.Equal(t, settings.Guild("g"), reloaded.Guild("g"))
+	assert.True(t, reloaded.User("u").AnnounceOptOut)
+}
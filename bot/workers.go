@@ -0,0 +1,195 @@
+package bot
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// job is a plugin's command or preview waiting for a worker.
+type job struct {
+	plugin string
+	run    func(context.Context)
+}
+
+// jobQueue holds the jobs waiting to run. Jobs with the same key run one at a
+// time in the order they were dispatched, so that e.g. a guild's commands take
+// effect in the order they were sent, while jobs with different keys run at
+// the same time.
+type jobQueue struct {
+	lock sync.Mutex
+
+	// pending are each key's jobs, starting with the one that's running, if
+	// any.
+	pending map[string][]job
+
+	// count is how many jobs are pending, and limit is how many may be.
+	count int
+	limit int
+
+	// ready are the keys whose next job can run. A key is only in it once, and
+	// not while its job is running, so it never holds more than limit keys.
+	ready chan string
+}
+
+func newJobQueue(limit int) *jobQueue {
+	return &jobQueue{
+		pending: map[string][]job{},
+		limit:   limit,
+		ready:   make(chan string, limit),
+	}
+}
+
+// push adds the job to the key's jobs, reporting whether there was room for it.
+func (q *jobQueue) push(key string, job job) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.count == q.limit {
+		return false
+	}
+
+	q.count++
+	q.pending[key] = append(q.pending[key], job)
+
+	if len(q.pending[key]) == 1 {
+		q.ready <- key
+	}
+
+	return true
+}
+
+// next is the key's job that's ready to run.
+func (q *jobQueue) next(key string) job {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.pending[key][0]
+}
+
+// done removes the key's job that ran, making its next job ready.
+func (q *jobQueue) done(key string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.count--
+	q.pending[key] = q.pending[key][1:]
+
+	if len(q.pending[key]) == 0 {
+		delete(q.pending, key)
+		return
+	}
+
+	q.ready <- key
+}
+
+// startWorkers starts the workers that run commands and previews, so that a
+// slow plugin doesn't hold up handling other messages. They stop when the bot
+// closes.
+func (b *Bot) startWorkers(count, queued int) {
+	b.context, b.cancel = context.WithCancel(context.Background())
+	b.jobs = newJobQueue(queued)
+
+	for i := 0; i < count; i++ {
+		b.workers.Add(1)
+
+		go b.work()
+	}
+}
+
+// Context is cancelled when the bot closes. It's meant for work the bot does
+// in the background rather than for a command or preview.
+func (b *Bot) Context() context.Context {
+	return b.context
+}
+
+// stopWorkers cancels the running jobs and waits for the workers to stop, then
+// runs the jobs that were still waiting, with their contexts cancelled, so that
+// they can let their invokers know.
+func (b *Bot) stopWorkers() {
+	if b.cancel == nil {
+		return
+	}
+
+	b.cancel()
+	b.workers.Wait()
+
+	for {
+		select {
+		case key := <-b.jobs.ready:
+			b.runJob(b.jobs.next(key))
+			b.jobs.done(key)
+
+		default:
+			return
+		}
+	}
+}
+
+func (b *Bot) work() {
+	defer b.workers.Done()
+
+	for {
+		select {
+		case key := <-b.jobs.ready:
+			b.runJob(b.jobs.next(key))
+			b.jobs.done(key)
+
+		case <-b.context.Done():
+			return
+		}
+	}
+}
+
+// orderKey is the key that orders the jobs for a guild, or for a channel
+// outside of guilds.
+func orderKey(guildID, channelID string) string {
+	if guildID != "" {
+		return "guild:" + guildID
+	}
+
+	return "channel:" + channelID
+}
+
+// dispatch runs the plugin's work on a worker after the work dispatched
+// before it with the same key. The work's context is cancelled when the
+// plugin's timeout passes or when the bot closes. Work dispatched while
+// closing, or while too much work is waiting, is dropped.
+func (b *Bot) dispatch(key, plugin string, run func(context.Context)) {
+	if b.context.Err() != nil {
+		return
+	}
+
+	if !b.jobs.push(key, job{plugin: plugin, run: run}) {
+		log.WithFields(log.Fields{
+			"plugin": plugin,
+			"key":    key,
+		}).Warn("Too much work is waiting, dropping")
+	}
+}
+
+// runJob runs the job with its plugin's timeout, recovering from panics so
+// that a broken plugin doesn't take the bot down with it.
+func (b *Bot) runJob(job job) {
+	logger := log.WithField("plugin", job.plugin)
+
+	ctx, cancel := context.WithTimeout(b.context, b.Config().PluginTimeout(job.plugin))
+	defer cancel()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.WithFields(log.Fields{
+				"panic": recovered,
+				"stack": string(debug.Stack()),
+			}).Error("Plugin panicked")
+		}
+	}()
+
+	job.run(ctx)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Warn("Plugin ran past its timeout")
+	}
+}
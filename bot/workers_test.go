@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testWorkersBot(count, queued int) *Bot {
+	config := DefaultConfig()
+	config.pluginTimeouts = map[string]time.Duration{"slow": 10 * time.Millisecond}
+
+	b := &Bot{config: config}
+	b.startWorkers(count, queued)
+
+	return b
+}
+
+func TestWorkersRecoverFromPanics(t *testing.T) {
+	b := testWorkersBot(1, 10)
+	defer b.stopWorkers()
+
+	ran := make(chan bool)
+
+	b.dispatch("a", "broken", func(context.Context) {
+		panic("broken")
+	})
+
+	b.dispatch("a", "working", func(context.Context) {
+		ran <- true
+	})
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("Worker didn't survive the panic")
+	}
+}
+
+func TestWorkersTimeout(t *testing.T) {
+	b := testWorkersBot(2, 10)
+	defer b.stopWorkers()
+
+	errs := make(chan error)
+	deadlines := make(chan time.Duration)
+
+	b.dispatch("a", "slow", func(ctx context.Context) {
+		<-ctx.Done()
+		errs <- ctx.Err()
+	})
+
+	assert.Equal(t, context.DeadlineExceeded, <-errs)
+
+	b.dispatch("a", "other", func(ctx context.Context) {
+		deadline, _ := ctx.Deadline()
+		deadlines <- time.Until(deadline)
+	})
+
+	remaining := <-deadlines
+
+	assert.True(t, remaining > 20*time.Second && remaining <= 30*time.Second)
+}
+
+func TestWorkersCancelledOnStop(t *testing.T) {
+	b := testWorkersBot(1, 10)
+
+	started := make(chan bool)
+	errs := make(chan error, 1)
+
+	b.dispatch("a", "plugin", func(ctx context.Context) {
+		started <- true
+		<-ctx.Done()
+		errs <- ctx.Err()
+	})
+
+	queued := make(chan error, 1)
+
+	b.dispatch("a", "plugin", func(ctx context.Context) {
+		queued <- ctx.Err()
+	})
+
+	<-started
+	b.stopWorkers()
+
+	assert.Equal(t, context.Canceled, <-errs)
+
+	// Work that was waiting still runs, but already cancelled.
+	assert.Equal(t, context.Canceled, <-queued)
+
+	// Work dispatched after stopping is dropped rather than blocking.
+	b.dispatch("a", "plugin", func(context.Context) {
+		t.Error("Dropped work ran")
+	})
+}
+
+func TestWorkersOrderByKey(t *testing.T) {
+	b := testWorkersBot(4, 10)
+	defer b.stopWorkers()
+
+	release := make(chan bool)
+	order := make(chan string, 3)
+
+	// The first job for a holds up the second, but not the one for b.
+	b.dispatch("a", "plugin", func(context.Context) {
+		<-release
+		order <- "a1"
+	})
+
+	b.dispatch("a", "plugin", func(context.Context) {
+		order <- "a2"
+	})
+
+	b.dispatch("b", "plugin", func(context.Context) {
+		order <- "b1"
+	})
+
+	assert.Equal(t, "b1", <-order)
+
+	close(release)
+
+	assert.Equal(t, "a1", <-order)
+	assert.Equal(t, "a2", <-order)
+}
+
+func TestWorkersDropWhenFull(t *testing.T) {
+	b := testWorkersBot(1, 2)
+	defer b.stopWorkers()
+
+	release := make(chan bool)
+	ran := make(chan string, 3)
+
+	for _, name := range []string{"first", "second", "dropped"} {
+		name := name
+
+		// Dispatching never blocks, even with every worker busy.
+		b.dispatch("a", "plugin", func(context.Context) {
+			<-release
+			ran <- name
+		})
+	}
+
+	close(release)
+
+	assert.Equal(t, "first", <-ran)
+	assert.Equal(t, "second", <-ran)
+
+	select {
+	case name := <-ran:
+		t.Errorf("%s ran", name)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
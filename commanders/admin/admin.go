@@ -0,0 +1,257 @@
+package admin
+
+import (
+	"strings"
+
+	"github.com/blaenk/bmo/bot"
+)
+
+// Admin is an empty type that implements CommandSet for the owner's
+// administrative commands.
+type Admin struct{}
+
+// New creates a new Admin instance.
+func New() *Admin {
+	return &Admin{}
+}
+
+// ownerOnly wraps a handler so that only the owner may invoke it.
+func ownerOnly(handler func(*bot.Invocation)) func(*bot.Invocation) {
+	return func(invocation *bot.Invocation) {
+		if !invocation.Bot.IsOwner(invocation.Author.ID) {
+			invocation.Reply("Only the owner can do that.")
+			return
+		}
+
+		handler(invocation)
+	}
+}
+
+// Commands provides the administrative commands.
+func (a *Admin) Commands() []*bot.Command {
+	return []*bot.Command{
+		{
+			Name:        "prefix",
+			Description: "Manages the text prefixes that invoke commands",
+			Subcommands: []*bot.Command{
+				{
+					Name:        "add",
+					Description: "Adds a prefix, e.g. ! or bmo,",
+					Args:        []bot.Arg{{Name: "prefix"}},
+					Permission:  "admin.prefix",
+					Handler:     a.addPrefix,
+				},
+				{
+					Name:        "remove",
+					Description: "Removes a prefix",
+					Args:        []bot.Arg{{Name: "prefix"}},
+					Permission:  "admin.prefix",
+					Handler:     a.removePrefix,
+				},
+				{
+					Name:        "list",
+					Description: "Lists the prefixes",
+					Handler:     a.listPrefixes,
+				},
+			},
+		},
+		{
+			Name:        "grant",
+			Description: "Grants a permission to a user, a role, or everyone",
+			Args: []bot.Arg{
+				{Name: "permission", Description: "e.g. audio.skip or admin.*"},
+				{Name: "subject", Description: "A user or role mention, or everyone"},
+			},
+			Handler: ownerOnly(a.grant),
+		},
+		{
+			Name:        "revoke",
+			Description: "Revokes a permission from a user, a role, or everyone",
+			Args: []bot.Arg{
+				{Name: "permission", Description: "e.g. audio.skip or admin.*"},
+				{Name: "subject", Description: "A user or role mention, or everyone"},
+			},
+			Handler: ownerOnly(a.revoke),
+		},
+		{
+			Name:        "permissions",
+			Description: "Lists the permissions granted in this server",
+			Handler:     a.listGrants,
+		},
+		{
+			Name:        "reload",
+			Description: "Reloads the config file",
+			Handler:     ownerOnly(a.reload),
+		},
+	}
+}
+
+func (a *Admin) reload(invocation *bot.Invocation) {
+	if err := invocation.Bot.ReloadConfig(); err != nil {
+		invocation.Reply("Couldn't reload the config:\n```\n" + err.Error() + "\n```")
+		return
+	}
+
+	invocation.Reply("Reloaded the config.")
+}
+
+// grantArgs parses the grant and revoke commands' arguments.
+func grantArgs(invocation *bot.Invocation) (bot.Grant, bool) {
+	permission := invocation.String("permission")
+
+	if !bot.ValidPermission(permission) {
+		invocation.Reply("`" + permission + "` isn't a valid permission.")
+		return bot.Grant{}, false
+	}
+
+	subject, err := bot.ParseGrantSubject(invocation.String("subject"))
+
+	if err != nil {
+		invocation.Reply(err.Error())
+		return bot.Grant{}, false
+	}
+
+	if invocation.GuildID == "" {
+		invocation.Reply("Permissions can only be granted in a server.")
+		return bot.Grant{}, false
+	}
+
+	return bot.Grant{Permission: permission, Subject: subject}, true
+}
+
+func (a *Admin) grant(invocation *bot.Invocation) {
+	grant, ok := grantArgs(invocation)
+
+	if !ok {
+		return
+	}
+
+	invocation.Bot.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		for _, existing := range guild.Grants {
+			if existing == grant {
+				return
+			}
+		}
+
+		guild.Grants = append(guild.Grants, grant)
+	})
+
+	invocation.Reply("Granted `" + grant.Permission + "` to " + describeSubject(grant.Subject) + ".")
+}
+
+func (a *Admin) revoke(invocation *bot.Invocation) {
+	grant, ok := grantArgs(invocation)
+
+	if !ok {
+		return
+	}
+
+	invocation.Bot.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		kept := guild.Grants[:0]
+
+		for _, existing := range guild.Grants {
+			if existing != grant {
+				kept = append(kept, existing)
+			}
+		}
+
+		guild.Grants = kept
+	})
+
+	invocation.Reply("Revoked `" + grant.Permission + "` from " + describeSubject(grant.Subject) + ".")
+}
+
+func (a *Admin) listGrants(invocation *bot.Invocation) {
+	grants := invocation.Bot.Settings().Guild(invocation.GuildID).Grants
+
+	if len(grants) == 0 {
+		invocation.Reply("No permissions have been granted. Only the owner can use privileged commands.")
+		return
+	}
+
+	lines := make([]string, len(grants))
+
+	for i, grant := range grants {
+		lines[i] = "`" + grant.Permission + "` → " + describeSubject(grant.Subject)
+	}
+
+	invocation.Reply("Granted permissions:\n" + strings.Join(lines, "\n"))
+}
+
+// describeSubject describes a grant subject in a way that Discord renders
+// nicely.
+func describeSubject(subject string) string {
+	switch {
+	case subject == bot.EveryoneSubject:
+		return "everyone"
+
+	case strings.HasPrefix(subject, "user:"):
+		return "<@" + strings.TrimPrefix(subject, "user:") + ">"
+
+	case strings.HasPrefix(subject, "role:"):
+		return "<@&" + strings.TrimPrefix(subject, "role:") + ">"
+	}
+
+	return subject
+}
+
+func (a *Admin) addPrefix(invocation *bot.Invocation) {
+	prefix := invocation.String("prefix")
+
+	if invocation.GuildID == "" {
+		invocation.Reply("Direct messages don't need a prefix.")
+		return
+	}
+
+	if strings.TrimSpace(prefix) == "" {
+		invocation.Reply("The prefix can't be empty.")
+		return
+	}
+
+	invocation.Bot.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		for _, existing := range guild.Prefixes {
+			if strings.EqualFold(existing, prefix) {
+				return
+			}
+		}
+
+		guild.Prefixes = append(guild.Prefixes, prefix)
+	})
+
+	invocation.Reply("Added the prefix `" + prefix + "`.")
+}
+
+func (a *Admin) removePrefix(invocation *bot.Invocation) {
+	prefix := invocation.String("prefix")
+
+	invocation.Bot.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		kept := guild.Prefixes[:0]
+
+		for _, existing := range guild.Prefixes {
+			if !strings.EqualFold(existing, prefix) {
+				kept = append(kept, existing)
+			}
+		}
+
+		guild.Prefixes = kept
+	})
+
+	invocation.Reply("Removed the prefix `" + prefix + "`.")
+}
+
+func (a *Admin) listPrefixes(invocation *bot.Invocation) {
+	prefixes := invocation.Bot.Settings().Guild(invocation.GuildID).Prefixes
+
+	if len(prefixes) == 0 {
+		invocation.Reply("There are no prefixes. Mention me to issue commands.")
+		return
+	}
+
+	quoted := make([]string, len(prefixes))
+
+	for i, prefix := range prefixes {
+		quoted[i] = "`" + prefix + "`"
+	}
+
+	invocation.Reply("Prefixes: " + strings.Join(quoted, ", "))
+}
@@ -1,88 +1,281 @@
 package audio
 
 import (
-	"strings"
+	"sync"
+	"time"
 
-	"github.com/blaenk/bmo/bot"
 	"github.com/bwmarrin/discordgo"
+
+	"github.com/blaenk/bmo/bot"
 )
 
-// Audio is an empty type that implements Commander.
-type Audio struct{}
+// queueCooldown keeps users from flooding the queue, since resolving and
+// converting tracks is slow.
+var queueCooldown = bot.Cooldown{User: 5 * time.Second}
+
+// Audio implements CommandSet for the audio player commands.
+type Audio struct {
+	lock  sync.Mutex
+	votes map[string]*voteTally
+}
 
 // New creates a new Audio instance.
 func New() *Audio {
-	return &Audio{}
+	return &Audio{
+		votes: map[string]*voteTally{},
+	}
 }
 
-// Command responds to the audio command.
-func (a *Audio) Command(b *bot.Bot, msg *discordgo.Message) {
-	b.EmbedLog().Infof("Message: %+v\n", msg)
+// Commands provides the audio player commands.
+func (a *Audio) Commands() []*bot.Command {
+	return []*bot.Command{
+		{
+			Name:        "play",
+			Description: "Queues the audio at a URL in your voice channel",
+			Permission:  "audio.play",
+			Args:        []bot.Arg{{Name: "url", Description: "The URL to play"}},
+			Cooldown:    queueCooldown,
+			Handler:     a.play,
+		},
+		{
+			Name:        "pause",
+			Description: "Pauses the player",
+			Permission:  "audio.pause",
+			Immediate:   true,
+			Handler:     a.pause,
+		},
+		{
+			Name:        "resume",
+			Description: "Resumes the player",
+			Permission:  "audio.pause",
+			Immediate:   true,
+			Handler:     a.resume,
+		},
+		{
+			Name:        "skip",
+			Description: "Skips the current track",
+			Permission:  "audio.skip",
+			Immediate:   true,
+			Handler:     a.skip,
+		},
+		{
+			Name:        "voteskip",
+			Description: "Votes to skip the current track",
+			Immediate:   true,
+			Handler:     a.voteSkip,
+		},
+		{
+			Name:        "skipthreshold",
+			Description: "Sets the percentage of listeners that must vote to skip",
+			Permission:  "admin.audio",
+			Args:        []bot.Arg{{Name: "percent", Kind: bot.ArgInt}},
+			Handler:     a.setSkipThreshold,
+		},
+		{
+			Name:        "queue",
+			Description: "Shows the queued tracks in the order they'll play",
+			Handler:     a.showQueue,
+		},
+		{
+			Name:        "queuemode",
+			Description: "Plays tracks in the order they're queued, or fairly by requester",
+			Permission:  "admin.audio",
+			Args:        []bot.Arg{{Name: "mode", Description: "fifo or fair"}},
+			Handler:     a.setQueueMode,
+		},
+		{
+			Name:        "history",
+			Description: "Lists the most recently played tracks",
+			Args:        []bot.Arg{{Name: "n", Kind: bot.ArgInt, Description: "How many to list", Optional: true}},
+			Handler:     a.history,
+		},
+		{
+			Name:        "replay",
+			Description: "Queues a track from the history again",
+			Permission:  "audio.play",
+			Args:        []bot.Arg{{Name: "n", Kind: bot.ArgInt, Description: "The track's number in the history"}},
+			Cooldown:    queueCooldown,
+			Handler:     a.replay,
+		},
+		{
+			Name:        "top",
+			Description: "Lists the most played tracks",
+			Handler:     a.top,
+		},
+		{
+			Name:        "autoplay",
+			Description: "Plays more tracks when the queue runs dry",
+			Permission:  "audio.autoplay",
+			Subcommands: []*bot.Command{
+				{
+					Name:        "on",
+					Description: "Turns autoplay on",
+					Args: []bot.Arg{{
+						Name:        "source",
+						Description: "history, library or related",
+						Optional:    true,
+					}},
+					Handler: a.autoplayOn,
+				},
+				{
+					Name:        "off",
+					Description: "Turns autoplay off",
+					Handler:     a.autoplayOff,
+				},
+			},
+		},
+		{
+			Name:        "radio",
+			Description: "Plays saved live radio streams",
+			Subcommands: []*bot.Command{
+				{
+					Name:        "add",
+					Description: "Saves a radio station",
+					Permission:  "audio.radio",
+					Args: []bot.Arg{
+						{Name: "name", Description: "The station's name"},
+						{Name: "url", Description: "The stream's URL"},
+					},
+					Handler: a.radioAdd,
+				},
+				{
+					Name:        "remove",
+					Description: "Removes a saved radio station",
+					Permission:  "audio.radio",
+					Args:        []bot.Arg{{Name: "name", Description: "The station's name"}},
+					Handler:     a.radioRemove,
+				},
+				{
+					Name:        "list",
+					Description: "Lists the saved radio stations",
+					Handler:     a.radioList,
+				},
+				{
+					Name:        "play",
+					Description: "Plays a saved radio station in your voice channel",
+					Permission:  "audio.play",
+					Args:        []bot.Arg{{Name: "name", Description: "The station's name"}},
+					Handler:     a.radioPlay,
+				},
+			},
+		},
+		{
+			Name:        "clear",
+			Description: "Clears the queue",
+			Permission:  "audio.clear",
+			Immediate:   true,
+			Handler:     a.clear,
+		},
+		{
+			Name:        "join",
+			Description: "Joins your voice channel",
+			Permission:  "audio.join",
+			Handler:     a.join,
+		},
+		{
+			Name:        "leave",
+			Description: "Leaves the voice channel",
+			Permission:  "audio.join",
+			Handler:     func(invocation *bot.Invocation) { invocation.Bot.Audio().Leave(invocation.GuildID) },
+		},
+	}
+}
 
-	if b.MessageCommandsBot(msg) {
-		invoker := msg.Author.ID
-		command := b.MessageCommand(msg)
+func (a *Audio) join(invocation *bot.Invocation) {
+	if _, err := invocation.Bot.JoinUserVoiceChannel(invocation.GuildID, invocation.Author.ID); err != nil {
+		invocation.Reply("Couldn't join your voice channel!")
+	}
+}
 
-		if strings.HasPrefix(command, "pause") {
-			b.Audio().Pause()
-		}
+// pause pauses the playback in the invoker's guild.
+func (a *Audio) pause(invocation *bot.Invocation) {
+	invocation.Bot.Audio().PauseGuild(invocation.GuildID)
+	invocation.Reply("Paused.")
+}
 
-		if strings.HasPrefix(command, "resume") {
-			b.Audio().Resume()
-		}
+func (a *Audio) resume(invocation *bot.Invocation) {
+	invocation.Bot.Audio().ResumeGuild(invocation.GuildID)
+	invocation.Reply("Resumed.")
+}
 
-		if strings.HasPrefix(command, "skip") {
-			b.Audio().Skip()
-		}
+func (a *Audio) clear(invocation *bot.Invocation) {
+	invocation.Bot.Audio().ClearGuild(invocation.GuildID)
+	invocation.Reply("Cleared the queue.")
+}
 
-		if strings.HasPrefix(command, "clear") {
-			b.Audio().Clear()
-		}
+// skip skips the track playing in the invoker's guild.
+func (a *Audio) skip(invocation *bot.Invocation) {
+	audio := invocation.Bot.Audio()
 
-		// TODO
-		// Support 'from' and 'to'
-		// On a ticker interval, update file read Seek(0) progress to edit ASCII
-		// progress bar of position in file
-		// https://stackoverflow.com/questions/10901351/fgetpos-available-in-go-want-to-find-file-position
-		if strings.HasPrefix(command, "play ") {
-			target := command[5:]
+	if !audio.SkipEvent(audio.NowPlaying(invocation.GuildID)) {
+		invocation.Reply("Nothing is playing.")
+	}
+}
 
-			if target == "" {
-				_, _ = b.ReplyToMessage(msg, "You didn't provide a URL!")
-				return
-			}
+// TODO
+// Support 'from' and 'to'
+// On a ticker interval, update file read Seek(0) progress to edit ASCII
+// progress bar of position in file
+// https://stackoverflow.com/questions/10901351/fgetpos-available-in-go-want-to-find-file-position
+func (a *Audio) play(invocation *bot.Invocation) {
+	b := invocation.Bot
+	target := invocation.String("url")
 
-			channel, _ := b.Session().Channel(msg.ChannelID)
+	voiceState, err := b.UserVoiceState(invocation.GuildID, invocation.Author.ID)
 
-			voiceState, err := b.UserVoiceState(channel.GuildID, invoker)
+	if err != nil {
+		invocation.Reply("You're not in a voice channel!")
+		return
+	}
 
-			if err != nil {
-				_, _ = b.ReplyToMessage(msg, "You're not in a voice channel!")
-				return
-			}
+	a.resolve(invocation, voiceState, target)
+}
 
-			// Get metadata and notify channel
-			meta, err := bot.GetAudioMetadata(target)
+// resolve resolves and converts the audio at the URL, then queues it in the
+// voice channel.
+func (a *Audio) resolve(invocation *bot.Invocation, voiceState *discordgo.VoiceState, target string) {
+	b := invocation.Bot
 
-			if err != nil {
-				_, _ = b.ReplyToMessage(msg, "Couldn't resolve an audio URL :(")
-				return
-			}
+	// Resolving and converting the audio can take a while.
+	invocation.Defer()
 
-			_, _ = b.Session().ChannelMessageSend(msg.ChannelID, "Queuing **"+meta.Title+"**")
+	// Get metadata and notify channel
+	meta, err := bot.GetAudioMetadata(invocation.Context, target)
 
-			// FIXME
-			// This blocks; do it in a separate goroutine?
-			// TODO
-			// Would be nice to be able to register OnProgress handlers for the ffmpeg
-			// process and/or download progress
-			convertedAudio, err := b.Audio().GetOrConvertFile(meta.AudioURL, meta.Origin)
+	if err != nil {
+		invocation.Reply("Couldn't resolve an audio URL :(")
+		return
+	}
 
-			if err != nil {
-				return
-			}
+	invocation.Reply("Queuing **" + meta.Title + "**")
 
-			b.Audio().EnqueueAudioFile(voiceState.GuildID, voiceState.ChannelID, convertedAudio)
-		}
+	// Live streams never finish converting, so they're played as they arrive.
+	if bot.IsLiveStream(invocation.Context, meta) {
+		b.Audio().EnqueueLiveStream(voiceState.GuildID, voiceState.ChannelID, bot.Track{
+			Origin:      meta.Origin,
+			Title:       meta.Title,
+			RequesterID: invocation.Author.ID,
+		}, meta.AudioURL)
+
+		return
 	}
+
+	// FIXME
+	// This blocks; do it in a separate goroutine?
+	// TODO
+	// Would be nice to be able to register OnProgress handlers for the ffmpeg
+	// process and/or download progress
+	convertedAudio, err := b.Audio().GetOrConvertFile(invocation.Context, meta.AudioURL, meta.Origin)
+
+	if err != nil {
+		invocation.Reply("Couldn't convert **" + meta.Title + "** :(")
+		return
+	}
+
+	b.Audio().EnqueueTrack(voiceState.GuildID, voiceState.ChannelID, bot.Track{
+		Origin:      meta.Origin,
+		CacheKey:    meta.Origin,
+		Title:       meta.Title,
+		RequesterID: invocation.Author.ID,
+	}, convertedAudio)
 }
@@ -1,13 +1,16 @@
 package audio
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/blaenk/bmo/bot"
 )
 
 func TestAudioMetadata(t *testing.T) {
-	meta, err := GetAudioMetadata("https://soundcloud.com/dofordadubstep/vaporwave")
+	meta, err := bot.GetAudioMetadata(context.Background(), "https://soundcloud.com/dofordadubstep/vaporwave")
 
 	assert.Nil(t, err)
 
@@ -21,7 +24,7 @@ func TestAudioMetadata(t *testing.T) {
 }
 
 func TestIncorrectAudioOrigin(t *testing.T) {
-	_, err := GetAudioMetadata("https://www.youtube.com")
+	_, err := bot.GetAudioMetadata(context.Background(), "https://www.youtube.com")
 
 	assert.NotNil(t, err)
 }
@@ -0,0 +1,34 @@
+package audio
+
+import (
+	"github.com/blaenk/bmo/bot"
+)
+
+func (a *Audio) autoplayOn(invocation *bot.Invocation) {
+	source := bot.AutoplayHistory
+
+	if invocation.Has("source") {
+		parsed, err := bot.ParseAutoplaySource(invocation.String("source"))
+
+		if err != nil {
+			invocation.Reply("Autoplay can pick from history, library or related.")
+			return
+		}
+
+		source = parsed
+	}
+
+	invocation.Bot.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		guild.Autoplay = source
+	})
+
+	invocation.Reply("Autoplay will pick from **" + string(source) + "** when the queue runs dry.")
+}
+
+func (a *Audio) autoplayOff(invocation *bot.Invocation) {
+	invocation.Bot.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		guild.Autoplay = bot.AutoplayOff
+	})
+
+	invocation.Reply("Autoplay is off.")
+}
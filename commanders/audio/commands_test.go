@@ -0,0 +1,438 @@
+package audio
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blaenk/bmo/bot"
+	"github.com/blaenk/bmo/bot/bottest"
+)
+
+const timeout = 5 * time.Second
+
+// fixture is a bot with the audio commands, connected to a fake session with
+// a guild where a listener is in the voice channel and a stranger isn't, and
+// another guild where a neighbour is in the voice channel.
+type fixture struct {
+	t        *testing.T
+	bot      *bot.Bot
+	session  *bottest.Session
+	finished chan *bot.TrackFinishedEvent
+}
+
+func newFixture(t *testing.T, configure ...func(*bot.Config)) *fixture {
+	dir, err := ioutil.TempDir("", "bmo-audio")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	config := bot.DefaultConfig()
+	config.Discord.Owner = "owner"
+	config.Paths.Opus = dir
+	config.Paths.Library = dir
+
+	for _, configure := range configure {
+		configure(config)
+	}
+
+	b, err := bot.New(config, bot.NewMemoryStore())
+	require.NoError(t, err)
+
+	b.RegisterCommands("audio", New())
+
+	session := bottest.NewSession(&discordgo.User{ID: "bmo", Username: "bmo"})
+	session.AddUser(&discordgo.User{ID: "owner", Username: "owner"})
+
+	session.AddGuild(&discordgo.Guild{
+		ID: "guild",
+		Channels: []*discordgo.Channel{
+			{ID: "text", Type: discordgo.ChannelTypeGuildText},
+			{ID: "voice", Type: discordgo.ChannelTypeGuildVoice},
+		},
+		Members: []*discordgo.Member{
+			{User: &discordgo.User{ID: "listener", Username: "listener"}},
+			{User: &discordgo.User{ID: "stranger", Username: "stranger"}},
+		},
+		VoiceStates: []*discordgo.VoiceState{
+			{GuildID: "guild", ChannelID: "voice", UserID: "listener"},
+		},
+	})
+
+	session.AddGuild(&discordgo.Guild{
+		ID: "other",
+		Channels: []*discordgo.Channel{
+			{ID: "other-text", Type: discordgo.ChannelTypeGuildText},
+			{ID: "other-voice", Type: discordgo.ChannelTypeGuildVoice},
+		},
+		Members: []*discordgo.Member{
+			{User: &discordgo.User{ID: "neighbour", Username: "neighbour"}},
+		},
+		VoiceStates: []*discordgo.VoiceState{
+			{GuildID: "other", ChannelID: "other-voice", UserID: "neighbour"},
+		},
+	})
+
+	require.NoError(t, b.OpenSession(session))
+
+	t.Cleanup(func() { b.Close() })
+
+	session.Ready()
+
+	// Everyone may use the audio commands, but only the owner may administer
+	// them.
+	for _, guildID := range []string{"guild", "other"} {
+		b.Settings().UpdateGuild(guildID, func(guild *bot.GuildSettings) {
+			guild.Grants = []bot.Grant{{Subject: bot.EveryoneSubject, Permission: "audio.*"}}
+		})
+	}
+
+	finished := make(chan *bot.TrackFinishedEvent, 10)
+
+	b.Bus().Subscribe(func(event *bot.TrackFinishedEvent) {
+		finished <- event
+	})
+
+	return &fixture{t: t, bot: b, session: session, finished: finished}
+}
+
+// command sends the command from the user and returns the bot's reply.
+func (f *fixture) command(userID, command string) string {
+	return f.commandIn("text", userID, command)
+}
+
+// commandIn sends the command from the user in the channel and returns the
+// bot's reply.
+func (f *fixture) commandIn(channelID, userID, command string) string {
+	f.session.Message(channelID, userID, "<@bmo> "+command)
+
+	reply, err := f.session.NextMessage(timeout)
+	require.NoError(f.t, err)
+
+	assert.Equal(f.t, channelID, reply.ChannelID)
+
+	return reply.Content
+}
+
+// enqueue queues the cached track in the guild's voice channel.
+func (f *fixture) enqueue(guildID, voiceChannelID string, track bot.Track) {
+	file, err := f.bot.Audio().OpenCached(track.CacheKey)
+	require.NoError(f.t, err)
+
+	f.bot.Audio().EnqueueTrack(guildID, voiceChannelID, track, file)
+}
+
+// cache puts a track with the given number of Opus frames in the Opus cache.
+func (f *fixture) cache(key string, frames int) {
+	name := path.Join(f.bot.Config().Paths.Opus, fmt.Sprintf("%x", sha1.Sum([]byte(key))))
+
+	require.NoError(f.t, ioutil.WriteFile(name, make([]byte, frames*320), 0644))
+}
+
+// play queues the cached track for the listener and waits for it to finish.
+func (f *fixture) play(track bot.Track) {
+	f.enqueue("guild", "voice", track)
+
+	f.waitFinished(track.Title)
+}
+
+func (f *fixture) waitFinished(title string) {
+	select {
+	case event := <-f.finished:
+		assert.Equal(f.t, title, event.Event.Title())
+
+	case <-time.After(timeout):
+		f.t.Fatal("Track didn't finish playing")
+	}
+}
+
+func TestHistoryAndReplay(t *testing.T) {
+	f := newFixture(t)
+
+	assert.Equal(t, "<@listener>: Nothing has been played yet.", f.command("listener", "history"))
+
+	track := bot.Track{
+		Origin:      "https://example.com/song",
+		CacheKey:    "https://example.com/song",
+		Title:       "Song",
+		RequesterID: "listener",
+	}
+
+	f.cache(track.CacheKey, 50)
+	f.play(track)
+
+	voice := f.session.Voice("guild")
+	require.NotNil(t, voice)
+
+	assert.Equal(t, "voice", voice.ChannelID())
+	assert.Equal(t, 50, voice.Frames())
+
+	assert.Contains(t, f.command("listener", "history"), "1. **Song** for <@listener>")
+
+	assert.Equal(t, "<@listener>: Queuing **Song**", f.command("listener", "replay 1"))
+
+	f.waitFinished("Song")
+
+	assert.Equal(t, 100, voice.Frames())
+
+	assert.Equal(t, "<@listener>: 1. **Song** played 2 times", f.command("listener", "top"))
+}
+
+func TestHistoryTimezone(t *testing.T) {
+	f := newFixture(t, func(config *bot.Config) {
+		config.Guilds["guild"] = bot.GuildConfig{Timezone: "Asia/Kathmandu"}
+	})
+
+	location, err := time.LoadLocation("Asia/Kathmandu")
+	require.NoError(t, err)
+
+	track := bot.Track{Origin: "song", CacheKey: "song", Title: "Song", RequesterID: "listener"}
+
+	f.cache(track.CacheKey, 5)
+
+	before := time.Now().In(location).Format("Jan 2 15:04")
+	f.play(track)
+	after := time.Now().In(location).Format("Jan 2 15:04")
+
+	history := f.command("listener", "history")
+
+	if !strings.HasSuffix(history, before) {
+		assert.True(t, strings.HasSuffix(history, after), history)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	f := newFixture(t)
+
+	assert.Equal(t, "<@listener>: The queue is empty.", f.command("listener", "queue"))
+
+	f.bot.Audio().Pause()
+
+	for _, title := range []string{"First", "Second"} {
+		key := "https://example.com/" + title
+		f.cache(key, 1)
+
+		file, err := f.bot.Audio().OpenCached(key)
+		require.NoError(t, err)
+
+		f.bot.Audio().EnqueueTrack("guild", "voice", bot.Track{Origin: key, CacheKey: key, Title: title, RequesterID: "listener"}, file)
+	}
+
+	queue := f.command("listener", "queue")
+
+	assert.Contains(t, queue, "**First** for <@listener>")
+	assert.Contains(t, queue, "**Second** for <@listener>")
+
+	f.bot.Audio().Resume()
+
+	f.waitFinished("First")
+	f.waitFinished("Second")
+
+	assert.Equal(t, "<@listener>: The queue is empty.", f.command("listener", "queue"))
+}
+
+func TestControlsStayInGuild(t *testing.T) {
+	f := newFixture(t)
+
+	assert.Equal(t, "<@neighbour>: Paused.", f.commandIn("other-text", "neighbour", "pause"))
+
+	theirs := bot.Track{Origin: "theirs", CacheKey: "theirs", Title: "Theirs", RequesterID: "neighbour"}
+	ours := bot.Track{Origin: "ours", CacheKey: "ours", Title: "Ours", RequesterID: "listener"}
+
+	f.cache(theirs.CacheKey, 5)
+	f.cache(ours.CacheKey, 5)
+
+	f.enqueue("other", "other-voice", theirs)
+	f.enqueue("guild", "voice", ours)
+
+	// The other guild being paused doesn't hold up this one.
+	f.waitFinished("Ours")
+
+	assert.Len(t, f.bot.Audio().Queued("other"), 1)
+
+	assert.Equal(t, "<@listener>: Nothing is playing.", f.command("listener", "skip"))
+
+	assert.Equal(t, "<@listener>: Cleared the queue.", f.command("listener", "clear"))
+	assert.Len(t, f.bot.Audio().Queued("other"), 1)
+
+	assert.Equal(t, "<@listener>: Resumed.", f.command("listener", "resume"))
+	assert.Len(t, f.bot.Audio().Queued("other"), 1)
+
+	assert.Equal(t, "<@neighbour>: Resumed.", f.commandIn("other-text", "neighbour", "resume"))
+
+	f.waitFinished("Theirs")
+}
+
+func TestQueueMode(t *testing.T) {
+	f := newFixture(t)
+
+	assert.Equal(t, "<@owner>: Requesters will now take turns.", f.command("owner", "queuemode fair"))
+	assert.True(t, f.bot.Settings().Guild("guild").FairQueue)
+
+	assert.Equal(t, "<@owner>: The queue mode must be fifo or fair.", f.command("owner", "queuemode random"))
+	assert.True(t, f.bot.Settings().Guild("guild").FairQueue)
+}
+
+func TestAdminPermission(t *testing.T) {
+	f := newFixture(t)
+
+	assert.Equal(t,
+		"<@listener>: Sorry, you need the `admin.audio` permission to do that.",
+		f.command("listener", "queuemode fair"))
+
+	assert.False(t, f.bot.Settings().Guild("guild").FairQueue)
+}
+
+func TestJoin(t *testing.T) {
+	f := newFixture(t)
+
+	f.session.Message("text", "listener", "<@bmo> join")
+
+	deadline := time.Now().Add(timeout)
+
+	for f.session.Voice("guild") == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	voice := f.session.Voice("guild")
+	require.NotNil(t, voice)
+
+	assert.Equal(t, "voice", voice.ChannelID())
+
+	assert.Equal(t, "<@stranger>: Couldn't join your voice channel!", f.command("stranger", "join"))
+}
+
+func TestEditedCommand(t *testing.T) {
+	f := newFixture(t)
+
+	executed := make(chan *bot.CommandExecutedEvent, 10)
+
+	f.bot.Bus().Subscribe(func(event *bot.CommandExecutedEvent) {
+		executed <- event
+	})
+
+	msg := f.session.Message("text", "owner", "<@bmo> queuemode fare")
+
+	assert.Equal(t, "queuemode", (<-executed).Invocation.Path())
+
+	usage, err := f.session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	assert.Equal(t, "<@owner>: The queue mode must be fifo or fair.", usage.Content)
+
+	f.session.Edit(msg, "<@bmo> queuemode fair")
+
+	<-executed
+
+	reply, err := f.session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	assert.Equal(t, "<@owner>: Requesters will now take turns.", reply.Content)
+
+	// Edits that don't change the command don't run it again.
+	f.session.Edit(msg, "<@bmo>   queuemode fair ")
+
+	_, err = f.session.NextMessage(100 * time.Millisecond)
+	assert.Error(t, err)
+
+	// Deleting the command deletes the replies to it.
+	f.session.Delete(msg)
+
+	assert.Equal(t, []string{usage.ID, reply.ID}, f.session.Deleted())
+}
+
+func TestReplayCooldown(t *testing.T) {
+	f := newFixture(t)
+
+	track := bot.Track{
+		Origin:      "https://example.com/song",
+		CacheKey:    "https://example.com/song",
+		Title:       "Song",
+		RequesterID: "listener",
+	}
+
+	f.cache(track.CacheKey, 1)
+	f.play(track)
+
+	assert.Equal(t, "<@listener>: Queuing **Song**", f.command("listener", "replay 1"))
+	assert.Equal(t, "<@listener>: Slow down! Try `replay` again in 5s.", f.command("listener", "replay 1"))
+
+	// The user is only told once.
+	f.session.Message("text", "listener", "<@bmo> replay 1")
+
+	_, err := f.session.NextMessage(100 * time.Millisecond)
+	assert.Error(t, err)
+
+	// The owner has no cooldowns, so the command runs, though the owner isn't
+	// in the voice channel.
+	assert.Equal(t, "<@owner>: You're not in a voice channel!", f.command("owner", "replay 1"))
+	assert.Equal(t, "<@owner>: You're not in a voice channel!", f.command("owner", "replay 1"))
+}
+
+func TestPrefixesAndDirectMessages(t *testing.T) {
+	f := newFixture(t)
+
+	f.bot.Settings().UpdateGuild("guild", func(guild *bot.GuildSettings) {
+		guild.Prefixes = []string{"bmo, ", "Ⱥ"}
+	})
+
+	f.session.Message("text", "listener", "BMO, help")
+
+	reply, err := f.session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	assert.Contains(t, reply.Content, "Commands:")
+
+	// Prefixes are easily used by accident, so only what looks like a mistyped
+	// command is answered.
+	f.session.Message("text", "listener", "bmo, how are you?")
+
+	_, err = f.session.NextMessage(100 * time.Millisecond)
+	assert.Error(t, err)
+
+	f.session.Message("text", "listener", "bmo, hepl")
+
+	reply, err = f.session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	assert.Equal(t, "<@listener>: I don't know the command `hepl`. Did you mean `help`?", reply.Content)
+
+	// Case-folding changes the length of these, so they don't match.
+	f.session.Message("text", "listener", "ⱥhelp")
+	f.session.Message("text", "listener", "ẞhelp")
+
+	_, err = f.session.NextMessage(100 * time.Millisecond)
+	assert.Error(t, err)
+
+	f.session.AddChannel(&discordgo.Channel{ID: "dm", Type: discordgo.ChannelTypeDM})
+
+	// Ordinary direct messages aren't answered, but commands are.
+	f.session.Message("dm", "listener", "hello there")
+
+	_, err = f.session.NextMessage(100 * time.Millisecond)
+	assert.Error(t, err)
+
+	f.session.Message("dm", "listener", "help")
+
+	reply, err = f.session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	assert.Equal(t, "dm", reply.ChannelID)
+	assert.Contains(t, reply.Content, "Commands:")
+
+	f.session.Message("dm", "listener", "<@bmo> hello there")
+
+	reply, err = f.session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	assert.Contains(t, reply.Content, "I don't know the command `hello`")
+}
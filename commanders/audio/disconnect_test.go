@@ -0,0 +1,84 @@
+package audio
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blaenk/bmo/bot"
+)
+
+// waitConnected waits until the bot is or isn't in the guild's voice channel,
+// reporting whether it happened in time.
+func (f *fixture) waitConnected(connected bool, within time.Duration) bool {
+	deadline := time.Now().Add(within)
+
+	for time.Now().Before(deadline) {
+		if (f.session.Voice("guild") != nil) == connected {
+			return true
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return false
+}
+
+func (f *fixture) join() {
+	f.session.Message("text", "listener", "<@bmo> join")
+
+	require.True(f.t, f.waitConnected(true, timeout))
+}
+
+func idleTimeout(d time.Duration) func(*bot.Config) {
+	return func(config *bot.Config) {
+		config.Voice.IdleTimeout = bot.Duration{Duration: d}
+	}
+}
+
+func TestLeaveWhenIdle(t *testing.T) {
+	f := newFixture(t, idleTimeout(50*time.Millisecond))
+
+	f.join()
+
+	assert.True(t, f.waitConnected(false, timeout))
+}
+
+func TestPlaybackRestartsIdleTimer(t *testing.T) {
+	f := newFixture(t, idleTimeout(500*time.Millisecond))
+
+	joined := time.Now()
+
+	f.join()
+
+	time.Sleep(250*time.Millisecond - time.Since(joined))
+
+	f.cache("track", 5)
+	f.play(bot.Track{Origin: "track", CacheKey: "track", Title: "Track"})
+
+	// The timer started by joining would have run out by now, but playing
+	// restarted it.
+	time.Sleep(600*time.Millisecond - time.Since(joined))
+
+	assert.NotNil(t, f.session.Voice("guild"))
+
+	assert.True(t, f.waitConnected(false, timeout))
+}
+
+func TestLeaveWhenAlone(t *testing.T) {
+	f := newFixture(t)
+
+	f.join()
+
+	f.session.VoiceStateUpdate("guild", "stranger", "voice")
+	f.session.VoiceStateUpdate("guild", "listener", "")
+
+	// The stranger is still listening.
+	assert.False(t, f.waitConnected(false, 100*time.Millisecond))
+
+	f.session.VoiceStateUpdate("guild", "stranger", "")
+
+	assert.True(t, f.waitConnected(false, timeout))
+}
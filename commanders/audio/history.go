@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blaenk/bmo/bot"
+)
+
+const (
+	// defaultHistoryListing is how many plays the history command lists by
+	// default.
+	defaultHistoryListing = 10
+
+	// maxHistoryListing is the most plays the history and top commands list.
+	maxHistoryListing = 25
+)
+
+// describeEntry describes a played track.
+func describeEntry(entry bot.HistoryEntry) string {
+	description := "**" + entry.Title + "**"
+
+	if entry.RequesterID != "" {
+		description += fmt.Sprintf(" for <@%s>", entry.RequesterID)
+	}
+
+	return description
+}
+
+// history lists the guild's most recently played tracks, numbered for replay.
+func (a *Audio) history(invocation *bot.Invocation) {
+	n := defaultHistoryListing
+
+	if invocation.Has("n") {
+		n = invocation.Int("n")
+	}
+
+	if n < 1 || n > maxHistoryListing {
+		invocation.Reply(fmt.Sprintf("I can list from 1 to %d tracks.", maxHistoryListing))
+		return
+	}
+
+	entries := invocation.Bot.Audio().History().Recent(invocation.GuildID, n)
+
+	if len(entries) == 0 {
+		invocation.Reply("Nothing has been played yet.")
+		return
+	}
+
+	location := invocation.Bot.Config().Guild(invocation.GuildID).Location()
+
+	var lines []string
+
+	for i, entry := range entries {
+		played := entry.Time.In(location).Format("Jan 2 15:04")
+		lines = append(lines, fmt.Sprintf("%d. %s %s", i+1, describeEntry(entry), played))
+	}
+
+	invocation.Reply(strings.Join(lines, "\n"))
+}
+
+// replay queues a track from the guild's history again, straight from the
+// Opus cache if it's still there.
+func (a *Audio) replay(invocation *bot.Invocation) {
+	b := invocation.Bot
+	n := invocation.Int("n")
+
+	entries := b.Audio().History().Recent(invocation.GuildID, n)
+
+	if n < 1 || n > len(entries) {
+		invocation.Reply("There's no track with that number in the history.")
+		return
+	}
+
+	entry := entries[n-1]
+
+	voiceState, err := b.UserVoiceState(invocation.GuildID, invocation.Author.ID)
+
+	if err != nil {
+		invocation.Reply("You're not in a voice channel!")
+		return
+	}
+
+	cachedAudio, err := b.Audio().OpenCached(entry.CacheKey)
+
+	if err != nil {
+		a.resolve(invocation, voiceState, entry.Origin)
+		return
+	}
+
+	track := entry.Track()
+	track.RequesterID = invocation.Author.ID
+
+	invocation.Reply("Queuing **" + track.Title + "**")
+
+	b.Audio().EnqueueTrack(voiceState.GuildID, voiceState.ChannelID, track, cachedAudio)
+}
+
+// top lists the guild's most played tracks.
+func (a *Audio) top(invocation *bot.Invocation) {
+	plays := invocation.Bot.Audio().History().Top(invocation.GuildID, defaultHistoryListing)
+
+	if len(plays) == 0 {
+		invocation.Reply("Nothing has been played yet.")
+		return
+	}
+
+	var lines []string
+
+	for i, track := range plays {
+		times := "times"
+
+		if track.Plays == 1 {
+			times = "time"
+		}
+
+		lines = append(lines, fmt.Sprintf("%d. **%s** played %d %s", i+1, track.Entry.Title, track.Plays, times))
+	}
+
+	invocation.Reply(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,84 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blaenk/bmo/bot"
+)
+
+// maxQueueListing is the most queued tracks the queue command lists.
+const maxQueueListing = 10
+
+// describeEvent describes a queued or playing track.
+func describeEvent(event *bot.AudioEvent) string {
+	title := event.Title()
+
+	if title == "" {
+		title = "Speech"
+	}
+
+	if event.RequesterID() == "" {
+		return "**" + title + "**"
+	}
+
+	return fmt.Sprintf("**%s** for <@%s>", title, event.RequesterID())
+}
+
+// showQueue lists the playing track and the queued tracks in the order they'll
+// be played.
+func (a *Audio) showQueue(invocation *bot.Invocation) {
+	audio := invocation.Bot.Audio()
+
+	var lines []string
+
+	if playing := audio.NowPlaying(invocation.GuildID); playing != nil {
+		lines = append(lines, "Playing "+describeEvent(playing))
+	}
+
+	queued := audio.Queued(invocation.GuildID)
+
+	for i, event := range queued {
+		if i == maxQueueListing {
+			lines = append(lines, fmt.Sprintf("and %d more", len(queued)-maxQueueListing))
+			break
+		}
+
+		lines = append(lines, fmt.Sprintf("%d. %s", i+1, describeEvent(event)))
+	}
+
+	if len(lines) == 0 {
+		invocation.Reply("The queue is empty.")
+		return
+	}
+
+	invocation.Reply(strings.Join(lines, "\n"))
+}
+
+// setQueueMode switches the guild between playing tracks in the order they're
+// queued and taking turns between requesters.
+func (a *Audio) setQueueMode(invocation *bot.Invocation) {
+	var fair bool
+
+	switch invocation.String("mode") {
+	case "fair":
+		fair = true
+
+	case "fifo":
+		fair = false
+
+	default:
+		invocation.Reply("The queue mode must be fifo or fair.")
+		return
+	}
+
+	invocation.Bot.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		guild.FairQueue = fair
+	})
+
+	if fair {
+		invocation.Reply("Requesters will now take turns.")
+	} else {
+		invocation.Reply("Tracks will now play in the order they're queued.")
+	}
+}
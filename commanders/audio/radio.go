@@ -0,0 +1,96 @@
+package audio
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/blaenk/bmo/bot"
+)
+
+func (a *Audio) radioAdd(invocation *bot.Invocation) {
+	name := invocation.String("name")
+	streamURL := invocation.String("url")
+
+	if parsed, err := url.Parse(streamURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		invocation.Reply("That's not a stream URL.")
+		return
+	}
+
+	invocation.Bot.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		for i, station := range guild.RadioStations {
+			if strings.EqualFold(station.Name, name) {
+				guild.RadioStations[i].URL = streamURL
+				return
+			}
+		}
+
+		guild.RadioStations = append(guild.RadioStations, bot.RadioStation{Name: name, URL: streamURL})
+	})
+
+	invocation.Reply("Saved the radio station **" + name + "**.")
+}
+
+func (a *Audio) radioRemove(invocation *bot.Invocation) {
+	name := invocation.String("name")
+	removed := false
+
+	invocation.Bot.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		for i, station := range guild.RadioStations {
+			if strings.EqualFold(station.Name, name) {
+				guild.RadioStations = append(guild.RadioStations[:i], guild.RadioStations[i+1:]...)
+				removed = true
+				return
+			}
+		}
+	})
+
+	if !removed {
+		invocation.Reply("There's no radio station called **" + name + "**.")
+		return
+	}
+
+	invocation.Reply("Removed the radio station **" + name + "**.")
+}
+
+func (a *Audio) radioList(invocation *bot.Invocation) {
+	stations := invocation.Bot.Settings().Guild(invocation.GuildID).RadioStations
+
+	if len(stations) == 0 {
+		invocation.Reply("There are no saved radio stations.")
+		return
+	}
+
+	var lines []string
+
+	for _, station := range stations {
+		lines = append(lines, "**"+station.Name+"** <"+station.URL+">")
+	}
+
+	invocation.Reply(strings.Join(lines, "\n"))
+}
+
+func (a *Audio) radioPlay(invocation *bot.Invocation) {
+	b := invocation.Bot
+
+	station, ok := b.Settings().Guild(invocation.GuildID).RadioStation(invocation.String("name"))
+
+	if !ok {
+		invocation.Reply("There's no radio station called **" + invocation.String("name") + "**.")
+		return
+	}
+
+	voiceState, err := b.UserVoiceState(invocation.GuildID, invocation.Author.ID)
+
+	if err != nil {
+		invocation.Reply("You're not in a voice channel!")
+		return
+	}
+
+	b.Audio().EnqueueLiveStream(voiceState.GuildID, voiceState.ChannelID, bot.Track{
+		Origin:      station.URL,
+		Title:       station.Name,
+		RequesterID: invocation.Author.ID,
+	}, station.URL)
+
+	invocation.Reply("Tuning in to **" + station.Name + "**")
+}
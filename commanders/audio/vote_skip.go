@@ -0,0 +1,106 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/blaenk/bmo/bot"
+)
+
+// voteTally tracks the votes to skip the track being played in a guild.
+type voteTally struct {
+	event  *bot.AudioEvent
+	voters map[string]bool
+}
+
+// votesNeeded is how many of the listeners must vote for a skip.
+func votesNeeded(listeners int, fraction float64) int {
+	needed := int(math.Ceil(float64(listeners) * fraction))
+
+	if needed < 1 {
+		return 1
+	}
+
+	return needed
+}
+
+// voteSkip records the invoker's vote to skip the current track, skipping it
+// once enough of the listeners in the bot's voice channel have voted.
+func (a *Audio) voteSkip(invocation *bot.Invocation) {
+	b := invocation.Bot
+	audio := b.Audio()
+
+	event := audio.NowPlaying(invocation.GuildID)
+
+	if event == nil {
+		invocation.Reply("Nothing is playing.")
+		return
+	}
+
+	listeners := b.VoiceChannelUsers(invocation.GuildID, event.VoiceChannelID())
+	isListener := map[string]bool{}
+
+	for _, userID := range listeners {
+		isListener[userID] = true
+	}
+
+	if !isListener[invocation.Author.ID] {
+		invocation.Reply("You have to be listening to vote!")
+		return
+	}
+
+	a.lock.Lock()
+
+	tally, ok := a.votes[invocation.GuildID]
+
+	// Votes only count towards the track they were cast for.
+	if !ok || tally.event != event {
+		tally = &voteTally{event: event, voters: map[string]bool{}}
+		a.votes[invocation.GuildID] = tally
+	}
+
+	tally.voters[invocation.Author.ID] = true
+
+	// Only count the voters who are still listening.
+	votes := 0
+
+	for userID := range tally.voters {
+		if isListener[userID] {
+			votes++
+		}
+	}
+
+	needed := votesNeeded(len(listeners), b.Settings().Guild(invocation.GuildID).VoteSkipThreshold())
+	passed := votes >= needed
+
+	if passed {
+		delete(a.votes, invocation.GuildID)
+	}
+
+	a.lock.Unlock()
+
+	if !passed {
+		invocation.Reply(fmt.Sprintf("Vote to skip: **%d/%d**", votes, needed))
+		return
+	}
+
+	if audio.SkipEvent(event) {
+		invocation.Reply(fmt.Sprintf("Vote to skip: **%d/%d**. Skipping!", votes, needed))
+	}
+}
+
+// setSkipThreshold sets the percentage of listeners that must vote to skip.
+func (a *Audio) setSkipThreshold(invocation *bot.Invocation) {
+	percent := invocation.Int("percent")
+
+	if percent < 1 || percent > 100 {
+		invocation.Reply("The threshold must be a percentage from 1 to 100.")
+		return
+	}
+
+	invocation.Bot.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		guild.VoteSkipFraction = float64(percent) / 100
+	})
+
+	invocation.Reply(fmt.Sprintf("Skipping now takes the votes of %d%% of listeners.", percent))
+}
@@ -0,0 +1,15 @@
+package audio
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVotesNeeded(t *testing.T) {
+	assert.Equal(t, 1, votesNeeded(1, 0.5))
+	assert.Equal(t, 2, votesNeeded(3, 0.5))
+	assert.Equal(t, 2, votesNeeded(4, 0.5))
+	assert.Equal(t, 4, votesNeeded(4, 1))
+	assert.Equal(t, 1, votesNeeded(0, 0.5))
+}
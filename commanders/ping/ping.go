@@ -2,10 +2,9 @@ package ping
 
 import (
 	"github.com/blaenk/bmo/bot"
-	"github.com/bwmarrin/discordgo"
 )
 
-// Ping is an empty type that implements Commander.
+// Ping is an empty type that implements CommandSet.
 type Ping struct{}
 
 // New creates a new Ping instance.
@@ -13,9 +12,15 @@ func New() *Ping {
 	return &Ping{}
 }
 
-// Command responds to the ping command.
-func (p *Ping) Command(bot *bot.Bot, msg *discordgo.Message) {
-	if msg.Content == "ping" {
-		_, _ = bot.Session().ChannelMessageSend(msg.ChannelID, "Pong!")
+// Commands provides the ping command.
+func (p *Ping) Commands() []*bot.Command {
+	return []*bot.Command{
+		{
+			Name:        "ping",
+			Description: "Checks that the bot is responsive",
+			Handler: func(invocation *bot.Invocation) {
+				invocation.Reply("Pong!")
+			},
+		},
 	}
 }
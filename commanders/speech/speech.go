@@ -0,0 +1,313 @@
+package speech
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blaenk/bmo/bot"
+)
+
+// rates maps the rates accepted by the voice rate command to Ivona's rates.
+var rates = []string{"x-slow", "slow", "medium", "fast", "x-fast"}
+
+// Speech implements CommandSet for text-to-speech related commands.
+type Speech struct{}
+
+// New creates a new Speech instance.
+func New() *Speech {
+	return &Speech{}
+}
+
+// sayCooldown is the guild's say_cooldown for each user.
+var sayCooldown = bot.Cooldown{
+	PerGuild: func(config bot.GuildConfig) bot.Cooldown {
+		return bot.Cooldown{User: config.SayCooldown.Duration}
+	},
+}
+
+// Commands provides the say, voice, readaloud and announce commands.
+func (s *Speech) Commands() []*bot.Command {
+	return []*bot.Command{
+		{
+			Name:        "say",
+			Description: "Speaks the text in your voice channel",
+			Permission:  "speech.say",
+			Args:        []bot.Arg{{Name: "text", Kind: bot.ArgText}},
+			Cooldown:    sayCooldown,
+			Handler:     s.say,
+		},
+		{
+			Name:        "voice",
+			Description: "Changes the voice you speak with",
+			Subcommands: append(voiceCommands(false), &bot.Command{
+				Name:        "guild",
+				Description: "Changes the server's default voice",
+				Permission:  "admin.voice",
+				Subcommands: voiceCommands(true),
+			}),
+		},
+		{
+			Name:        "readaloud",
+			Description: "Reads the messages in a text channel aloud",
+			Permission:  "admin.readaloud",
+			Subcommands: []*bot.Command{
+				{
+					Name:        "here",
+					Description: "Reads this channel's messages aloud in your voice channel",
+					Handler:     s.readAloudHere,
+				},
+				{
+					Name:        "off",
+					Description: "Stops reading messages aloud",
+					Handler:     s.readAloudOff,
+				},
+			},
+		},
+		{
+			Name:        "announce",
+			Description: "Changes how voice channel presence is announced",
+			Subcommands: []*bot.Command{
+				{
+					Name:        "on",
+					Description: "Announces when you join or leave",
+					Handler:     func(invocation *bot.Invocation) { s.announceOptOut(invocation, false) },
+				},
+				{
+					Name:        "off",
+					Description: "Stops announcing when you join or leave",
+					Handler:     func(invocation *bot.Invocation) { s.announceOptOut(invocation, true) },
+				},
+				{
+					Name:        "language",
+					Description: "Sets the server's announcement language",
+					Permission:  "admin.announce",
+					Args:        []bot.Arg{{Name: "code"}},
+					Handler:     s.announceLanguage,
+				},
+				{
+					Name:        "template",
+					Description: "Sets an announcement template for a language, or resets it",
+					Permission:  "admin.announce",
+					Args: []bot.Arg{
+						{Name: "code"},
+						{Name: "kind", Description: "join, leave or move"},
+						{Name: "template", Kind: bot.ArgText},
+					},
+					Handler: s.announceTemplate,
+				},
+			},
+		},
+	}
+}
+
+func validVoiceName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Speech) say(invocation *bot.Invocation) {
+	b := invocation.Bot
+	author := invocation.Author
+	text := strings.TrimSpace(invocation.String("text"))
+
+	if text == "" {
+		invocation.Reply("You didn't say anything!")
+		return
+	}
+
+	config := b.Config().Guild(invocation.GuildID)
+
+	if utf8.RuneCountInString(text) > config.MaxSayLength {
+		invocation.Reply(fmt.Sprintf("That's too long! Keep it under %d characters.", config.MaxSayLength))
+		return
+	}
+
+	isSSML := strings.HasPrefix(text, "<speak")
+
+	if isSSML && !b.HasPermission(invocation.GuildID, author.ID, "speech.ssml") {
+		invocation.Reply("Sorry, you need the `speech.ssml` permission to use SSML.")
+		return
+	}
+
+	voiceState, err := b.UserVoiceState(invocation.GuildID, author.ID)
+
+	if err != nil {
+		invocation.Reply("You're not in a voice channel!")
+		return
+	}
+
+	speech := &bot.Speech{
+		Text:  text,
+		SSML:  isSSML,
+		Voice: b.Voice(invocation.GuildID, author.ID),
+	}
+
+	if err := b.SpeakWith(voiceState.GuildID, voiceState.ChannelID, speech); err != nil {
+		b.VoiceLog().WithError(err).Error("Couldn't say text")
+		invocation.Reply("Couldn't say that :(")
+	}
+}
+
+// voiceCommands creates the voice setting subcommands, which either change the
+// invoker's voice or the guild's.
+func voiceCommands(forGuild bool) []*bot.Command {
+	update := func(invocation *bot.Invocation, change func(*bot.VoiceSettings)) {
+		b := invocation.Bot
+
+		if forGuild {
+			b.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+				change(&guild.Voice)
+			})
+		} else {
+			b.Settings().UpdateUser(invocation.Author.ID, func(user *bot.UserSettings) {
+				change(&user.Voice)
+			})
+		}
+
+		invocation.Reply("Voice updated.")
+	}
+
+	return []*bot.Command{
+		{
+			Name:        "set",
+			Description: "Sets the voice, e.g. Brian",
+			Args:        []bot.Arg{{Name: "name"}},
+			Handler: func(invocation *bot.Invocation) {
+				name := invocation.String("name")
+
+				if !validVoiceName(name) {
+					invocation.Reply("That's not a voice name.")
+					return
+				}
+
+				update(invocation, func(voice *bot.VoiceSettings) { voice.Name = name })
+			},
+		},
+		{
+			Name:        "rate",
+			Description: fmt.Sprintf("Sets the speaking rate from 1 to %d", len(rates)),
+			Args:        []bot.Arg{{Name: "n", Kind: bot.ArgInt}},
+			Handler: func(invocation *bot.Invocation) {
+				n := invocation.Int("n")
+
+				if n < 1 || n > len(rates) {
+					invocation.Reply(fmt.Sprintf("The rate must be a number from 1 to %d.", len(rates)))
+					return
+				}
+
+				update(invocation, func(voice *bot.VoiceSettings) { voice.Rate = rates[n-1] })
+			},
+		},
+		{
+			Name:        "reset",
+			Description: "Resets the voice to the default",
+			Handler: func(invocation *bot.Invocation) {
+				update(invocation, func(voice *bot.VoiceSettings) { *voice = bot.VoiceSettings{} })
+			},
+		},
+	}
+}
+
+// readAloudHere binds the current text channel to the invoker's voice channel.
+func (s *Speech) readAloudHere(invocation *bot.Invocation) {
+	b := invocation.Bot
+
+	voiceState, err := b.UserVoiceState(invocation.GuildID, invocation.Author.ID)
+
+	if err != nil {
+		invocation.Reply("You're not in a voice channel!")
+		return
+	}
+
+	b.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		guild.ReadAloud = bot.ReadAloudSettings{
+			TextChannelID:  invocation.ChannelID,
+			VoiceChannelID: voiceState.ChannelID,
+		}
+	})
+
+	invocation.Reply("Messages in this channel will be read aloud.")
+}
+
+func (s *Speech) readAloudOff(invocation *bot.Invocation) {
+	b := invocation.Bot
+
+	b.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		guild.ReadAloud = bot.ReadAloudSettings{}
+	})
+
+	invocation.Reply("Read-aloud mode is off.")
+}
+
+var presenceKinds = map[string]bot.PresenceKind{
+	"join":  bot.PresenceJoin,
+	"leave": bot.PresenceLeave,
+	"move":  bot.PresenceMove,
+}
+
+func (s *Speech) announceOptOut(invocation *bot.Invocation, optOut bool) {
+	invocation.Bot.Settings().UpdateUser(invocation.Author.ID, func(user *bot.UserSettings) {
+		user.AnnounceOptOut = optOut
+	})
+
+	if optOut {
+		invocation.Reply("You won't be announced anymore.")
+	} else {
+		invocation.Reply("You'll be announced again.")
+	}
+}
+
+func (s *Speech) announceLanguage(invocation *bot.Invocation) {
+	b := invocation.Bot
+
+	language := invocation.String("code")
+
+	b.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		guild.Language = language
+	})
+
+	invocation.Reply("Announcement language set to **" + language + "**.")
+}
+
+// announceTemplate sets the guild's template for a kind of presence change in
+// a language. A template of "reset" reverts to the built-in template.
+func (s *Speech) announceTemplate(invocation *bot.Invocation) {
+	b := invocation.Bot
+
+	kind, ok := presenceKinds[invocation.String("kind")]
+
+	if !ok {
+		invocation.Reply("The kind of announcement must be join, leave or move.")
+		return
+	}
+
+	language := invocation.String("code")
+	template := invocation.String("template")
+
+	if template == "reset" {
+		template = ""
+	}
+
+	b.Settings().UpdateGuild(invocation.GuildID, func(guild *bot.GuildSettings) {
+		if guild.PresenceTemplates == nil {
+			guild.PresenceTemplates = map[string]bot.PresenceTemplates{}
+		}
+
+		templates := guild.PresenceTemplates[language]
+		templates.SetTemplate(kind, template)
+		guild.PresenceTemplates[language] = templates
+	})
+
+	invocation.Reply("Announcement template updated.")
+}
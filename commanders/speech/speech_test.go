@@ -0,0 +1,144 @@
+package speech
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blaenk/bmo/bot"
+	"github.com/blaenk/bmo/bot/bottest"
+)
+
+const timeout = 5 * time.Second
+
+// newSession creates a bot with the speech commands, connected to a fake
+// session with a guild where a listener is in the voice channel and a stranger
+// isn't. Everyone may say things, but only the owner may use SSML or change
+// the guild's voice.
+func newSession(t *testing.T, config *bot.Config) (*bot.Bot, *bottest.Session) {
+	config.Discord.Owner = "owner"
+
+	b, err := bot.New(config, bot.NewMemoryStore())
+	require.NoError(t, err)
+
+	b.RegisterCommands("speech", New())
+
+	session := bottest.NewSession(&discordgo.User{ID: "bmo", Username: "bmo"})
+	session.AddUser(&discordgo.User{ID: "owner", Username: "owner"})
+
+	session.AddGuild(&discordgo.Guild{
+		ID: "guild",
+		Channels: []*discordgo.Channel{
+			{ID: "text", Type: discordgo.ChannelTypeGuildText},
+			{ID: "voice", Type: discordgo.ChannelTypeGuildVoice},
+		},
+		Members: []*discordgo.Member{
+			{User: &discordgo.User{ID: "listener", Username: "listener"}},
+			{User: &discordgo.User{ID: "stranger", Username: "stranger"}},
+		},
+		VoiceStates: []*discordgo.VoiceState{
+			{GuildID: "guild", ChannelID: "voice", UserID: "listener"},
+		},
+	})
+
+	require.NoError(t, b.OpenSession(session))
+
+	t.Cleanup(func() { b.Close() })
+
+	session.Ready()
+
+	b.Settings().UpdateGuild("guild", func(guild *bot.GuildSettings) {
+		guild.Grants = []bot.Grant{{Subject: bot.EveryoneSubject, Permission: "speech.say"}}
+	})
+
+	return b, session
+}
+
+// command sends the command from the user and returns the bot's reply.
+func command(t *testing.T, session *bottest.Session, userID, command string) string {
+	session.Message("text", userID, "<@bmo> "+command)
+
+	reply, err := session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	return reply.Content
+}
+
+func withoutCooldown() *bot.Config {
+	config := bot.DefaultConfig()
+	config.Defaults.SayCooldown = bot.Duration{}
+
+	return config
+}
+
+func TestSayRejects(t *testing.T) {
+	_, session := newSession(t, withoutCooldown())
+
+	tests := []struct {
+		name    string
+		userID  string
+		command string
+		reply   string
+	}{
+		{"empty", "listener", `say "  "`, "You didn't say anything!"},
+		{"too long", "listener", "say " + strings.Repeat("a", 201), "That's too long! Keep it under 200 characters."},
+		{"ssml", "listener", "say <speak>hi</speak>", "Sorry, you need the `speech.ssml` permission to use SSML."},
+		{"not in voice", "stranger", "say hi", "You're not in a voice channel!"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, "<@"+test.userID+">: "+test.reply, command(t, session, test.userID, test.command))
+		})
+	}
+}
+
+func TestSayCooldown(t *testing.T) {
+	_, session := newSession(t, bot.DefaultConfig())
+
+	assert.Contains(t, command(t, session, "stranger", "say hi"), "You're not in a voice channel!")
+	assert.Contains(t, command(t, session, "stranger", "say hi"), "Slow down! Try `say` again in 10s.")
+
+	// Users who keep trying are only told to slow down once.
+	session.Message("text", "stranger", "<@bmo> say hi")
+
+	_, err := session.NextMessage(100 * time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestVoiceCommands(t *testing.T) {
+	b, session := newSession(t, withoutCooldown())
+
+	tests := []struct {
+		name    string
+		userID  string
+		command string
+		reply   string
+	}{
+		{"rate too low", "listener", "voice rate 0", "The rate must be a number from 1 to 5."},
+		{"rate too high", "listener", "voice rate 6", "The rate must be a number from 1 to 5."},
+		{"bad name", "listener", "voice set R2-D2", "That's not a voice name."},
+		{"user rate", "listener", "voice rate 2", "Voice updated."},
+		{"user name", "listener", "voice set Amy", "Voice updated."},
+		{"guild denied", "listener", "voice guild rate 3", "Sorry, you need the `admin.voice` permission to do that."},
+		{"guild rate", "owner", "voice guild rate 5", "Voice updated."},
+		{"guild name", "owner", "voice guild set Brian", "Voice updated."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, "<@"+test.userID+">: "+test.reply, command(t, session, test.userID, test.command))
+		})
+	}
+
+	assert.Equal(t, bot.VoiceSettings{Name: "Amy", Rate: "slow"}, b.Settings().User("listener").Voice)
+	assert.Equal(t, bot.VoiceSettings{Name: "Brian", Rate: "x-fast"}, b.Settings().Guild("guild").Voice)
+	assert.Equal(t, bot.VoiceSettings{}, b.Settings().User("owner").Voice)
+
+	assert.Equal(t, "<@listener>: Voice updated.", command(t, session, "listener", "voice reset"))
+	assert.Equal(t, bot.VoiceSettings{}, b.Settings().User("listener").Voice)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/blaenk/bmo/bot"
+	"github.com/blaenk/bmo/console"
+)
+
+// runConsole runs the bot in the terminal instead of on Discord. The config
+// file is optional, and nothing is saved to the store.
+func runConsole(configPath string, args []string) {
+	flags := flag.NewFlagSet("console", flag.ExitOnError)
+
+	wavPath := flags.String("wav", "", "write the audio played to this WAV file instead of discarding it")
+	verbose := flags.Bool("verbose", false, "log everything rather than only warnings and errors")
+
+	flags.Parse(args)
+
+	if !*verbose {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	config := bot.DefaultConfig()
+
+	if _, err := os.Stat(configPath); err == nil {
+		if config, err = bot.LoadConfig(configPath); err != nil {
+			log.WithError(err).Fatal("Couldn't load config")
+		}
+	}
+
+	// The console's author owns the bot so that every command can be tried.
+	config.Discord.Owner = console.AuthorID
+
+	var sink console.Sink = console.NullSink{}
+
+	if *wavPath != "" {
+		wav, err := console.NewWAVSink(*wavPath)
+
+		if err != nil {
+			log.WithError(err).Fatal("Couldn't create WAV file")
+		}
+
+		sink = wav
+	}
+
+	bot, err := bot.New(config, bot.NewMemoryStore())
+
+	if err != nil {
+		log.WithError(err).Fatal("Couldn't set up bot")
+	}
+
+	registerPlugins(bot)
+
+	defer bot.Close()
+
+	log.RegisterExitHandler(func() { bot.Close() })
+
+	// Close the bot on an interrupt too, so that the WAV file is finished.
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, os.Interrupt)
+
+	go func() {
+		<-signalChannel
+
+		bot.Close()
+		os.Exit(0)
+	}()
+
+	if err := console.Run(bot, os.Stdin, os.Stdout, sink); err != nil {
+		log.WithError(err).Error("Couldn't read from the console")
+	}
+}
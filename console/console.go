@@ -0,0 +1,229 @@
+// Package console runs the bot in a terminal instead of on Discord, so that
+// commands and previews can be tried out while developing plugins.
+//
+// Lines typed into the console are sent to the bot as messages from the
+// console's author in a text channel of the console's guild. Lines starting
+// with a slash are commands, so "/ping" is sent as a message mentioning the
+// bot followed by "ping". What the bot sends is printed, and what it plays in
+// the guild's voice channel, where the author is, goes to a Sink.
+package console
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/blaenk/bmo/bot"
+	"github.com/blaenk/bmo/bot/bottest"
+)
+
+const (
+	// AuthorID is the ID of the user the console's lines are sent as. It should
+	// be the bot's owner so that every command is permitted.
+	AuthorID = "console"
+
+	selfID   = "bmo"
+	selfName = "bmo"
+
+	guildID        = "console"
+	textChannelID  = "text"
+	voiceChannelID = "voice"
+)
+
+// Session is a bot.Session that prints the messages the bot sends instead of
+// sending them to Discord.
+type Session struct {
+	*bottest.Session
+
+	lock   sync.Mutex
+	out    io.Writer
+	nextID int
+
+	// mentions replaces user mentions with the user's name.
+	mentions *strings.Replacer
+
+	voice *VoiceConnection
+}
+
+// NewSession creates a session with a guild for the console, printing to out
+// and playing audio to the sink.
+func NewSession(out io.Writer, sink Sink) *Session {
+	s := &Session{
+		Session: bottest.NewSession(&discordgo.User{ID: selfID, Username: selfName, Bot: true}),
+		out:     out,
+		mentions: strings.NewReplacer(
+			"<@"+selfID+">", "@"+selfName,
+			"<@"+AuthorID+">", "@"+AuthorID,
+		),
+	}
+
+	s.voice = newVoiceConnection(s, sink)
+
+	s.AddGuild(&discordgo.Guild{
+		ID:   guildID,
+		Name: "Console",
+		Channels: []*discordgo.Channel{
+			{ID: textChannelID, Name: "text", Type: discordgo.ChannelTypeGuildText},
+			{ID: voiceChannelID, Name: "voice", Type: discordgo.ChannelTypeGuildVoice},
+		},
+		Members: []*discordgo.Member{
+			{User: &discordgo.User{ID: AuthorID, Username: AuthorID}},
+		},
+		VoiceStates: []*discordgo.VoiceState{
+			{GuildID: guildID, ChannelID: voiceChannelID, UserID: AuthorID},
+		},
+	})
+
+	return s
+}
+
+// printf prints a line to the console.
+func (s *Session) printf(format string, args ...interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	fmt.Fprintf(s.out, format+"\n", args...)
+}
+
+// message creates the record of a message the bot sent.
+func (s *Session) message(channelID string) *discordgo.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.nextID++
+
+	return &discordgo.Message{
+		ID:        fmt.Sprintf("sent-%d", s.nextID),
+		ChannelID: channelID,
+		GuildID:   guildID,
+		Author:    &discordgo.User{ID: selfID, Username: selfName, Bot: true},
+	}
+}
+
+// ChannelMessageSend prints the message.
+func (s *Session) ChannelMessageSend(channelID, content string) (*discordgo.Message, error) {
+	message := s.message(channelID)
+	message.Content = content
+
+	s.printf("%s: %s", selfName, s.mentions.Replace(content))
+
+	return message, nil
+}
+
+// ChannelMessageSendEmbed prints the embed as text.
+func (s *Session) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	message := s.message(channelID)
+	message.Embeds = []*discordgo.MessageEmbed{embed}
+
+	s.printf("%s: %s", selfName, s.mentions.Replace(formatEmbed(embed)))
+
+	return message, nil
+}
+
+// ChannelMessageSendReply prints the message, noting which message it replies
+// to.
+func (s *Session) ChannelMessageSendReply(channelID, content string, reference *discordgo.MessageReference) (*discordgo.Message, error) {
+	message := s.message(channelID)
+	message.Content = content
+	message.MessageReference = reference
+
+	s.printf("%s (replying to %s): %s", selfName, reference.MessageID, s.mentions.Replace(content))
+
+	return message, nil
+}
+
+// formatEmbed describes the embed as indented lines of text.
+func formatEmbed(embed *discordgo.MessageEmbed) string {
+	lines := []string{"[embed]"}
+
+	if embed.Author != nil && embed.Author.Name != "" {
+		lines = append(lines, "  "+embed.Author.Name)
+	}
+
+	if embed.Title != "" {
+		lines = append(lines, "  "+embed.Title)
+	}
+
+	if embed.URL != "" {
+		lines = append(lines, "  <"+embed.URL+">")
+	}
+
+	if embed.Description != "" {
+		lines = append(lines, "  "+strings.Replace(embed.Description, "\n", "\n  ", -1))
+	}
+
+	for _, field := range embed.Fields {
+		lines = append(lines, fmt.Sprintf("  %s: %s", field.Name, field.Value))
+	}
+
+	if embed.Footer != nil && embed.Footer.Text != "" {
+		lines = append(lines, "  "+embed.Footer.Text)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// ChannelVoiceJoin joins the console's voice channel.
+func (s *Session) ChannelVoiceJoin(guildID, channelID string, mute, deaf bool) (bot.VoiceConnection, error) {
+	if channelID != voiceChannelID {
+		return nil, fmt.Errorf("Channel %s isn't the console's voice channel", channelID)
+	}
+
+	s.voice.join(channelID)
+
+	return s.voice, nil
+}
+
+// VoiceConnection is the console's voice connection while the bot is in the
+// voice channel.
+func (s *Session) VoiceConnection(id string) (bot.VoiceConnection, bool) {
+	if id != guildID || !s.voice.isConnected() {
+		return nil, false
+	}
+
+	return s.voice, true
+}
+
+// Close closes the sink.
+func (s *Session) Close() error {
+	s.Session.Close()
+
+	return s.voice.close()
+}
+
+// Run opens the bot on a console session and sends it the lines read from in
+// until there are no more, printing what it sends to out. The bot should be
+// closed afterwards, which also closes the sink.
+func Run(b *bot.Bot, in io.Reader, out io.Writer, sink Sink) error {
+	session := NewSession(out, sink)
+
+	if err := b.OpenSession(session); err != nil {
+		return err
+	}
+
+	session.Ready()
+
+	session.printf("* Connected as %s. Commands start with a slash, e.g. /ping", selfName)
+
+	scanner := bufio.NewScanner(in)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			line = "<@" + selfID + "> " + line[1:]
+		}
+
+		session.Message(textChannelID, AuthorID, line)
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,78 @@
+package console
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blaenk/bmo/bot"
+	"github.com/blaenk/bmo/commanders/ping"
+)
+
+func TestRun(t *testing.T) {
+	config := bot.DefaultConfig()
+	config.Discord.Owner = AuthorID
+
+	b, err := bot.New(config, bot.NewMemoryStore())
+	require.NoError(t, err)
+
+	b.RegisterCommands("ping", ping.New())
+
+	var out bytes.Buffer
+
+	require.NoError(t, Run(b, strings.NewReader("/ping\n\nnot a command\n"), &out, NullSink{}))
+
+	// Closing waits for the commands to finish.
+	require.NoError(t, b.Close())
+
+	assert.Contains(t, out.String(), "bmo: @console: Pong!\n")
+	assert.Equal(t, 1, strings.Count(out.String(), "Pong!"))
+}
+
+func TestFormatEmbed(t *testing.T) {
+	embed := &discordgo.MessageEmbed{
+		Title:       "Launch",
+		URL:         "https://example.com",
+		Description: "first\nsecond",
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Posted"},
+	}
+
+	assert.Equal(t, "[embed]\n  Launch\n  <https://example.com>\n  first\n  second\n  Posted", formatEmbed(embed))
+}
+
+func TestWAVSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bmo-console")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	name := path.Join(dir, "out.wav")
+
+	sink, err := NewWAVSink(name)
+	require.NoError(t, err)
+
+	require.NoError(t, sink.Write([]int16{1, -1, 2, -2}))
+	require.NoError(t, sink.Write([]int16{3, -3}))
+	require.NoError(t, sink.Close())
+
+	contents, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	require.Len(t, contents, wavHeaderSize+12)
+
+	assert.Equal(t, "RIFF", string(contents[0:4]))
+	assert.Equal(t, uint32(wavHeaderSize-8+12), binary.LittleEndian.Uint32(contents[4:8]))
+	assert.Equal(t, "WAVE", string(contents[8:12]))
+	assert.Equal(t, uint16(channels), binary.LittleEndian.Uint16(contents[22:24]))
+	assert.Equal(t, uint32(frequency), binary.LittleEndian.Uint32(contents[24:28]))
+	assert.Equal(t, "data", string(contents[36:40]))
+	assert.Equal(t, uint32(12), binary.LittleEndian.Uint32(contents[40:44]))
+	assert.Equal(t, int16(-3), int16(binary.LittleEndian.Uint16(contents[wavHeaderSize+10:])))
+}
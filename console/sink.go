@@ -0,0 +1,117 @@
+package console
+
+import (
+	"encoding/binary"
+	"os"
+)
+
+const (
+	// Discord voice audio is 48kHz stereo, in 20ms frames of 960 samples per
+	// channel.
+	frequency int = 48000
+	channels  int = 2
+	frameSize int = 960
+)
+
+// Sink receives the PCM audio the bot plays in the voice channel.
+type Sink interface {
+	Write(pcm []int16) error
+	Close() error
+}
+
+// NullSink discards the audio.
+type NullSink struct{}
+
+// Write discards the PCM.
+func (NullSink) Write(pcm []int16) error {
+	return nil
+}
+
+// Close does nothing.
+func (NullSink) Close() error {
+	return nil
+}
+
+// wavHeaderSize is the size of the RIFF header written before the samples.
+const wavHeaderSize = 44
+
+// WAVSink writes the audio to a 16-bit PCM WAV file.
+type WAVSink struct {
+	file *os.File
+	size uint32
+}
+
+// NewWAVSink creates the WAV file at the path, replacing any that's there.
+func NewWAVSink(path string) (*WAVSink, error) {
+	file, err := os.Create(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sink := &WAVSink{file: file}
+
+	// The sizes in the header are filled in once the file is closed.
+	if err := sink.writeHeader(); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+func (w *WAVSink) writeHeader() error {
+	const bitsPerSample = 16
+
+	blockAlign := channels * bitsPerSample / 8
+
+	header := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'},
+		uint32(wavHeaderSize - 8 + w.size),
+		[4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '},
+		uint32(16),
+		uint16(1), // PCM
+		uint16(channels),
+		uint32(frequency),
+		uint32(frequency * blockAlign),
+		uint16(blockAlign),
+		uint16(bitsPerSample),
+		[4]byte{'d', 'a', 't', 'a'},
+		w.size,
+	}
+
+	for _, field := range header {
+		if err := binary.Write(w.file, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write appends the PCM samples.
+func (w *WAVSink) Write(pcm []int16) error {
+	if err := binary.Write(w.file, binary.LittleEndian, pcm); err != nil {
+		return err
+	}
+
+	w.size += uint32(len(pcm) * 2)
+
+	return nil
+}
+
+// Close fills in the header's sizes and closes the file.
+func (w *WAVSink) Close() error {
+	if _, err := w.file.Seek(0, 0); err != nil {
+		w.file.Close()
+		return err
+	}
+
+	if err := w.writeHeader(); err != nil {
+		w.file.Close()
+		return err
+	}
+
+	return w.file.Close()
+}
@@ -0,0 +1,162 @@
+package console
+
+import (
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/layeh/gopus"
+)
+
+// VoiceConnection decodes the Opus frames the bot sends and writes them to
+// the sink. There's one for the console's guild, which stays open when the bot
+// disconnects so that everything played ends up in the same sink.
+type VoiceConnection struct {
+	lock sync.Mutex
+
+	session   *Session
+	channelID string
+	connected bool
+	speaking  bool
+
+	send    chan []byte
+	recv    chan *discordgo.Packet
+	done    chan struct{}
+	stopped chan struct{}
+
+	sink Sink
+}
+
+func newVoiceConnection(session *Session, sink Sink) *VoiceConnection {
+	v := &VoiceConnection{
+		session: session,
+		send:    make(chan []byte),
+		recv:    make(chan *discordgo.Packet),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		sink:    sink,
+	}
+
+	go v.play()
+
+	return v
+}
+
+// play decodes the frames into the sink until the connection is closed.
+func (v *VoiceConnection) play() {
+	defer close(v.stopped)
+
+	decoder, err := gopus.NewDecoder(frequency, channels)
+
+	if err != nil {
+		v.session.printf("* Couldn't create an Opus decoder: %s", err)
+		return
+	}
+
+	for {
+		select {
+		case frame := <-v.send:
+			pcm, err := decoder.Decode(frame, frameSize, false)
+
+			if err != nil {
+				v.session.printf("* Couldn't decode Opus frame: %s", err)
+				continue
+			}
+
+			if err := v.sink.Write(pcm); err != nil {
+				v.session.printf("* Couldn't write audio: %s", err)
+			}
+
+		case <-v.done:
+			return
+		}
+	}
+}
+
+// close stops playing and closes the sink.
+func (v *VoiceConnection) close() error {
+	close(v.done)
+	<-v.stopped
+
+	return v.sink.Close()
+}
+
+func (v *VoiceConnection) join(channelID string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.connected && v.channelID == channelID {
+		return
+	}
+
+	v.channelID = channelID
+	v.connected = true
+
+	v.session.printf("* %s joined voice channel %s", selfName, channelID)
+}
+
+// isConnected reports whether the bot is in a voice channel.
+func (v *VoiceConnection) isConnected() bool {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return v.connected
+}
+
+// ChannelID is the voice channel the bot is in.
+func (v *VoiceConnection) ChannelID() string {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	return v.channelID
+}
+
+// Ready is always true.
+func (v *VoiceConnection) Ready() bool {
+	return true
+}
+
+// Speaking prints when the bot starts and stops speaking.
+func (v *VoiceConnection) Speaking(speaking bool) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if speaking != v.speaking {
+		v.speaking = speaking
+
+		if speaking {
+			v.session.printf("* %s started speaking", selfName)
+		} else {
+			v.session.printf("* %s stopped speaking", selfName)
+		}
+	}
+
+	return nil
+}
+
+// OpusSend receives the frames that are written to the sink.
+func (v *VoiceConnection) OpusSend() chan<- []byte {
+	return v.send
+}
+
+// OpusRecv never receives anything, since nobody else is in the channel.
+func (v *VoiceConnection) OpusRecv() <-chan *discordgo.Packet {
+	return v.recv
+}
+
+// OnSpeakingUpdate does nothing, since nobody else is in the channel.
+func (v *VoiceConnection) OnSpeakingUpdate(handler func(*discordgo.VoiceSpeakingUpdate)) {
+}
+
+// Disconnect leaves the voice channel.
+func (v *VoiceConnection) Disconnect() error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.connected {
+		v.connected = false
+
+		v.session.printf("* %s left voice channel %s", selfName, v.channelID)
+	}
+
+	return nil
+}
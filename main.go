@@ -1,37 +1,60 @@
 package main
 
 import (
+	"flag"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/joho/godotenv"
 
 	"github.com/blaenk/bmo/bot"
+	"github.com/blaenk/bmo/commanders/admin"
 	"github.com/blaenk/bmo/commanders/audio"
 	"github.com/blaenk/bmo/commanders/ping"
+	"github.com/blaenk/bmo/commanders/speech"
 	"github.com/blaenk/bmo/previewers/hn"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
-	}
+	configPath := flag.String("config", "bmo.toml", "path to the config file")
+
+	flag.Parse()
 
 	log.SetFormatter(&log.JSONFormatter{
 		TimestampFormat: time.RFC3339Nano,
 	})
 
-	bot := bot.New()
+	if flag.Arg(0) == "console" {
+		runConsole(*configPath, flag.Args()[1:])
+		return
+	}
+
+	config, err := bot.LoadConfig(*configPath)
+
+	if err != nil {
+		log.WithError(err).Fatal("Couldn't load config")
+	}
+
+	store, err := bot.OpenBoltStore(config.Paths.Store)
 
-	bot.RegisterCommand(ping.New())
-	bot.RegisterCommand(audio.New())
+	if err != nil {
+		log.WithError(err).Fatal("Couldn't open store")
+	}
 
-	bot.RegisterPreviewer(hn.New())
+	bot, err := bot.New(config, store)
+
+	if err != nil {
+		log.WithError(err).Fatal("Couldn't set up bot")
+	}
+
+	registerPlugins(bot)
+
+	if err := bot.Open(); err != nil {
+		log.WithError(err).Fatal("Couldn't open bot")
+	}
 
-	bot.Open()
 	defer bot.Close()
 
 	log.RegisterExitHandler(func() { bot.Close() })
@@ -45,5 +68,26 @@ func main() {
 	// output.
 	signal.Notify(signalChannel, syscall.SIGPIPE)
 
-	<-signalChannel
+	// Listen for SIGHUP to reload the config.
+	signal.Notify(signalChannel, syscall.SIGHUP)
+
+	for received := range signalChannel {
+		if received != syscall.SIGHUP {
+			return
+		}
+
+		if err := bot.ReloadConfig(); err != nil {
+			log.WithError(err).Error("Couldn't reload config")
+		}
+	}
+}
+
+// registerPlugins registers the bot's commands and previewers.
+func registerPlugins(bot *bot.Bot) {
+	bot.RegisterCommands("admin", admin.New())
+	bot.RegisterCommands("ping", ping.New())
+	bot.RegisterCommands("audio", audio.New())
+	bot.RegisterCommands("speech", speech.New())
+
+	bot.RegisterPreviewer("hn", hn.New())
 }
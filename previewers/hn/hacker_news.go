@@ -1,9 +1,11 @@
 package hn
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strconv"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/bwmarrin/discordgo"
@@ -11,15 +13,46 @@ import (
 	"github.com/blaenk/bmo/bot"
 )
 
-// HackerNews is an empty type that implements Previewer.
-type HackerNews struct{}
+// HackerNews implements Previewer.
+type HackerNews struct {
+	// name is the name the previewer was registered under.
+	name string
+}
 
 // New creates a new HackerNews instance.
 func New() *HackerNews {
-	return &HackerNews{}
+	return &HackerNews{name: "hn"}
+}
+
+// Init records the name the previewer was registered under.
+func (hn *HackerNews) Init(context *bot.PluginContext) error {
+	hn.name = context.Name
+
+	return nil
+}
+
+// previewSent announces the preview of the message's link.
+func (hn *HackerNews) previewSent(b *bot.Bot, msg, preview *discordgo.Message, link *url.URL) {
+	guildID, _ := b.MessageGuildID(msg)
+
+	b.Bus().Publish(&bot.PreviewSentEvent{
+		Previewer: hn.name,
+		GuildID:   guildID,
+		ChannelID: msg.ChannelID,
+		MessageID: msg.ID,
+		PreviewID: preview.ID,
+		URL:       link,
+	})
+}
+
+// location is the time zone item times are shown in for the message's guild.
+func location(bot *bot.Bot, msg *discordgo.Message) *time.Location {
+	guildID, _ := bot.MessageGuildID(msg)
+
+	return bot.Config().Guild(guildID).Location()
 }
 
-func (hn *HackerNews) previewStory(bot *bot.Bot, item *Item, msg *discordgo.Message, logger *log.Entry) {
+func (hn *HackerNews) previewStory(bot *bot.Bot, item *Item, msg *discordgo.Message, link *url.URL, logger *log.Entry) {
 	description := fmt.Sprintf("**%d** points. **%d** comments", item.Score, item.Descendants)
 
 	embed := &discordgo.MessageEmbed{
@@ -37,23 +70,32 @@ func (hn *HackerNews) previewStory(bot *bot.Bot, item *Item, msg *discordgo.Mess
 			URL:  "https://news.ycombinator.com",
 			Name: "Hacker News",
 		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Posted at " + item.formatTime(location(bot, msg)),
+		},
 	}
 
-	_, err := bot.Session().ChannelMessageSendEmbed(msg.ChannelID, embed)
+	preview, err := bot.Session().ChannelMessageSendEmbed(msg.ChannelID, embed)
 
 	if err != nil {
 		logger.WithError(err).Error("Couldn't send HN Story embed")
+	} else {
+		// The preview is announced once all of it is sent.
+		defer hn.previewSent(bot, msg, preview, link)
 	}
 
-	_, err = bot.Session().ChannelMessageSend(msg.ChannelID, item.URL)
+	target, err := bot.Session().ChannelMessageSend(msg.ChannelID, item.URL)
 
 	if err != nil {
 		logger.WithError(err).Error("Couldn't send HN Story target URL")
+		return
 	}
+
+	bot.RecordResponse(msg, target)
 }
 
-func (hn *HackerNews) previewComment(bot *bot.Bot, item *Item, msg *discordgo.Message, logger *log.Entry) {
-	root, err := item.findRoot()
+func (hn *HackerNews) previewComment(ctx context.Context, bot *bot.Bot, item *Item, msg *discordgo.Message, link *url.URL, logger *log.Entry) {
+	root, err := item.findRoot(ctx)
 
 	if err != nil {
 		logger.WithError(err).Error("Couldn't find root")
@@ -79,12 +121,18 @@ func (hn *HackerNews) previewComment(bot *bot.Bot, item *Item, msg *discordgo.Me
 		Thumbnail: &discordgo.MessageEmbedThumbnail{
 			URL: "https://news.ycombinator.com/y18.gif",
 		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: "Posted at " + item.formatTime(location(bot, msg)),
+		},
 	}
 
-	_, err = bot.Session().ChannelMessageSendEmbed(msg.ChannelID, embed)
+	preview, err := bot.Session().ChannelMessageSendEmbed(msg.ChannelID, embed)
 
 	if err != nil {
 		logger.WithError(err).Error("Couldn't send HN Comment embed")
+	} else {
+		// The preview is announced once all of it is sent.
+		defer hn.previewSent(bot, msg, preview, link)
 	}
 
 	formattedBody, err := item.formatCommentBody()
@@ -98,16 +146,15 @@ func (hn *HackerNews) previewComment(bot *bot.Bot, item *Item, msg *discordgo.Me
 %s
 :speech_left: **END QUOTE** :speech_balloon:`, formattedBody)
 
-	_, err = bot.Session().ChannelMessageSend(msg.ChannelID, commentBody)
-
-	if err != nil {
+	// Long comments are split into several replies.
+	if _, err := bot.Reply(ctx, msg, commentBody); err != nil {
 		logger.WithError(err).Error("Couldn't send HN Comment body")
 	}
 }
 
 // Preview sends Discord message embeds previewing any detected Hacker News
 // item.
-func (hn *HackerNews) Preview(bot *bot.Bot, msg *discordgo.Message, link *url.URL) {
+func (hn *HackerNews) Preview(ctx context.Context, bot *bot.Bot, msg *discordgo.Message, link *url.URL) {
 	if link.Host != "news.ycombinator.com" {
 		return
 	}
@@ -126,7 +173,7 @@ func (hn *HackerNews) Preview(bot *bot.Bot, msg *discordgo.Message, link *url.UR
 		return
 	}
 
-	item, err := getHNItem(intID)
+	item, err := getHNItem(ctx, intID)
 
 	if err != nil {
 		hnLog.WithError(err).Error("Couldn't get item")
@@ -137,10 +184,10 @@ func (hn *HackerNews) Preview(bot *bot.Bot, msg *discordgo.Message, link *url.UR
 
 	switch item.Type {
 	case "story":
-		hn.previewStory(bot, item, msg, hnLog)
+		hn.previewStory(bot, item, msg, link, hnLog)
 
 	case "comment":
-		hn.previewComment(bot, item, msg, hnLog)
+		hn.previewComment(ctx, bot, item, msg, link, hnLog)
 
 	default:
 		hnLog.Warn("Unknown HN item type")
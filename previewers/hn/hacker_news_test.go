@@ -1,9 +1,12 @@
 package hn
 
-import "testing"
+import (
+	"context"
+	"testing"
+)
 
 func TestFormatStory(t *testing.T) {
-	story, err := getHNItem(13027718)
+	_, err := getHNItem(context.Background(), 13027718)
 
 	if err != nil {
 		t.Error("Expected no error")
@@ -11,7 +14,7 @@ func TestFormatStory(t *testing.T) {
 }
 
 func TestFormatComment(t *testing.T) {
-	comment, err := getHNItem(13028891)
+	_, err := getHNItem(context.Background(), 13028891)
 
 	if err != nil {
 		t.Error("Expected no error")
@@ -19,7 +22,7 @@ func TestFormatComment(t *testing.T) {
 }
 
 func TestDeepFormatComment(t *testing.T) {
-	comment, err := getHNItem(13030726)
+	_, err := getHNItem(context.Background(), 13030726)
 
 	if err != nil {
 		t.Error("Expected no error")
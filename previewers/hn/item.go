@@ -1,6 +1,7 @@
 package hn
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -27,15 +28,24 @@ type Item struct {
 	URL         string `json:"url"`
 }
 
-func getHNItem(id int) (*Item, error) {
-	url := fmt.Sprintf("https://hacker-news.firebaseio.com/v0/item/%d.json", id)
+// apiURL is where items are fetched from. Tests point it at a fake server.
+var apiURL = "https://hacker-news.firebaseio.com/v0"
+
+func getHNItem(ctx context.Context, id int) (*Item, error) {
+	url := fmt.Sprintf("%s/item/%d.json", apiURL, id)
 
 	logger := log.WithFields(log.Fields{
 		"topic": "HN",
 		"ID":    id,
 	})
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest("GET", url, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 
 	if err != nil {
 		logger.WithError(err).Error("Couldn't get item")
@@ -55,26 +65,10 @@ func getHNItem(id int) (*Item, error) {
 	return item, nil
 }
 
-func (i *Item) formatTime() string {
-	t := time.Unix(i.Time, 0)
-	format := "3:04pm MST on Monday, January 2"
-
-	// TODO
-	// Allow timezone to be set via environment variable. If UTC Is chosen then we
-	// don't need to load the location.
-
-	pst, err := time.LoadLocation("America/Los_Angeles")
-
-	if err != nil {
-		log.WithFields(log.Fields{
-			"topic": "HN",
-			"id":    i.ID,
-		}).WithError(err).Error("Couldn't load America/Los_Angeles")
-
-		return t.UTC().Format(format)
-	}
-
-	return t.In(pst).Format(format)
+// formatTime formats the item's time in the location, which is configured per
+// guild.
+func (i *Item) formatTime(location *time.Location) string {
+	return time.Unix(i.Time, 0).In(location).Format("3:04pm MST on Monday, January 2")
 }
 
 func (i *Item) formatCommentBody() (string, error) {
@@ -104,13 +98,13 @@ func (i *Item) authorURL() string {
 	return fmt.Sprintf("https://news.ycombinator.com/user?id=%s", i.Author)
 }
 
-func (i *Item) findRoot() (*Item, error) {
+func (i *Item) findRoot(ctx context.Context) (*Item, error) {
 	logger := log.WithFields(log.Fields{
 		"topic": "HN",
 		"ID":    i.ID,
 	})
 
-	parent, err := getHNItem(i.Parent)
+	parent, err := getHNItem(ctx, i.Parent)
 
 	if err != nil {
 		logger.WithField("parent", i.Parent).WithError(err).Error("Couldn't get parent")
@@ -122,7 +116,7 @@ func (i *Item) findRoot() (*Item, error) {
 		return parent, nil
 
 	case "comment":
-		return parent.findRoot()
+		return parent.findRoot(ctx)
 
 	default:
 		err := fmt.Errorf("Unknown type")
@@ -0,0 +1,229 @@
+package hn
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/blaenk/bmo/bot"
+	"github.com/blaenk/bmo/bot/bottest"
+)
+
+const timeout = 5 * time.Second
+
+var items = map[int]string{
+	1: `{"id": 1, "type": "story", "by": "pg", "title": "Launch", "url": "https://example.com/launch",
+	     "score": 42, "descendants": 1, "kids": [2], "time": 1160418111}`,
+	2: `{"id": 2, "type": "comment", "by": "sama", "parent": 1, "text": "<p>Nice <i>launch</i></p>", "time": 1160418200}`,
+	4: `{"id": 4, "type": "comment", "by": "sama", "parent": 1, "text": "` +
+		strings.Repeat("<p>"+strings.Repeat("word ", 100)+"</p>", 10) + `", "time": 1160418300}`,
+}
+
+// fakeAPI serves the items in place of the Hacker News API for the duration of
+// the test.
+func fakeAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id int
+
+		if _, err := fmt.Sscanf(r.URL.Path, "/item/%d.json", &id); err != nil || items[id] == "" {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprint(w, items[id])
+	}))
+
+	original := apiURL
+	apiURL = server.URL
+
+	t.Cleanup(func() {
+		apiURL = original
+		server.Close()
+	})
+}
+
+func newSession(t *testing.T) (*bot.Bot, *bottest.Session) {
+	config := bot.DefaultConfig()
+	config.Discord.Owner = "owner"
+
+	b, err := bot.New(config, bot.NewMemoryStore())
+	require.NoError(t, err)
+
+	b.RegisterPreviewer("hn", New())
+
+	session := bottest.NewSession(&discordgo.User{ID: "bmo"})
+	session.AddUser(&discordgo.User{ID: "owner"})
+
+	session.AddGuild(&discordgo.Guild{
+		ID:       "guild",
+		Channels: []*discordgo.Channel{{ID: "text", Type: discordgo.ChannelTypeGuildText}},
+		Members:  []*discordgo.Member{{User: &discordgo.User{ID: "user"}}},
+	})
+
+	require.NoError(t, b.OpenSession(session))
+
+	t.Cleanup(func() { b.Close() })
+
+	session.Ready()
+
+	return b, session
+}
+
+func TestPreviewStory(t *testing.T) {
+	fakeAPI(t)
+
+	b, session := newSession(t)
+
+	previews := make(chan *bot.PreviewSentEvent, 1)
+
+	b.Bus().Subscribe(func(event *bot.PreviewSentEvent) {
+		previews <- event
+	})
+
+	msg := session.Message("text", "user", "look https://news.ycombinator.com/item?id=1")
+
+	embed, err := session.NextMessage(timeout)
+	require.NoError(t, err)
+	require.Len(t, embed.Embeds, 1)
+
+	assert.Equal(t, "Launch", embed.Embeds[0].Title)
+	assert.Equal(t, "**42** points. **1** comments", embed.Embeds[0].Description)
+	assert.Equal(t, "https://news.ycombinator.com/item?id=1", embed.Embeds[0].URL)
+
+	target, err := session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://example.com/launch", target.Content)
+
+	preview := <-previews
+
+	assert.Equal(t, "hn", preview.Previewer)
+	assert.Equal(t, "guild", preview.GuildID)
+	assert.Equal(t, msg.ID, preview.MessageID)
+	assert.Equal(t, embed.ID, preview.PreviewID)
+}
+
+func TestPreviewComment(t *testing.T) {
+	fakeAPI(t)
+
+	_, session := newSession(t)
+
+	session.Message("text", "user", "https://news.ycombinator.com/item?id=2")
+
+	embed, err := session.NextMessage(timeout)
+	require.NoError(t, err)
+	require.Len(t, embed.Embeds, 1)
+
+	assert.Equal(t, "Comment on: Launch", embed.Embeds[0].Title)
+	assert.Equal(t, "by **sama**", embed.Embeds[0].Description)
+
+	body, err := session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	assert.Contains(t, body.Content, "Nice *launch*")
+}
+
+func TestPreviewLongComment(t *testing.T) {
+	fakeAPI(t)
+
+	_, session := newSession(t)
+
+	msg := session.Message("text", "user", "https://news.ycombinator.com/item?id=4")
+
+	_, err := session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	// The comment is too long for one message, so it's split into replies.
+	var parts []*discordgo.Message
+
+	for {
+		part, err := session.NextMessage(100 * time.Millisecond)
+
+		if err != nil {
+			break
+		}
+
+		parts = append(parts, part)
+	}
+
+	assert.True(t, len(parts) > 1)
+
+	for _, part := range parts {
+		assert.True(t, len(part.Content) <= bot.MaxMessageLength)
+		require.NotNil(t, part.MessageReference)
+		assert.Equal(t, msg.ID, part.MessageReference.MessageID)
+	}
+}
+
+func TestPreviewMissingItem(t *testing.T) {
+	fakeAPI(t)
+
+	_, session := newSession(t)
+
+	session.Message("text", "user", "https://news.ycombinator.com/item?id=3")
+
+	_, err := session.NextMessage(100 * time.Millisecond)
+
+	assert.Error(t, err)
+}
+
+func TestPreviewEditedLink(t *testing.T) {
+	fakeAPI(t)
+
+	_, session := newSession(t)
+
+	msg := session.Message("text", "user", "look https://news.ycombinator.com/item?id=1")
+
+	for i := 0; i < 2; i++ {
+		_, err := session.NextMessage(timeout)
+		require.NoError(t, err)
+	}
+
+	// Only the link added by the edit is previewed.
+	session.Edit(msg, "look https://news.ycombinator.com/item?id=1 and https://news.ycombinator.com/item?id=2")
+
+	embed, err := session.NextMessage(timeout)
+	require.NoError(t, err)
+	require.Len(t, embed.Embeds, 1)
+
+	assert.Equal(t, "Comment on: Launch", embed.Embeds[0].Title)
+
+	_, err = session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	_, err = session.NextMessage(100 * time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestDeletePreviewedMessage(t *testing.T) {
+	fakeAPI(t)
+
+	b, session := newSession(t)
+
+	previews := make(chan *bot.PreviewSentEvent, 1)
+
+	b.Bus().Subscribe(func(event *bot.PreviewSentEvent) {
+		previews <- event
+	})
+
+	msg := session.Message("text", "user", "https://news.ycombinator.com/item?id=1")
+
+	embed, err := session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	target, err := session.NextMessage(timeout)
+	require.NoError(t, err)
+
+	<-previews
+
+	session.Delete(msg)
+
+	assert.ElementsMatch(t, []string{embed.ID, target.ID}, session.Deleted())
+}